@@ -0,0 +1,89 @@
+// Gera o digest de tokens de API para uso no tokens.json com TOKEN_HASH_MODE.
+//
+//	go run ./cmd/hashtoken -mode sha256 std_1234567890
+//	echo "$TOKEN" | go run ./cmd/hashtoken -mode hmac-sha256 -secret "$TOKEN_HASH_SECRET"
+//	go run ./cmd/hashtoken -mode sha256 -generate pro_
+package main
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"fc-pos-golang-rate-limiter/internal/config"
+)
+
+func main() {
+	mode := flag.String("mode", envOrDefault("TOKEN_HASH_MODE", config.TokenHashSHA256), "modo de hash: sha256 ou hmac-sha256")
+	secret := flag.String("secret", os.Getenv("TOKEN_HASH_SECRET"), "segredo do modo hmac-sha256 (default $TOKEN_HASH_SECRET)")
+	generate := flag.String("generate", "", "gera um novo token aleatório com o prefixo informado (ex.: std_)")
+	flag.Parse()
+
+	if err := run(*mode, *secret, *generate, flag.Args(), os.Stdin, os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "hashtoken: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(mode, secret, generate string, args []string, stdin io.Reader, stdout io.Writer) error {
+	if mode == config.TokenHashNone {
+		return fmt.Errorf("mode %q does not hash tokens", mode)
+	}
+	hasher, err := config.NewTokenHasher(mode, secret)
+	if err != nil {
+		return err
+	}
+
+	// Novo token: o valor bruto é exibido uma única vez para ser entregue ao cliente
+	if generate != "" {
+		token, err := newToken(generate)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(stdout, "token:  %s\ndigest: %s\n", token, hasher(token))
+		return err
+	}
+
+	tokens := args
+	if len(tokens) == 0 {
+		scanner := bufio.NewScanner(stdin)
+		for scanner.Scan() {
+			if token := strings.TrimSpace(scanner.Text()); token != "" {
+				tokens = append(tokens, token)
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			return err
+		}
+	}
+	if len(tokens) == 0 {
+		return fmt.Errorf("no tokens given (pass them as arguments or on stdin)")
+	}
+
+	for _, token := range tokens {
+		if _, err := fmt.Fprintln(stdout, hasher(token)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func newToken(prefix string) (string, error) {
+	buf := make([]byte, 20)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return prefix + hex.EncodeToString(buf), nil
+}
+
+func envOrDefault(name, fallback string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return fallback
+}
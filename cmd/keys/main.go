@@ -0,0 +1,119 @@
+// Lista e remove as chaves de rate limit de um namespace no Redis usando SCAN.
+// A conexão, o prefixo e o tenant padrão vêm da mesma configuração do servidor.
+//
+//	go run ./cmd/keys list
+//	go run ./cmd/keys -prefix checkout -tenant acme purge
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"fc-pos-golang-rate-limiter/internal/config"
+	"fc-pos-golang-rate-limiter/internal/limiter"
+)
+
+func main() {
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "keys: %v\n", err)
+		os.Exit(1)
+	}
+
+	prefix := flag.String("prefix", cfg.RateLimit.KeyPrefix, "prefixo das chaves (default $RATE_LIMIT_KEY_PREFIX)")
+	tenant := flag.String("tenant", cfg.RateLimit.Tenant, "tenant das chaves (default $RATE_LIMIT_TENANT); vazio inclui todos os tenants do prefixo")
+	all := flag.Bool("all", false, "permite namespace vazio, ou seja, todas as chaves do database")
+	timeout := flag.Duration("timeout", time.Minute, "tempo máximo da operação")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "uso: keys [flags] list|purge\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if !config.ValidKeySegment(*prefix) || !config.ValidKeySegment(*tenant) {
+		fmt.Fprintln(os.Stderr, "keys: -prefix and -tenant must not contain :, *, ?, [, ], \\, { or }")
+		os.Exit(2)
+	}
+	namespace := limiter.KeyNamespace(*prefix, *tenant)
+	if namespace == "" && !*all {
+		fmt.Fprintln(os.Stderr, "keys: empty namespace would match every key in the database; set -prefix/-tenant or pass -all")
+		os.Exit(2)
+	}
+
+	var store keyStore
+	var invalidator limiter.BlockInvalidator
+	if cfg.Redis.Mode == config.RedisModeSharded {
+		shards, err := limiter.NewRedisShards(&cfg.Redis)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "keys: %v\n", err)
+			os.Exit(1)
+		}
+		sharded := limiter.NewShardedStrategy(shards)
+		store, invalidator = sharded, limiter.NewShardedBlockInvalidator(sharded, cfg.BlockCache.Channel)
+	} else {
+		client, err := limiter.NewRedisClient(&cfg.Redis)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "keys: %v\n", err)
+			os.Exit(1)
+		}
+		store, invalidator = limiter.NewRedisStrategy(client), limiter.NewRedisBlockInvalidator(client, cfg.BlockCache.Channel)
+	}
+	defer store.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	if err := run(ctx, store, flag.Arg(0), namespace, os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "keys: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Bloqueios removidos podem continuar no cache em memória das instâncias
+	if flag.Arg(0) != "purge" {
+		return
+	}
+	if err := invalidator.Publish(ctx, ""); err != nil {
+		fmt.Fprintf(os.Stderr, "keys: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// Storages que listam e removem chaves: Redis ou todos os shards do modo sharded
+type keyStore interface {
+	ScanKeys(ctx context.Context, namespace string, fn func(key string) error) error
+	PurgeKeys(ctx context.Context, namespace string) (int, error)
+	Close() error
+}
+
+func run(ctx context.Context, store keyStore, command, namespace string, stdout io.Writer) error {
+	switch command {
+	case "list":
+		count := 0
+		err := store.ScanKeys(ctx, namespace, func(key string) error {
+			count++
+			_, err := fmt.Fprintln(stdout, key)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "%d keys in namespace %q\n", count, namespace)
+		return nil
+	case "purge":
+		deleted, err := store.PurgeKeys(ctx, namespace)
+		fmt.Fprintf(stdout, "%d keys deleted from namespace %q\n", deleted, namespace)
+		return err
+	default:
+		return errors.New("unknown command " + command + " (use list or purge)")
+	}
+}
@@ -0,0 +1,33 @@
+// Gera o JSON Schema do arquivo de política a partir dos tipos de internal/config.
+//
+//	go run ./cmd/policyschema -o configs/policy.schema.json
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"fc-pos-golang-rate-limiter/internal/config"
+)
+
+func main() {
+	output := flag.String("o", "", "arquivo de saída (default: stdout)")
+	flag.Parse()
+
+	schema, err := config.PolicySchema()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "policyschema: %v\n", err)
+		os.Exit(1)
+	}
+
+	if *output == "" {
+		_, err = os.Stdout.Write(schema)
+	} else {
+		err = os.WriteFile(*output, schema, 0o644)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "policyschema: %v\n", err)
+		os.Exit(1)
+	}
+}
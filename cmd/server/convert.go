@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fc-pos-golang-rate-limiter/internal/config"
+	"fc-pos-golang-rate-limiter/pkg/ratelimit"
+)
+
+// Conversões da configuração do servidor para os tipos de pkg/ratelimit
+
+func tokensOf(tokens config.TokenConfigs) ratelimit.TokenConfigs {
+	return convertMap(tokens, func(token config.TokenConfig) ratelimit.TokenConfig { return ratelimit.TokenConfig(token) })
+}
+
+func plansOf(plans config.PlanConfigs) ratelimit.Plans {
+	return convertMap(plans, func(plan config.PlanConfig) ratelimit.PlanConfig { return ratelimit.PlanConfig(plan) })
+}
+
+func rulesOf(rules config.RuleConfigs) ratelimit.Rules {
+	return convertMap(rules, func(rule config.RuleConfig) ratelimit.RuleConfig { return ratelimit.RuleConfig(rule) })
+}
+
+func routeRulesOf(routes config.RouteRules) ratelimit.RouteRules {
+	converted := make(ratelimit.RouteRules, len(routes))
+	for i, route := range routes {
+		converted[i] = ratelimit.RouteRule(route)
+	}
+	return converted
+}
+
+func redisOf(cfg config.RedisConfig) ratelimit.RedisConfig {
+	return ratelimit.RedisConfig{
+		Host:                cfg.Host,
+		Port:                cfg.Port,
+		Password:            cfg.Password,
+		DB:                  cfg.DB,
+		Mode:                cfg.Mode,
+		Addrs:               cfg.Addrs,
+		MasterName:          cfg.MasterName,
+		SentinelPassword:    cfg.SentinelPassword,
+		Username:            cfg.Username,
+		Socket:              cfg.Socket,
+		TLS:                 ratelimit.RedisTLSConfig(cfg.TLS),
+		PoolSize:            cfg.PoolSize,
+		MinIdleConns:        cfg.MinIdleConns,
+		DialTimeout:         cfg.DialTimeout,
+		ReadTimeout:         cfg.ReadTimeout,
+		WriteTimeout:        cfg.WriteTimeout,
+		MaxRetries:          cfg.MaxRetries,
+		MinRetryBackoff:     cfg.MinRetryBackoff,
+		MaxRetryBackoff:     cfg.MaxRetryBackoff,
+		ShardHealthInterval: cfg.ShardHealthInterval,
+	}
+}
+
+func convertMap[From, To any](m map[string]From, convert func(From) To) map[string]To {
+	if m == nil {
+		return nil
+	}
+	converted := make(map[string]To, len(m))
+	for key, value := range m {
+		converted[key] = convert(value)
+	}
+	return converted
+}
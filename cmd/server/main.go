@@ -2,23 +2,34 @@ package main
 
 import (
 	"context"
+	"errors"
+	"expvar"
+	"flag"
+	"fmt"
+	"io/fs"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
 	"fc-pos-golang-rate-limiter/internal/config"
 	"fc-pos-golang-rate-limiter/internal/handler"
-	"fc-pos-golang-rate-limiter/internal/limiter"
-	ratelimitMiddleware "fc-pos-golang-rate-limiter/internal/middleware"
+	"fc-pos-golang-rate-limiter/internal/jwtauth"
+	"fc-pos-golang-rate-limiter/internal/proxy"
+	"fc-pos-golang-rate-limiter/internal/rls"
+	"fc-pos-golang-rate-limiter/pkg/ratelimit"
+	"fc-pos-golang-rate-limiter/pkg/response"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/cors"
 	"github.com/go-redis/redis/v8"
 	httpSwagger "github.com/swaggo/http-swagger"
+	"google.golang.org/grpc"
 )
 
 // @title FullCycle Rate Limiter API
@@ -38,40 +49,171 @@ import (
 // @name API_KEY
 
 func main() {
-	cfg, err := config.LoadConfig()
-	if err != nil {
-		log.Fatalf("Failed to load configuration: %v", err)
-	}
+	checkConfig := flag.Bool("check-config", false, "valida a configuração e os arquivos referenciados sem iniciar o servidor")
+	flag.Parse()
 
-	tokenConfigs, err := config.LoadTokenConfigs("configs/tokens.json")
+	settings, err := loadSettings()
+	if *checkConfig {
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Configuration is invalid:\n%v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println("Configuration OK")
+		return
+	}
 	if err != nil {
-		log.Fatalf("Failed to load token configurations: %v", err)
+		log.Fatalf("Invalid configuration:\n%v", err)
 	}
 
-	redisClient := redis.NewClient(&redis.Options{
-		Addr:     cfg.Redis.GetRedisAddr(),
-		Password: cfg.Redis.Password,
-		DB:       cfg.Redis.DB,
-	})
+	cfg := settings.cfg
+	tokenFile := settings.tokenFile
+	errorResponse := settings.errorResponse
+
+	redisClient := settings.redisClient
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
-	if err := redisClient.Ping(ctx).Err(); err != nil {
-		log.Fatalf("Failed to connect to Redis: %v", err)
+
+	var storage ratelimit.StorageStrategy
+	var sharded *ratelimit.ShardedStrategy
+	var gossip *ratelimit.GossipStrategy
+	switch cfg.RateLimit.Storage {
+	case config.StorageGossip:
+		// Contadores em memória trocados com os peers; não usa o Redis
+		gossip = ratelimit.NewGossipStrategy(
+			ratelimit.WithGossipNodeID(cfg.Gossip.NodeID),
+			ratelimit.WithGossipPeers(cfg.Gossip.Peers...),
+			ratelimit.WithGossipInterval(cfg.Gossip.Interval),
+			ratelimit.WithGossipSecret(cfg.Gossip.Secret),
+			ratelimit.WithGossipMaxBlock(cfg.RateLimit.GetBlockDuration()),
+		)
+		storage = gossip
+	case config.StorageBolt:
+		// Arquivo local: um único nó, com bloqueios mantidos entre reinícios
+		bolt, err := ratelimit.NewBoltStrategy(cfg.Bolt.Path,
+			ratelimit.WithBoltCompactInterval(cfg.Bolt.CompactInterval),
+			ratelimit.WithBoltNoSync(cfg.Bolt.NoSync),
+		)
+		if err != nil {
+			log.Fatalf("Failed to open bolt storage: %v", err)
+		}
+		log.Printf("Bolt storage: %s", cfg.Bolt.Path)
+		storage = bolt
+	default:
+		var counters ratelimit.BatchStorage
+		if cfg.Redis.Mode == config.RedisModeSharded {
+			// Basta um shard no ar; os demais voltam ao hash pelo health check
+			sharded = ratelimit.NewShardedStrategy(settings.redisShards, ratelimit.WithShardHealthInterval(cfg.Redis.ShardHealthInterval))
+			sharded.CheckHealth(ctx)
+			if len(sharded.Healthy()) == 0 {
+				log.Fatalf("Failed to connect to Redis: no shard is reachable")
+			}
+			log.Printf("Redis shards available: %s", strings.Join(sharded.Healthy(), ", "))
+			counters = sharded
+		} else {
+			if err := redisClient.Ping(ctx).Err(); err != nil {
+				log.Fatalf("Failed to connect to Redis: %v", err)
+			}
+			counters = ratelimit.NewRedisStrategy(redisClient)
+		}
+
+		storage = counters
+		if cfg.Batch.Enabled {
+			storage = ratelimit.NewBatchStrategy(counters,
+				ratelimit.WithBatchSyncInterval(cfg.Batch.SyncInterval),
+				ratelimit.WithBatchLeaseSize(cfg.Batch.LeaseSize),
+			)
+		}
+	}
+	if cfg.BlockCache.Enabled {
+		cacheOpts := []ratelimit.BlockCacheOption{ratelimit.WithBlockCacheMaxEntries(cfg.BlockCache.MaxEntries)}
+		// Sem Redis não há outras instâncias a avisar dos resets
+		switch {
+		case sharded != nil:
+			cacheOpts = append(cacheOpts, ratelimit.WithBlockInvalidator(ratelimit.NewShardedBlockInvalidator(sharded, cfg.BlockCache.Channel)))
+		case redisClient != nil:
+			cacheOpts = append(cacheOpts, ratelimit.WithBlockInvalidator(ratelimit.NewRedisBlockInvalidator(redisClient, cfg.BlockCache.Channel)))
+		}
+		storage = ratelimit.NewBlockCacheStrategy(storage, cacheOpts...)
+	}
+
+	limiterOpts := []ratelimit.Option{
+		ratelimit.WithStorage(storage),
+		ratelimit.WithLimits(ratelimit.Config(cfg.RateLimit)),
+		ratelimit.WithTokens(tokensOf(tokenFile.Tokens)),
+		ratelimit.WithPlans(plansOf(tokenFile.Plans)),
+		ratelimit.WithTokenHasher(settings.tokenHasher),
+		ratelimit.WithRules(rulesOf(settings.rules)),
+		ratelimit.WithHeaders(ratelimit.HeaderConfig(cfg.Headers)),
+		ratelimit.WithErrorResponse(errorResponse),
+	}
+	if proxies := cfg.RateLimit.TrustedProxies; len(proxies) > 0 {
+		limiterOpts = append(limiterOpts, ratelimit.WithTrustedProxies(proxies...))
+	}
+	if cfg.Concurrency.Limit > 0 {
+		// Sem Redis os slots ficam na memória de cada instância
+		var slots ratelimit.ConcurrencyStrategy = ratelimit.NewMemoryConcurrencyStrategy()
+		switch {
+		case sharded != nil:
+			// Cada slot fica no shard da chave, como os contadores
+			slots = sharded
+		case redisClient != nil:
+			slots = ratelimit.NewRedisConcurrencyStrategy(redisClient)
+		}
+		limiterOpts = append(limiterOpts,
+			ratelimit.WithConcurrency(slots, cfg.Concurrency.LeaseTTL),
+			ratelimit.WithConcurrencyLimit(cfg.Concurrency.Limit),
+		)
 	}
+	if cfg.Wait.MaxWait > 0 {
+		limiterOpts = append(limiterOpts, ratelimit.WithWait(cfg.Wait.MaxWait, cfg.Wait.MaxWaiters))
+	}
+	if policy := cfg.Policy; policy != nil {
+		limiterOpts = append(limiterOpts, ratelimit.WithRouteRules(routeRulesOf(policy.Routes)))
+		if policy.Access != nil {
+			limiterOpts = append(limiterOpts, ratelimit.WithAccessList(ratelimit.AccessList(*policy.Access)))
+		}
+	}
+	identity := settings.identity
 
-	storageStrategy := limiter.NewRedisStrategy(redisClient)
-	rateLimiter := limiter.NewRateLimiter(storageStrategy, &cfg.RateLimit, tokenConfigs)
+	// Com JWT habilitado o bearer token tem prioridade sobre a identificação configurada
+	if settings.jwtVerifier != nil {
+		identity = ratelimit.JWTIdentity(settings.jwtVerifier, ratelimit.JWTClaims{
+			Key:   cfg.JWT.KeyClaim,
+			Plan:  cfg.JWT.PlanClaim,
+			Limit: cfg.JWT.LimitClaim,
+		}, identity)
+	}
+	limiterOpts = append(limiterOpts, ratelimit.WithIdentityFunc(identity))
+
+	rl, err := ratelimit.New(limiterOpts...)
+	if err != nil {
+		log.Fatalf("Failed to create rate limiter: %v", err)
+	}
+	rateLimiter := rl.RateLimiter
 
 	healthHandler := handler.NewHealthHandler()
 
-	router := setupRouter(rateLimiter, healthHandler)
+	// Modo gateway: encaminha as rotas configuradas para os upstreams
+	healthCtx, stopHealthChecks := context.WithCancel(context.Background())
+	defer stopHealthChecks()
+
+	var gateway *proxy.Gateway
+	if cfg.Proxy.Enabled {
+		gateway, err = proxy.NewGateway(settings.routes, rl.RuleMiddleware, cfg.Headers)
+		if err != nil {
+			log.Fatalf("Failed to create proxy gateway: %v", err)
+		}
+		gateway.StartHealthChecks(healthCtx, cfg.Proxy.GetHealthInterval())
+	}
+
+	router := setupRouter(cfg, rl, healthHandler, gateway)
 
 	server := &http.Server{
 		Addr:         ":" + cfg.Server.Port,
 		Handler:      router,
-		ReadTimeout:  15 * time.Second,
-		WriteTimeout: 15 * time.Second,
+		ReadTimeout:  config.ServerWriteTimeout,
+		WriteTimeout: config.ServerWriteTimeout,
 		IdleTimeout:  60 * time.Second,
 	}
 
@@ -80,12 +222,94 @@ func main() {
 		log.Printf("Environment: %s", cfg.Server.AppEnv)
 		log.Printf("Swagger UI: http://localhost:%s/swagger", cfg.Server.Port)
 		log.Printf("Health Check: http://localhost:%s/health", cfg.Server.Port)
+		if cfg.Policy != nil {
+			log.Printf("Rate limit policy loaded from %s", cfg.PolicyFile)
+		}
+		if cfg.Proxy.Enabled {
+			log.Printf("Proxy gateway enabled with routes from %s", cfg.Proxy.RoutesFile)
+		}
+		if cfg.Server.DecisionAPIEnabled {
+			log.Printf("Decision API: http://localhost:%s/v1/check", cfg.Server.Port)
+		}
+		if cfg.RateLimit.DryRun {
+			log.Printf("Rate limit dry-run enabled: requests over the limit are logged but not rejected")
+		}
 
 		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 			log.Fatalf("Server failed to start: %v", err)
 		}
 	}()
 
+	// Servidor gRPC compatível com o RateLimitService do Envoy
+	var grpcServer *grpc.Server
+	if cfg.Envoy.Enabled {
+		grpcServer = grpc.NewServer()
+		rls.NewServer(rateLimiter, cfg.Envoy, cfg.Headers).Register(grpcServer)
+
+		listener, err := net.Listen("tcp", cfg.Envoy.GetAddr())
+		if err != nil {
+			log.Fatalf("Failed to listen for Envoy rate limit service: %v", err)
+		}
+
+		go func() {
+			log.Printf("Envoy rate limit service (gRPC) listening on %s", cfg.Envoy.GetAddr())
+			if err := grpcServer.Serve(listener); err != nil {
+				log.Fatalf("Envoy rate limit service failed: %v", err)
+			}
+		}()
+	}
+
+	// Endpoint que recebe os deltas dos peers do gossip
+	var gossipServer *http.Server
+	if gossip != nil {
+		mux := http.NewServeMux()
+		mux.Handle(ratelimit.GossipPath, gossip)
+		gossipServer = &http.Server{
+			Addr:         cfg.Gossip.BindAddr,
+			Handler:      mux,
+			ReadTimeout:  5 * time.Second,
+			WriteTimeout: 5 * time.Second,
+		}
+
+		go func() {
+			log.Printf("Gossip storage: node %s listening on %s, peers: %s", cfg.Gossip.NodeID, cfg.Gossip.BindAddr, strings.Join(cfg.Gossip.Peers, ", "))
+			if err := gossipServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				log.Fatalf("Gossip listener failed: %v", err)
+			}
+		}()
+	}
+
+	// Métricas (expvar) ficam fora do router público
+	var adminServer *http.Server
+	if cfg.Server.AdminAddr != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/debug/vars", expvar.Handler())
+		adminServer = &http.Server{
+			Addr:         cfg.Server.AdminAddr,
+			Handler:      mux,
+			ReadTimeout:  5 * time.Second,
+			WriteTimeout: 5 * time.Second,
+		}
+
+		go func() {
+			log.Printf("Admin listener (/debug/vars) on %s", cfg.Server.AdminAddr)
+			if err := adminServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				log.Fatalf("Admin listener failed: %v", err)
+			}
+		}()
+	}
+
+	// SIGHUP recarrega a lista de nós do modo sharded sem reiniciar
+	if sharded != nil {
+		reload := make(chan os.Signal, 1)
+		signal.Notify(reload, syscall.SIGHUP)
+		go func() {
+			for range reload {
+				reloadShards(sharded)
+			}
+		}()
+	}
+
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
@@ -99,14 +323,147 @@ func main() {
 		log.Fatalf("Server forced to shutdown: %v", err)
 	}
 
-	if err := storageStrategy.Close(); err != nil {
+	if grpcServer != nil {
+		grpcServer.GracefulStop()
+	}
+
+	if gossipServer != nil {
+		if err := gossipServer.Shutdown(shutdownCtx); err != nil {
+			log.Printf("Error stopping gossip listener: %v", err)
+		}
+	}
+
+	if adminServer != nil {
+		if err := adminServer.Shutdown(shutdownCtx); err != nil {
+			log.Printf("Error stopping admin listener: %v", err)
+		}
+	}
+
+	if err := rl.Close(); err != nil {
 		log.Printf("Error closing Redis connection: %v", err)
 	}
 
 	log.Println("Server exited")
 }
 
-func setupRouter(rateLimiter *limiter.RateLimiter, healthHandler *handler.HealthHandler) *chi.Mux {
+// Relê a configuração e aplica a nova lista de shards; apenas as chaves dos nós
+// adicionados ou removidos mudam de lugar
+func reloadShards(sharded *ratelimit.ShardedStrategy) {
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		log.Printf("Shard reload ignored, invalid configuration: %v", err)
+		return
+	}
+	if cfg.Redis.Mode != config.RedisModeSharded {
+		log.Printf("Shard reload ignored: REDIS_MODE changed to %q, restart to apply", cfg.Redis.Mode)
+		return
+	}
+
+	shards, err := ratelimit.NewRedisShards(redisOf(cfg.Redis))
+	if err != nil {
+		log.Printf("Shard reload failed: %v", err)
+		return
+	}
+	sharded.SetShards(shards)
+	log.Printf("Redis shards reloaded: %d configured, available: %s", len(shards), strings.Join(sharded.Healthy(), ", "))
+}
+
+// Configuração e arquivos carregados na inicialização
+type settings struct {
+	cfg           *config.Config
+	tokenFile     *config.TokenFile
+	tokenHasher   config.TokenHasher
+	rules         config.RuleConfigs
+	routes        []config.RouteConfig
+	errorResponse response.RateLimitErrorOptions
+	identity      ratelimit.IdentityFunc
+	jwtVerifier   *jwtauth.Verifier
+	// O cliente só conecta no primeiro comando; criá-lo valida os arquivos de TLS
+	redisClient redis.UniversalClient
+	// Nós do modo sharded
+	redisShards []ratelimit.Shard
+}
+
+// Carrega e valida toda a configuração, reunindo os problemas de todos os
+// arquivos em vez de parar no primeiro
+func loadSettings() (*settings, error) {
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		return nil, fmt.Errorf("environment: %w", err)
+	}
+
+	s := &settings{cfg: cfg, identity: ratelimit.IdentityFromKey(ratelimit.DefaultKeyFunc)}
+	var errs []error
+
+	// Tokens e regras definidos na política substituem os arquivos separados
+	if cfg.Policy != nil && cfg.Policy.TokenFile() != nil {
+		s.tokenFile = cfg.Policy.TokenFile()
+	} else if s.tokenFile, err = config.LoadTokenFile(cfg.Tokens.File); err != nil {
+		errs = append(errs, fmt.Errorf("%s: %w", cfg.Tokens.File, err))
+	}
+
+	// As regras nomeadas são opcionais
+	if cfg.Policy != nil && cfg.Policy.Rules != nil {
+		s.rules = cfg.Policy.Rules
+	} else if s.rules, err = config.LoadRuleConfigs(cfg.Rules.File); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		errs = append(errs, fmt.Errorf("%s: %w", cfg.Rules.File, err))
+	}
+
+	if s.tokenHasher, err = config.NewTokenHasher(cfg.Tokens.HashMode, cfg.Tokens.HashSecret); err != nil {
+		errs = append(errs, fmt.Errorf("TOKEN_HASH_MODE: %w", err))
+	}
+
+	if s.errorResponse, err = loadErrorResponseOptions(&cfg.Response); err != nil {
+		errs = append(errs, fmt.Errorf("%s: %w", cfg.Response.TemplatesFile, err))
+	}
+
+	if cfg.RateLimit.Key != "" {
+		if s.identity, err = ratelimit.ParseKeyFunc(cfg.RateLimit.Key); err != nil {
+			errs = append(errs, fmt.Errorf("RATE_LIMIT_KEY: %w", err))
+		}
+	}
+
+	// No modo sharded tudo passa pelos shards, inclusive slots e pub/sub
+	switch {
+	case cfg.RateLimit.UseRedis() && cfg.Redis.Mode == config.RedisModeSharded:
+		if s.redisShards, err = ratelimit.NewRedisShards(redisOf(cfg.Redis)); err != nil {
+			errs = append(errs, fmt.Errorf("redis: %w", err))
+		}
+	case cfg.RateLimit.UseRedis():
+		if s.redisClient, err = ratelimit.NewRedisClient(redisOf(cfg.Redis)); err != nil {
+			errs = append(errs, fmt.Errorf("redis: %w", err))
+		}
+	}
+
+	if cfg.JWT.Enabled {
+		if s.jwtVerifier, err = jwtauth.NewVerifierFromConfig(cfg.JWT); err != nil {
+			errs = append(errs, fmt.Errorf("JWT: %w", err))
+		}
+	}
+
+	if cfg.Proxy.Enabled {
+		if s.routes, err = config.LoadRouteConfigs(cfg.Proxy.RoutesFile); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", cfg.Proxy.RoutesFile, err))
+		}
+	}
+
+	// As rotas podem referenciar regras do rules.json: a verificação usa as
+	// regras finais, para que uma regra inexistente impeça a inicialização
+	if cfg.Policy != nil && cfg.Policy.Rules == nil {
+		if err := cfg.Policy.Routes.CheckRules(s.rules); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", cfg.PolicyFile, err))
+		}
+	}
+	if s.routes != nil {
+		if err := config.CheckRouteConfigRules(s.routes, s.rules); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", cfg.Proxy.RoutesFile, err))
+		}
+	}
+
+	return s, errors.Join(errs...)
+}
+
+func setupRouter(cfg *config.Config, rl *ratelimit.Limiter, healthHandler *handler.HealthHandler, gateway *proxy.Gateway) *chi.Mux {
 	router := chi.NewRouter()
 
 	router.Use(middleware.Logger)
@@ -117,7 +474,7 @@ func setupRouter(rateLimiter *limiter.RateLimiter, healthHandler *handler.Health
 		AllowedOrigins:   []string{"*"},
 		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
 		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token", "API_KEY"},
-		ExposedHeaders:   []string{"Link"},
+		ExposedHeaders:   exposedHeaders(cfg.Headers),
 		AllowCredentials: false,
 		MaxAge:           300,
 	}))
@@ -128,10 +485,62 @@ func setupRouter(rateLimiter *limiter.RateLimiter, healthHandler *handler.Health
 
 	router.Get("/health", healthHandler.Health)
 
+	// API de decisão para serviços que não embutem o middleware
+	if cfg.Server.DecisionAPIEnabled {
+		checkHandler := handler.NewCheckHandler(rl.RateLimiter, cfg.Server.DecisionAPISecret)
+		router.Post("/v1/check", checkHandler.Check)
+		router.Post("/v1/check/batch", checkHandler.CheckBatch)
+	}
+
+	if gateway != nil {
+		gateway.Mount(router)
+	}
+
 	router.Route("/api/v1", func(r chi.Router) {
-		r.Use(ratelimitMiddleware.RateLimitMiddleware(rateLimiter))
+		r.Use(rl.Middleware)
 		r.Get("/resource", healthHandler.Resource)
 	})
 
 	return router
 }
+
+// Headers expostos via CORS, incluindo os nomes configurados de rate limit
+func exposedHeaders(headers config.HeaderConfig) []string {
+	return []string{
+		"Link",
+		"Retry-After",
+		headers.LimitHeader,
+		headers.RemainingHeader,
+		headers.ResetHeader,
+		headers.PolicyHeader,
+		headers.RateLimitHeader,
+	}
+}
+
+// Monta as opções da resposta 429. O arquivo de templates é opcional.
+func loadErrorResponseOptions(cfg *config.ResponseConfig) (response.RateLimitErrorOptions, error) {
+	opts := response.RateLimitErrorOptions{
+		ProblemJSON: cfg.UseProblemJSON(),
+		ProblemType: cfg.ProblemType,
+	}
+
+	templateConfigs, err := config.LoadResponseTemplates(cfg.TemplatesFile)
+	if errors.Is(err, fs.ErrNotExist) {
+		return opts, nil
+	}
+	if err != nil {
+		return opts, err
+	}
+
+	sets := make(map[string]response.TemplateSet, len(templateConfigs))
+	for route, tpl := range templateConfigs {
+		sets[route] = response.TemplateSet{JSON: tpl.JSON, Text: tpl.Text, HTML: tpl.HTML}
+	}
+
+	templates, err := response.NewTemplates(sets)
+	if err != nil {
+		return opts, err
+	}
+	opts.Templates = templates
+	return opts, nil
+}
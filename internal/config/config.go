@@ -1,7 +1,13 @@
 package config
 
 import (
+	"errors"
 	"fmt"
+	"io/fs"
+	"net"
+	"net/netip"
+	"os"
+	"strings"
 	"time"
 
 	"github.com/spf13/viper"
@@ -11,24 +17,301 @@ type Config struct {
 	Server    ServerConfig    `mapstructure:"server"`
 	RateLimit RateLimitConfig `mapstructure:"rate_limit"`
 	Redis     RedisConfig     `mapstructure:"redis"`
+	Headers   HeaderConfig    `mapstructure:"headers"`
+	Response  ResponseConfig  `mapstructure:"response"`
+	Envoy     EnvoyConfig     `mapstructure:"envoy"`
+	Proxy     ProxyConfig     `mapstructure:"proxy"`
+	JWT       JWTConfig       `mapstructure:"jwt"`
+	Tokens    TokensConfig    `mapstructure:"tokens"`
+	Rules     RulesConfig     `mapstructure:"rules"`
+	// BlockCache mantém em memória os bloqueios conhecidos
+	BlockCache BlockCacheConfig `mapstructure:"block_cache"`
+	// Batch habilita o modo aproximado com saldo local e envio em lote
+	Batch BatchConfig `mapstructure:"batch"`
+	// Gossip configura o storage em memória distribuído entre as instâncias
+	Gossip GossipConfig `mapstructure:"gossip"`
+	// Bolt configura o storage persistente em arquivo de um único nó
+	Bolt BoltConfig `mapstructure:"bolt"`
+	// Concurrency limita as requisições simultâneas por cliente
+	Concurrency ConcurrencyConfig `mapstructure:"concurrency"`
+	// Wait segura as requisições limitadas em vez de responder 429 de imediato
+	Wait WaitConfig `mapstructure:"wait"`
+
+	// PolicyFile é o arquivo de política (YAML ou JSON); Policy é nil quando ele não existe
+	PolicyFile string  `mapstructure:"-"`
+	Policy     *Policy `mapstructure:"-"`
 }
 
 type ServerConfig struct {
-	Port   string `mapstructure:"port"`
-	AppEnv string `mapstructure:"app_env"`
+	Port               string `mapstructure:"port"`
+	AppEnv             string `mapstructure:"app_env"`
+	DecisionAPIEnabled bool   `mapstructure:"decision_api_enabled"`
+	// Segredo exigido no header Authorization da API de decisão
+	DecisionAPISecret string `mapstructure:"decision_api_secret"`
+	// Endereço do listener administrativo (/debug/vars); vazio desabilita
+	AdminAddr string `mapstructure:"admin_addr"`
 }
 
 type RateLimitConfig struct {
-	IPLimit              int `mapstructure:"ip_limit"`
-	WindowSeconds        int `mapstructure:"window_seconds"`
-	BlockDurationSeconds int `mapstructure:"block_duration_seconds"`
+	IPLimit              int  `mapstructure:"ip_limit"`
+	WindowSeconds        int  `mapstructure:"window_seconds"`
+	BlockDurationSeconds int  `mapstructure:"block_duration_seconds"`
+	DryRun               bool `mapstructure:"dry_run"`
+	// Key define como o cliente é identificado (ex.: "ip+route"); vazio usa API_KEY ou IP
+	Key string `mapstructure:"key"`
+	// KeyPrefix e Tenant formam o namespace das chaves no storage
+	// ("p=<prefixo>:t=<tenant>:ip:..."), isolando ambientes e serviços no mesmo Redis
+	KeyPrefix string `mapstructure:"key_prefix"`
+	Tenant    string `mapstructure:"tenant"`
+	// Storage é redis (padrão), gossip (em memória, sem Redis) ou bolt (arquivo local)
+	Storage string `mapstructure:"storage"`
+	// TrustedProxies são os IPs/CIDRs dos proxies cujo X-Forwarded-For é
+	// aceito no escopo dos tokens e nas listas de acesso
+	TrustedProxies []string `mapstructure:"trusted_proxies"`
+}
+
+// Storages dos contadores
+const (
+	StorageRedis  = "redis"
+	StorageGossip = "gossip"
+	StorageBolt   = "bolt"
+)
+
+// Indica se os contadores ficam em memória, trocados entre as instâncias por gossip
+func (c *RateLimitConfig) UseGossip() bool {
+	return c.Storage == StorageGossip
 }
 
+// Indica se os contadores ficam no Redis (padrão)
+func (c *RateLimitConfig) UseRedis() bool {
+	return c.Storage == "" || c.Storage == StorageRedis
+}
+
+// Indica se o valor pode ser usado como segmento de chave: sem ":" e sem
+// caracteres de padrão do Redis (*, ?, [, ], \) ou de hash tag ({, })
+func ValidKeySegment(segment string) bool {
+	return !strings.ContainsAny(segment, ":*?[]\\{}")
+}
+
+// Modos de conexão com o Redis
+const (
+	RedisModeStandalone = "standalone"
+	RedisModeSentinel   = "sentinel"
+	RedisModeCluster    = "cluster"
+	RedisModeSharded    = "sharded"
+)
+
 type RedisConfig struct {
 	Host     string `mapstructure:"host"`
 	Port     string `mapstructure:"port"`
 	Password string `mapstructure:"password"`
 	DB       int    `mapstructure:"db"`
+	// Mode é standalone (Host/Port), sentinel, cluster ou sharded
+	Mode string `mapstructure:"mode"`
+	// Addrs são os sentinels (modo sentinel), os nós semente (modo cluster) ou
+	// os nós independentes no formato [nome=]host:porta (modo sharded)
+	Addrs            []string `mapstructure:"addrs"`
+	MasterName       string   `mapstructure:"master_name"`
+	SentinelPassword string   `mapstructure:"sentinel_password"`
+
+	// Username do ACL (Redis 6+); vazio usa o usuário default
+	Username string `mapstructure:"username"`
+	// Socket conecta por unix socket no lugar de Host/Port (modo standalone)
+	Socket string         `mapstructure:"socket"`
+	TLS    RedisTLSConfig `mapstructure:"tls"`
+
+	// Pool e timeouts; zero usa o default do go-redis
+	PoolSize     int           `mapstructure:"pool_size"`
+	MinIdleConns int           `mapstructure:"min_idle_conns"`
+	DialTimeout  time.Duration `mapstructure:"dial_timeout"`
+	ReadTimeout  time.Duration `mapstructure:"read_timeout"`
+	WriteTimeout time.Duration `mapstructure:"write_timeout"`
+	// MaxRetries -1 desabilita as novas tentativas
+	MaxRetries      int           `mapstructure:"max_retries"`
+	MinRetryBackoff time.Duration `mapstructure:"min_retry_backoff"`
+	MaxRetryBackoff time.Duration `mapstructure:"max_retry_backoff"`
+
+	// Intervalo do health check dos nós no modo sharded
+	ShardHealthInterval time.Duration `mapstructure:"shard_health_interval"`
+}
+
+// Nó do modo sharded. O nome define a posição do nó no hash: trocar o endereço
+// mantendo o nome não redistribui as chaves.
+type RedisShard struct {
+	Name string
+	Addr string
+}
+
+// Configura TLS na conexão com o Redis (Redis gerenciado, mTLS)
+type RedisTLSConfig struct {
+	Enabled bool   `mapstructure:"enabled"`
+	CAFile  string `mapstructure:"ca_file"`
+	// CertFile e KeyFile habilitam o certificado de cliente (mTLS)
+	CertFile   string `mapstructure:"cert_file"`
+	KeyFile    string `mapstructure:"key_file"`
+	ServerName string `mapstructure:"server_name"`
+	// InsecureSkipVerify desabilita a verificação do certificado (apenas desenvolvimento)
+	InsecureSkipVerify bool `mapstructure:"insecure_skip_verify"`
+}
+
+// Modos de headers de rate limit suportados
+const (
+	HeaderModeLegacy = "legacy"
+	HeaderModeIETF   = "ietf"
+	HeaderModeBoth   = "both"
+)
+
+// Formatos do header de reset no modo legado
+const (
+	ResetFormatRFC3339 = "rfc3339"
+	ResetFormatDelta   = "delta"
+	ResetFormatEpoch   = "epoch"
+)
+
+// Define quais headers de rate limit são enviados e com quais nomes
+type HeaderConfig struct {
+	Mode            string `mapstructure:"mode"`
+	ResetFormat     string `mapstructure:"reset_format"`
+	LimitHeader     string `mapstructure:"limit_header"`
+	RemainingHeader string `mapstructure:"remaining_header"`
+	ResetHeader     string `mapstructure:"reset_header"`
+	PolicyHeader    string `mapstructure:"policy_header"`
+	RateLimitHeader string `mapstructure:"ratelimit_header"`
+}
+
+// Configura o serviço gRPC compatível com o RateLimitService do Envoy
+type EnvoyConfig struct {
+	Enabled bool   `mapstructure:"enabled"`
+	Port    string `mapstructure:"port"`
+	// BindHost é a interface do listener; o serviço não tem autenticação, então
+	// o default 127.0.0.1 só aceita o Envoy rodando na mesma máquina
+	BindHost string `mapstructure:"bind_host"`
+	Domain   string `mapstructure:"domain"`
+	RuleKey  string `mapstructure:"rule_key"`
+	IPKey    string `mapstructure:"ip_key"`
+	TokenKey string `mapstructure:"token_key"`
+	// AllowLimitOverride aceita o limit enviado no descriptor pelo Envoy
+	AllowLimitOverride bool `mapstructure:"allow_limit_override"`
+}
+
+// Endereço do listener gRPC
+func (c *EnvoyConfig) GetAddr() string {
+	return net.JoinHostPort(c.BindHost, c.Port)
+}
+
+// Configura o modo gateway (reverse proxy para upstreams)
+type ProxyConfig struct {
+	Enabled               bool   `mapstructure:"enabled"`
+	RoutesFile            string `mapstructure:"routes_file"`
+	HealthIntervalSeconds int    `mapstructure:"health_interval_seconds"`
+}
+
+// Define onde ficam os tokens e como eles são armazenados (texto puro ou digest)
+type TokensConfig struct {
+	File string `mapstructure:"file"`
+	// HashMode é none, sha256 ou hmac-sha256; com hash, as chaves do tokens.json
+	// e do Redis são o digest em hex do token
+	HashMode   string `mapstructure:"hash_mode"`
+	HashSecret string `mapstructure:"hash_secret"`
+}
+
+// Define onde ficam as regras nomeadas. O arquivo é opcional: se não existir,
+// não há regras além das da política.
+type RulesConfig struct {
+	File string `mapstructure:"file"`
+}
+
+// Configura o cache em memória dos bloqueios. Os resets são propagados entre
+// as instâncias pelo canal de pub/sub do Redis.
+type BlockCacheConfig struct {
+	Enabled    bool   `mapstructure:"enabled"`
+	MaxEntries int    `mapstructure:"max_entries"`
+	Channel    string `mapstructure:"channel"`
+}
+
+// Configura o modo aproximado: cada instância concede até LeaseSize unidades
+// por chave sem consultar o Redis e envia o consumo a cada SyncInterval
+type BatchConfig struct {
+	Enabled      bool          `mapstructure:"enabled"`
+	SyncInterval time.Duration `mapstructure:"sync_interval"`
+	LeaseSize    int           `mapstructure:"lease_size"`
+}
+
+// Configura o storage por gossip: cada instância recebe os deltas dos peers em
+// BindAddr e envia os seus a cada Interval
+type GossipConfig struct {
+	NodeID   string        `mapstructure:"node_id"`
+	BindAddr string        `mapstructure:"bind_addr"`
+	Peers    []string      `mapstructure:"peers"`
+	Interval time.Duration `mapstructure:"interval"`
+	Secret   string        `mapstructure:"secret"`
+}
+
+// Configura o storage em arquivo bbolt: os bloqueios sobrevivem a reinícios e
+// as janelas expiradas são removidas a cada CompactInterval
+type BoltConfig struct {
+	Path            string        `mapstructure:"path"`
+	CompactInterval time.Duration `mapstructure:"compact_interval"`
+	// NoSync desliga o fsync das transações: mais rápido, mas uma queda do
+	// sistema operacional pode perder as gravações recentes
+	NoSync bool `mapstructure:"no_sync"`
+}
+
+// Configura o limite de requisições simultâneas por cliente (0 desabilita).
+// Cada slot expira após LeaseTTL se a instância cair sem liberá-lo.
+type ConcurrencyConfig struct {
+	Limit    int           `mapstructure:"limit"`
+	LeaseTTL time.Duration `mapstructure:"lease_ttl"`
+}
+
+// Timeout de leitura e escrita do servidor HTTP; limita RATE_LIMIT_MAX_WAIT
+const ServerWriteTimeout = 15 * time.Second
+
+// Configura a espera pelo reset do limite (MaxWait 0 desabilita). MaxWaiters
+// limita as requisições esperando ao mesmo tempo em cada instância.
+type WaitConfig struct {
+	MaxWait    time.Duration `mapstructure:"max_wait"`
+	MaxWaiters int           `mapstructure:"max_waiters"`
+}
+
+// Configura a identificação por JWT (Authorization: Bearer).
+// A chave é o HMACSecret (HS256), o PublicKeyFile ou o JWKSFile (RS256).
+type JWTConfig struct {
+	Enabled       bool   `mapstructure:"enabled"`
+	HMACSecret    string `mapstructure:"hmac_secret"`
+	PublicKeyFile string `mapstructure:"public_key_file"`
+	JWKSFile      string `mapstructure:"jwks_file"`
+	// KeyClaim identifica o cliente (ex.: sub, org_id)
+	KeyClaim string `mapstructure:"key_claim"`
+	// PlanClaim seleciona o plano do cliente; LimitClaim sobrescreve o limite
+	PlanClaim  string `mapstructure:"plan_claim"`
+	LimitClaim string `mapstructure:"limit_claim"`
+}
+
+// Formatos do corpo da resposta 429 quando o cliente aceita JSON
+const (
+	ErrorFormatJSON    = "json"
+	ErrorFormatProblem = "problem"
+)
+
+// Define o formato do corpo da resposta 429
+type ResponseConfig struct {
+	ErrorFormat   string `mapstructure:"error_format"`
+	ProblemType   string `mapstructure:"problem_type"`
+	TemplatesFile string `mapstructure:"templates_file"`
+}
+
+// Retorna a configuração de headers compatível com o comportamento original
+func DefaultHeaderConfig() HeaderConfig {
+	return HeaderConfig{
+		Mode:            HeaderModeLegacy,
+		ResetFormat:     ResetFormatRFC3339,
+		LimitHeader:     "X-RateLimit-Limit",
+		RemainingHeader: "X-RateLimit-Remaining",
+		ResetHeader:     "X-RateLimit-Reset",
+		PolicyHeader:    "RateLimit-Policy",
+		RateLimitHeader: "RateLimit",
+	}
 }
 
 // LoadConfig carrega configurações da aplicação usando viper com suporte a .env e defaults
@@ -40,13 +323,96 @@ func LoadConfig() (*Config, error) {
 
 	viper.SetDefault("SERVER_PORT", "8080")
 	viper.SetDefault("APP_ENV", "development")
+	viper.SetDefault("DECISION_API_ENABLED", false)
+	viper.SetDefault("DECISION_API_SECRET", "")
+	viper.SetDefault("ADMIN_ADDR", "")
 	viper.SetDefault("RATE_LIMIT_IP", 10)
 	viper.SetDefault("RATE_LIMIT_WINDOW_SECONDS", 1)
 	viper.SetDefault("RATE_LIMIT_BLOCK_DURATION_SECONDS", 300)
+	viper.SetDefault("RATE_LIMIT_DRY_RUN", false)
+	viper.SetDefault("RATE_LIMIT_KEY", "")
+	viper.SetDefault("RATE_LIMIT_KEY_PREFIX", "")
+	viper.SetDefault("RATE_LIMIT_TENANT", "")
+	viper.SetDefault("TRUSTED_PROXIES", "")
+	viper.SetDefault("RATE_LIMIT_STORAGE", StorageRedis)
 	viper.SetDefault("REDIS_HOST", "localhost")
 	viper.SetDefault("REDIS_PORT", "6379")
 	viper.SetDefault("REDIS_PASSWORD", "")
 	viper.SetDefault("REDIS_DB", 0)
+	viper.SetDefault("REDIS_MODE", RedisModeStandalone)
+	viper.SetDefault("REDIS_ADDRS", "")
+	viper.SetDefault("REDIS_SENTINEL_MASTER", "")
+	viper.SetDefault("REDIS_SENTINEL_PASSWORD", "")
+	viper.SetDefault("REDIS_USERNAME", "")
+	viper.SetDefault("REDIS_SOCKET", "")
+	viper.SetDefault("REDIS_TLS_ENABLED", false)
+	viper.SetDefault("REDIS_TLS_CA_FILE", "")
+	viper.SetDefault("REDIS_TLS_CERT_FILE", "")
+	viper.SetDefault("REDIS_TLS_KEY_FILE", "")
+	viper.SetDefault("REDIS_TLS_SERVER_NAME", "")
+	viper.SetDefault("REDIS_TLS_INSECURE_SKIP_VERIFY", false)
+	viper.SetDefault("REDIS_POOL_SIZE", 0)
+	viper.SetDefault("REDIS_MIN_IDLE_CONNS", 0)
+	viper.SetDefault("REDIS_DIAL_TIMEOUT", 5*time.Second)
+	viper.SetDefault("REDIS_READ_TIMEOUT", 3*time.Second)
+	viper.SetDefault("REDIS_WRITE_TIMEOUT", 3*time.Second)
+	viper.SetDefault("REDIS_MAX_RETRIES", 3)
+	viper.SetDefault("REDIS_MIN_RETRY_BACKOFF", 8*time.Millisecond)
+	viper.SetDefault("REDIS_MAX_RETRY_BACKOFF", 512*time.Millisecond)
+	viper.SetDefault("REDIS_SHARD_HEALTH_INTERVAL", time.Second)
+
+	defaultHeaders := DefaultHeaderConfig()
+	viper.SetDefault("RATE_LIMIT_HEADER_MODE", defaultHeaders.Mode)
+	viper.SetDefault("RATE_LIMIT_HEADER_RESET_FORMAT", defaultHeaders.ResetFormat)
+	viper.SetDefault("RATE_LIMIT_HEADER_LIMIT_NAME", defaultHeaders.LimitHeader)
+	viper.SetDefault("RATE_LIMIT_HEADER_REMAINING_NAME", defaultHeaders.RemainingHeader)
+	viper.SetDefault("RATE_LIMIT_HEADER_RESET_NAME", defaultHeaders.ResetHeader)
+	viper.SetDefault("RATE_LIMIT_HEADER_POLICY_NAME", defaultHeaders.PolicyHeader)
+	viper.SetDefault("RATE_LIMIT_HEADER_RATELIMIT_NAME", defaultHeaders.RateLimitHeader)
+	viper.SetDefault("RATE_LIMIT_ERROR_FORMAT", ErrorFormatJSON)
+	viper.SetDefault("RATE_LIMIT_PROBLEM_TYPE", "about:blank")
+	viper.SetDefault("RATE_LIMIT_RESPONSE_TEMPLATES", "configs/responses.json")
+	viper.SetDefault("ENVOY_RLS_ENABLED", false)
+	viper.SetDefault("ENVOY_RLS_PORT", "8081")
+	viper.SetDefault("ENVOY_RLS_BIND_HOST", "127.0.0.1")
+	viper.SetDefault("ENVOY_RLS_ALLOW_LIMIT_OVERRIDE", false)
+	viper.SetDefault("ENVOY_RLS_DOMAIN", "")
+	viper.SetDefault("ENVOY_RLS_RULE_KEY", "rule")
+	viper.SetDefault("ENVOY_RLS_IP_KEY", "remote_address")
+	viper.SetDefault("ENVOY_RLS_TOKEN_KEY", "api_key")
+	viper.SetDefault("PROXY_ENABLED", false)
+	viper.SetDefault("PROXY_ROUTES_FILE", "configs/routes.json")
+	viper.SetDefault("PROXY_HEALTH_INTERVAL_SECONDS", 10)
+	viper.SetDefault("TOKENS_FILE", "configs/tokens.json")
+	viper.SetDefault("TOKEN_HASH_MODE", TokenHashNone)
+	viper.SetDefault("RULES_FILE", "configs/rules.json")
+	viper.SetDefault("TOKEN_HASH_SECRET", "")
+	viper.SetDefault("JWT_ENABLED", false)
+	viper.SetDefault("JWT_HMAC_SECRET", "")
+	viper.SetDefault("JWT_PUBLIC_KEY_FILE", "")
+	viper.SetDefault("JWT_JWKS_FILE", "")
+	viper.SetDefault("JWT_KEY_CLAIM", "sub")
+	viper.SetDefault("JWT_PLAN_CLAIM", "")
+	viper.SetDefault("JWT_LIMIT_CLAIM", "")
+	viper.SetDefault("BLOCK_CACHE_ENABLED", false)
+	viper.SetDefault("BLOCK_CACHE_MAX_ENTRIES", 100000)
+	viper.SetDefault("BLOCK_CACHE_CHANNEL", "rate_limiter:block_cache")
+	viper.SetDefault("BATCH_ENABLED", false)
+	viper.SetDefault("BATCH_SYNC_INTERVAL", 100*time.Millisecond)
+	viper.SetDefault("BATCH_LEASE_SIZE", 10)
+	viper.SetDefault("GOSSIP_NODE_ID", defaultNodeID())
+	viper.SetDefault("GOSSIP_BIND_ADDR", "127.0.0.1:7946")
+	viper.SetDefault("GOSSIP_PEERS", "")
+	viper.SetDefault("GOSSIP_INTERVAL", 200*time.Millisecond)
+	viper.SetDefault("GOSSIP_SECRET", "")
+	viper.SetDefault("BOLT_PATH", "data/rate_limiter.db")
+	viper.SetDefault("BOLT_COMPACT_INTERVAL", time.Minute)
+	viper.SetDefault("BOLT_NO_SYNC", false)
+	viper.SetDefault("CONCURRENCY_LIMIT", 0)
+	viper.SetDefault("CONCURRENCY_LEASE_TTL", 30*time.Second)
+	viper.SetDefault("RATE_LIMIT_MAX_WAIT", 0)
+	viper.SetDefault("RATE_LIMIT_MAX_WAITERS", 1000)
+	viper.SetDefault("POLICY_FILE", "configs/policy.yaml")
 
 	viper.AutomaticEnv()
 
@@ -56,20 +422,116 @@ func LoadConfig() (*Config, error) {
 		}
 	}
 
+	// A política é opcional; seus valores viram defaults das variáveis de ambiente
+	policyFile := viper.GetString("POLICY_FILE")
+	policy, err := LoadPolicy(policyFile)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("%s: %w", policyFile, err)
+	}
+	if policy != nil {
+		policy.applyDefaults()
+	}
+
 	viper.Set("server.port", viper.GetString("SERVER_PORT"))
 	viper.Set("server.app_env", viper.GetString("APP_ENV"))
+	viper.Set("server.decision_api_enabled", viper.GetBool("DECISION_API_ENABLED"))
+	viper.Set("server.decision_api_secret", viper.GetString("DECISION_API_SECRET"))
+	viper.Set("server.admin_addr", viper.GetString("ADMIN_ADDR"))
 	viper.Set("rate_limit.ip_limit", viper.GetInt("RATE_LIMIT_IP"))
 	viper.Set("rate_limit.window_seconds", viper.GetInt("RATE_LIMIT_WINDOW_SECONDS"))
 	viper.Set("rate_limit.block_duration_seconds", viper.GetInt("RATE_LIMIT_BLOCK_DURATION_SECONDS"))
+	viper.Set("rate_limit.dry_run", viper.GetBool("RATE_LIMIT_DRY_RUN"))
+	viper.Set("rate_limit.key", viper.GetString("RATE_LIMIT_KEY"))
+	viper.Set("rate_limit.key_prefix", viper.GetString("RATE_LIMIT_KEY_PREFIX"))
+	viper.Set("rate_limit.tenant", viper.GetString("RATE_LIMIT_TENANT"))
+	viper.Set("rate_limit.trusted_proxies", splitList(viper.GetString("TRUSTED_PROXIES")))
+	viper.Set("rate_limit.storage", viper.GetString("RATE_LIMIT_STORAGE"))
 	viper.Set("redis.host", viper.GetString("REDIS_HOST"))
 	viper.Set("redis.port", viper.GetString("REDIS_PORT"))
 	viper.Set("redis.password", viper.GetString("REDIS_PASSWORD"))
 	viper.Set("redis.db", viper.GetInt("REDIS_DB"))
+	viper.Set("redis.mode", viper.GetString("REDIS_MODE"))
+	viper.Set("redis.addrs", splitList(viper.GetString("REDIS_ADDRS")))
+	viper.Set("redis.master_name", viper.GetString("REDIS_SENTINEL_MASTER"))
+	viper.Set("redis.sentinel_password", viper.GetString("REDIS_SENTINEL_PASSWORD"))
+	viper.Set("redis.username", viper.GetString("REDIS_USERNAME"))
+	viper.Set("redis.socket", viper.GetString("REDIS_SOCKET"))
+	viper.Set("redis.tls.enabled", viper.GetBool("REDIS_TLS_ENABLED"))
+	viper.Set("redis.tls.ca_file", viper.GetString("REDIS_TLS_CA_FILE"))
+	viper.Set("redis.tls.cert_file", viper.GetString("REDIS_TLS_CERT_FILE"))
+	viper.Set("redis.tls.key_file", viper.GetString("REDIS_TLS_KEY_FILE"))
+	viper.Set("redis.tls.server_name", viper.GetString("REDIS_TLS_SERVER_NAME"))
+	viper.Set("redis.tls.insecure_skip_verify", viper.GetBool("REDIS_TLS_INSECURE_SKIP_VERIFY"))
+	viper.Set("redis.pool_size", viper.GetInt("REDIS_POOL_SIZE"))
+	viper.Set("redis.min_idle_conns", viper.GetInt("REDIS_MIN_IDLE_CONNS"))
+	viper.Set("redis.dial_timeout", viper.GetDuration("REDIS_DIAL_TIMEOUT"))
+	viper.Set("redis.read_timeout", viper.GetDuration("REDIS_READ_TIMEOUT"))
+	viper.Set("redis.write_timeout", viper.GetDuration("REDIS_WRITE_TIMEOUT"))
+	viper.Set("redis.max_retries", viper.GetInt("REDIS_MAX_RETRIES"))
+	viper.Set("redis.min_retry_backoff", viper.GetDuration("REDIS_MIN_RETRY_BACKOFF"))
+	viper.Set("redis.max_retry_backoff", viper.GetDuration("REDIS_MAX_RETRY_BACKOFF"))
+	viper.Set("redis.shard_health_interval", viper.GetDuration("REDIS_SHARD_HEALTH_INTERVAL"))
+	viper.Set("headers.mode", viper.GetString("RATE_LIMIT_HEADER_MODE"))
+	viper.Set("headers.reset_format", viper.GetString("RATE_LIMIT_HEADER_RESET_FORMAT"))
+	viper.Set("headers.limit_header", viper.GetString("RATE_LIMIT_HEADER_LIMIT_NAME"))
+	viper.Set("headers.remaining_header", viper.GetString("RATE_LIMIT_HEADER_REMAINING_NAME"))
+	viper.Set("headers.reset_header", viper.GetString("RATE_LIMIT_HEADER_RESET_NAME"))
+	viper.Set("headers.policy_header", viper.GetString("RATE_LIMIT_HEADER_POLICY_NAME"))
+	viper.Set("headers.ratelimit_header", viper.GetString("RATE_LIMIT_HEADER_RATELIMIT_NAME"))
+	viper.Set("response.error_format", viper.GetString("RATE_LIMIT_ERROR_FORMAT"))
+	viper.Set("response.problem_type", viper.GetString("RATE_LIMIT_PROBLEM_TYPE"))
+	viper.Set("response.templates_file", viper.GetString("RATE_LIMIT_RESPONSE_TEMPLATES"))
+	viper.Set("envoy.enabled", viper.GetBool("ENVOY_RLS_ENABLED"))
+	viper.Set("envoy.port", viper.GetString("ENVOY_RLS_PORT"))
+	viper.Set("envoy.bind_host", viper.GetString("ENVOY_RLS_BIND_HOST"))
+	viper.Set("envoy.allow_limit_override", viper.GetBool("ENVOY_RLS_ALLOW_LIMIT_OVERRIDE"))
+	viper.Set("envoy.domain", viper.GetString("ENVOY_RLS_DOMAIN"))
+	viper.Set("envoy.rule_key", viper.GetString("ENVOY_RLS_RULE_KEY"))
+	viper.Set("envoy.ip_key", viper.GetString("ENVOY_RLS_IP_KEY"))
+	viper.Set("envoy.token_key", viper.GetString("ENVOY_RLS_TOKEN_KEY"))
+	viper.Set("proxy.enabled", viper.GetBool("PROXY_ENABLED"))
+	viper.Set("proxy.routes_file", viper.GetString("PROXY_ROUTES_FILE"))
+	viper.Set("proxy.health_interval_seconds", viper.GetInt("PROXY_HEALTH_INTERVAL_SECONDS"))
+	viper.Set("tokens.file", viper.GetString("TOKENS_FILE"))
+	viper.Set("tokens.hash_mode", viper.GetString("TOKEN_HASH_MODE"))
+	viper.Set("tokens.hash_secret", viper.GetString("TOKEN_HASH_SECRET"))
+	viper.Set("rules.file", viper.GetString("RULES_FILE"))
+	viper.Set("jwt.enabled", viper.GetBool("JWT_ENABLED"))
+	viper.Set("jwt.hmac_secret", viper.GetString("JWT_HMAC_SECRET"))
+	viper.Set("jwt.public_key_file", viper.GetString("JWT_PUBLIC_KEY_FILE"))
+	viper.Set("jwt.jwks_file", viper.GetString("JWT_JWKS_FILE"))
+	viper.Set("jwt.key_claim", viper.GetString("JWT_KEY_CLAIM"))
+	viper.Set("jwt.plan_claim", viper.GetString("JWT_PLAN_CLAIM"))
+	viper.Set("jwt.limit_claim", viper.GetString("JWT_LIMIT_CLAIM"))
+	viper.Set("block_cache.enabled", viper.GetBool("BLOCK_CACHE_ENABLED"))
+	viper.Set("block_cache.max_entries", viper.GetInt("BLOCK_CACHE_MAX_ENTRIES"))
+	viper.Set("block_cache.channel", viper.GetString("BLOCK_CACHE_CHANNEL"))
+	viper.Set("batch.enabled", viper.GetBool("BATCH_ENABLED"))
+	viper.Set("batch.sync_interval", viper.GetDuration("BATCH_SYNC_INTERVAL"))
+	viper.Set("batch.lease_size", viper.GetInt("BATCH_LEASE_SIZE"))
+	viper.Set("gossip.node_id", viper.GetString("GOSSIP_NODE_ID"))
+	viper.Set("gossip.bind_addr", viper.GetString("GOSSIP_BIND_ADDR"))
+	viper.Set("gossip.peers", splitList(viper.GetString("GOSSIP_PEERS")))
+	viper.Set("gossip.interval", viper.GetDuration("GOSSIP_INTERVAL"))
+	viper.Set("gossip.secret", viper.GetString("GOSSIP_SECRET"))
+	viper.Set("bolt.path", viper.GetString("BOLT_PATH"))
+	viper.Set("bolt.compact_interval", viper.GetDuration("BOLT_COMPACT_INTERVAL"))
+	viper.Set("bolt.no_sync", viper.GetBool("BOLT_NO_SYNC"))
+	viper.Set("concurrency.limit", viper.GetInt("CONCURRENCY_LIMIT"))
+	viper.Set("concurrency.lease_ttl", viper.GetDuration("CONCURRENCY_LEASE_TTL"))
+	viper.Set("wait.max_wait", viper.GetDuration("RATE_LIMIT_MAX_WAIT"))
+	viper.Set("wait.max_waiters", viper.GetInt("RATE_LIMIT_MAX_WAITERS"))
 
 	var config Config
 	if err := viper.Unmarshal(&config); err != nil {
 		return nil, fmt.Errorf("error unmarshaling config: %w", err)
 	}
+	config.PolicyFile = policyFile
+	config.Policy = policy
+
+	if err := config.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
 
 	return &config, nil
 }
@@ -82,6 +544,75 @@ func (c *RateLimitConfig) GetBlockDuration() time.Duration {
 	return time.Duration(c.BlockDurationSeconds) * time.Second
 }
 
+// Indica se os headers legados X-RateLimit-* devem ser enviados
+func (c *HeaderConfig) SendLegacy() bool {
+	return c.Mode == HeaderModeLegacy || c.Mode == HeaderModeBoth
+}
+
+// Indica se os headers do draft IETF RateLimit/RateLimit-Policy devem ser enviados
+func (c *HeaderConfig) SendIETF() bool {
+	return c.Mode == HeaderModeIETF || c.Mode == HeaderModeBoth
+}
+
+func (c *ProxyConfig) GetHealthInterval() time.Duration {
+	return time.Duration(c.HealthIntervalSeconds) * time.Second
+}
+
+// Indica se o corpo da resposta 429 deve seguir a RFC 9457
+func (c *ResponseConfig) UseProblemJSON() bool {
+	return c.ErrorFormat == ErrorFormatProblem
+}
+
 func (c *RedisConfig) GetRedisAddr() string {
 	return fmt.Sprintf("%s:%s", c.Host, c.Port)
 }
+
+// Nós do modo sharded a partir de Addrs; sem nome, o endereço é o nome
+func (c *RedisConfig) Shards() []RedisShard {
+	shards := make([]RedisShard, 0, len(c.Addrs))
+	for _, entry := range c.Addrs {
+		name, addr, named := strings.Cut(entry, "=")
+		if !named {
+			addr = name
+		}
+		shards = append(shards, RedisShard{Name: strings.TrimSpace(name), Addr: strings.TrimSpace(addr)})
+	}
+	return shards
+}
+
+// Identificador padrão do nó no gossip
+func defaultNodeID() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		return "rate-limiter"
+	}
+	return hostname
+}
+
+// Separa uma lista de valores por vírgula, ignorando itens vazios
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// Converte IPs e CIDRs em prefixos; um IP sozinho vira /32 (ou /128)
+func ParseCIDRs(values []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(values))
+	for _, value := range values {
+		if addr, err := netip.ParseAddr(value); err == nil {
+			prefixes = append(prefixes, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid IP or CIDR %q", value)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
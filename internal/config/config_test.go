@@ -5,6 +5,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -38,6 +39,16 @@ func TestLoadConfig(t *testing.T) {
 	assert.Equal(t, 2*time.Second, cfg.RateLimit.GetWindowDuration())
 	assert.Equal(t, 600*time.Second, cfg.RateLimit.GetBlockDuration())
 	assert.Equal(t, "test-redis:6380", cfg.Redis.GetRedisAddr())
+	assert.Equal(t, 5*time.Second, cfg.Redis.DialTimeout)
+	assert.Equal(t, 3, cfg.Redis.MaxRetries)
+	assert.Equal(t, 512*time.Millisecond, cfg.Redis.MaxRetryBackoff)
+
+	assert.Equal(t, DefaultHeaderConfig(), cfg.Headers)
+	assert.True(t, cfg.Headers.SendLegacy())
+	assert.False(t, cfg.Headers.SendIETF())
+	assert.Equal(t, "configs/rules.json", cfg.Rules.File)
+	assert.Equal(t, "127.0.0.1:8081", cfg.Envoy.GetAddr())
+	assert.False(t, cfg.Envoy.AllowLimitOverride)
 }
 
 func TestLoadTokenConfigs(t *testing.T) {
@@ -97,3 +108,543 @@ func TestTokenConfigDurationMethods(t *testing.T) {
 	assert.Equal(t, 120*time.Second, tokenConfig.GetBlockDuration())
 }
 
+
+func TestLoadResponseTemplates(t *testing.T) {
+	dir := t.TempDir()
+
+	require.NoError(t, os.WriteFile(dir+"/429.html", []byte("<p>{{.RetryAfter}}</p>"), 0o600))
+	require.NoError(t, os.WriteFile(dir+"/responses.json", []byte(`{
+		"/api/v1/*": {
+			"json": "{\"limit\": {{.Limit}}}",
+			"html_file": "429.html"
+		}
+	}`), 0o600))
+
+	templates, err := LoadResponseTemplates(dir + "/responses.json")
+	require.NoError(t, err)
+
+	route, exists := templates["/api/v1/*"]
+	require.True(t, exists)
+	assert.Equal(t, `{"limit": {{.Limit}}}`, route.JSON)
+	assert.Equal(t, "<p>{{.RetryAfter}}</p>", route.HTML)
+	assert.Empty(t, route.Text)
+
+	_, err = LoadResponseTemplates(dir + "/missing.json")
+	assert.Error(t, err)
+}
+
+func TestLoadRuleConfigs(t *testing.T) {
+	path := t.TempDir() + "/rules.json"
+	require.NoError(t, os.WriteFile(path, []byte(`{
+		"login": {"limit": 5, "window_seconds": 60, "block_duration_seconds": 600, "dry_run": true}
+	}`), 0o600))
+
+	rules, err := LoadRuleConfigs(path)
+	require.NoError(t, err)
+
+	login, exists := rules.GetRuleConfig("login")
+	require.True(t, exists)
+	assert.Equal(t, 5, login.Limit)
+	assert.Equal(t, 60*time.Second, login.GetWindowDuration())
+	assert.Equal(t, 600*time.Second, login.GetBlockDuration())
+	assert.True(t, login.DryRun)
+
+	_, exists = rules.GetRuleConfig("missing")
+	assert.False(t, exists)
+}
+
+func TestLoadTokenFileWithPlans(t *testing.T) {
+	dir := t.TempDir()
+	path := dir + "/tokens.json"
+	require.NoError(t, os.WriteFile(path, []byte(`{
+		"plans": {
+			"std": {"limit": 100, "window_seconds": 1, "block_duration_seconds": 300},
+			"pro": {"limit": 1000, "window_seconds": 1, "block_duration_seconds": 60}
+		},
+		"tokens": {
+			"std_1": {"plan": "std"},
+			"std_2": {"plan": "std", "limit": 50, "block_duration_seconds": 600},
+			"custom": {"limit": 5, "window_seconds": 10, "block_duration_seconds": 30}
+		}
+	}`), 0o600))
+
+	tokenFile, err := LoadTokenFile(path)
+	require.NoError(t, err)
+
+	pro, exists := tokenFile.Plans.GetPlanConfig("pro")
+	require.True(t, exists)
+	assert.Equal(t, 60*time.Second, pro.GetBlockDuration())
+
+	assert.Equal(t, TokenConfig{Plan: "std", Limit: 100, WindowSeconds: 1, BlockDurationSeconds: 300}, tokenFile.Tokens["std_1"])
+	assert.Equal(t, TokenConfig{Plan: "std", Limit: 50, WindowSeconds: 1, BlockDurationSeconds: 600}, tokenFile.Tokens["std_2"])
+	assert.Equal(t, TokenConfig{Limit: 5, WindowSeconds: 10, BlockDurationSeconds: 30}, tokenFile.Tokens["custom"])
+
+	tokenConfigs, err := LoadTokenConfigs(path)
+	require.NoError(t, err)
+	assert.Len(t, tokenConfigs, 3)
+
+	invalid := map[string]string{
+		"unknown plan":         `{"plans": {}, "tokens": {"t": {"plan": "gold"}}}`,
+		"token without limits": `{"tokens": {"t": {"window_seconds": 1}}}`,
+		"invalid plan":         `{"plans": {"std": {"limit": 0, "window_seconds": 1}}, "tokens": {}}`,
+	}
+	for name, content := range invalid {
+		t.Run(name, func(t *testing.T) {
+			require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+			_, err := LoadTokenFile(path)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestTokenConfigMetadata(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	expiresAt := now.Add(time.Hour)
+	notBefore := now.Add(-time.Hour)
+
+	token := TokenConfig{
+		Limit:         10,
+		WindowSeconds: 1,
+		ExpiresAt:     &expiresAt,
+		NotBefore:     &notBefore,
+		AllowedRoutes: []string{"/api/v1/*", "/health"},
+		AllowedCIDRs:  []string{"10.0.0.0/8", "2001:db8::/32"},
+	}
+
+	assert.NoError(t, token.CheckValidity(now))
+	assert.ErrorIs(t, token.CheckValidity(expiresAt), ErrTokenExpired)
+	assert.ErrorIs(t, token.CheckValidity(notBefore.Add(-time.Second)), ErrTokenNotYetValid)
+
+	assert.NoError(t, token.CheckScope("/api/v1/resource", "10.1.2.3"))
+	assert.NoError(t, token.CheckScope("/health", "2001:db8::1"))
+	assert.NoError(t, token.CheckScope("/health", "::ffff:10.1.2.3"))
+	assert.ErrorIs(t, token.CheckScope("/api/v2/resource", "10.1.2.3"), ErrRouteNotAllowed)
+	assert.ErrorIs(t, token.CheckScope("/health", "192.168.1.1"), ErrIPNotAllowed)
+	assert.ErrorIs(t, token.CheckScope("/health", "not-an-ip"), ErrIPNotAllowed)
+
+	token.Disabled = true
+	assert.ErrorIs(t, token.CheckValidity(now), ErrTokenDisabled)
+
+	path := t.TempDir() + "/tokens.json"
+	require.NoError(t, os.WriteFile(path, []byte(`{
+		"tokens": {
+			"partner": {
+				"limit": 10,
+				"window_seconds": 1,
+				"expires_at": "2026-12-31T23:59:59Z",
+				"allowed_routes": ["/api/v1/*"],
+				"allowed_cidrs": ["10.0.0.0/8"],
+				"owner": "partner-team",
+				"description": "Integração do parceiro"
+			}
+		}
+	}`), 0o600))
+
+	tokenConfigs, err := LoadTokenConfigs(path)
+	require.NoError(t, err)
+	partner := tokenConfigs["partner"]
+	require.NotNil(t, partner.ExpiresAt)
+	assert.Equal(t, 2026, partner.ExpiresAt.Year())
+	assert.Equal(t, "partner-team", partner.Owner)
+
+	require.NoError(t, os.WriteFile(path, []byte(`{"tokens": {"t": {"limit": 1, "window_seconds": 1, "allowed_cidrs": ["10.0.0.0/99"]}}}`), 0o600))
+	_, err = LoadTokenConfigs(path)
+	assert.Error(t, err)
+}
+
+func TestTokenHasher(t *testing.T) {
+	plain, err := NewTokenHasher(TokenHashNone, "")
+	require.NoError(t, err)
+	assert.Equal(t, "std_123", plain("std_123"))
+
+	sha, err := NewTokenHasher(TokenHashSHA256, "")
+	require.NoError(t, err)
+	// echo -n abc | sha256sum
+	assert.Equal(t, "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad", sha("abc"))
+
+	hmacHasher, err := NewTokenHasher(TokenHashHMAC, "secret")
+	require.NoError(t, err)
+	assert.Len(t, hmacHasher("abc"), 64)
+	assert.NotEqual(t, sha("abc"), hmacHasher("abc"))
+
+	_, err = NewTokenHasher(TokenHashHMAC, "")
+	assert.Error(t, err)
+	_, err = NewTokenHasher("md5", "")
+	assert.Error(t, err)
+
+	fingerprint := TokenFingerprint("std_123")
+	assert.NotContains(t, fingerprint, "std_123")
+	assert.Equal(t, fingerprint, TokenFingerprint("std_123"))
+}
+
+func TestConfigValidate(t *testing.T) {
+	valid := Config{
+		Server:    ServerConfig{Port: "8080"},
+		RateLimit: RateLimitConfig{IPLimit: 10, WindowSeconds: 1, BlockDurationSeconds: 300},
+		Redis:     RedisConfig{Host: "localhost", Port: "6379"},
+		Headers:   DefaultHeaderConfig(),
+		Response:  ResponseConfig{ErrorFormat: ErrorFormatJSON},
+		Tokens:    TokensConfig{File: "configs/tokens.json", HashMode: TokenHashNone},
+		Rules:     RulesConfig{File: "configs/rules.json"},
+	}
+	require.NoError(t, valid.Validate())
+
+	invalid := valid
+	invalid.RateLimit.IPLimit = 0
+	invalid.RateLimit.WindowSeconds = 0
+	invalid.Redis.Port = "redis"
+	invalid.Tokens.HashMode = TokenHashHMAC
+	invalid.JWT.Enabled = true
+
+	err := invalid.Validate()
+	require.Error(t, err)
+
+	var errs ValidationErrors
+	require.ErrorAs(t, err, &errs)
+	paths := make([]string, 0, len(errs))
+	for _, e := range errs {
+		paths = append(paths, e.Path)
+	}
+	assert.Equal(t, []string{
+		"RATE_LIMIT_IP",
+		"RATE_LIMIT_WINDOW_SECONDS",
+		"REDIS_PORT",
+		"TOKEN_HASH_SECRET",
+		"JWT_ENABLED",
+		"JWT_KEY_CLAIM",
+	}, paths)
+
+	namespaced := valid
+	namespaced.RateLimit.KeyPrefix = "app-v2"
+	namespaced.RateLimit.Tenant = "acme"
+	require.NoError(t, namespaced.Validate())
+
+	// ":" no prefixo tornaria o namespace ambíguo com o tenant
+	namespaced.RateLimit.KeyPrefix = "app:v2"
+	assert.ErrorContains(t, namespaced.Validate(), "RATE_LIMIT_KEY_PREFIX")
+
+	namespaced.RateLimit.KeyPrefix = "app*"
+	namespaced.RateLimit.Tenant = "acme:eu"
+	err = namespaced.Validate()
+	assert.ErrorContains(t, err, "RATE_LIMIT_KEY_PREFIX")
+	assert.ErrorContains(t, err, "RATE_LIMIT_TENANT")
+
+	cached := valid
+	cached.BlockCache = BlockCacheConfig{Enabled: true}
+	err = cached.Validate()
+	assert.ErrorContains(t, err, "BLOCK_CACHE_MAX_ENTRIES")
+	assert.ErrorContains(t, err, "BLOCK_CACHE_CHANNEL")
+
+	batched := valid
+	batched.Batch = BatchConfig{Enabled: true, SyncInterval: -time.Second}
+	err = batched.Validate()
+	assert.ErrorContains(t, err, "BATCH_SYNC_INTERVAL")
+	assert.ErrorContains(t, err, "BATCH_LEASE_SIZE")
+
+	concurrent := valid
+	concurrent.Concurrency = ConcurrencyConfig{Limit: 5}
+	assert.ErrorContains(t, concurrent.Validate(), "CONCURRENCY_LEASE_TTL")
+	concurrent.Concurrency = ConcurrencyConfig{Limit: -1}
+	assert.ErrorContains(t, concurrent.Validate(), "CONCURRENCY_LIMIT")
+
+	waiting := valid
+	waiting.Wait = WaitConfig{MaxWait: 2 * time.Second}
+	assert.ErrorContains(t, waiting.Validate(), "RATE_LIMIT_MAX_WAITERS")
+	waiting.Wait = WaitConfig{MaxWait: -time.Second}
+	assert.ErrorContains(t, waiting.Validate(), "RATE_LIMIT_MAX_WAIT")
+	waiting.Wait = WaitConfig{MaxWait: ServerWriteTimeout, MaxWaiters: 10}
+	assert.ErrorContains(t, waiting.Validate(), "server write timeout")
+
+	envoy := valid
+	envoy.Envoy = EnvoyConfig{Enabled: true, Port: "8081", BindHost: "0.0.0.0"}
+	require.NoError(t, envoy.Validate())
+	envoy.Envoy.BindHost = ""
+	assert.ErrorContains(t, envoy.Validate(), "ENVOY_RLS_BIND_HOST")
+
+	proxied := valid
+	proxied.RateLimit.TrustedProxies = []string{"10.0.0.1", "192.168.0.0/16"}
+	require.NoError(t, proxied.Validate())
+	proxied.RateLimit.TrustedProxies = []string{"10.0.0.0/99"}
+	assert.ErrorContains(t, proxied.Validate(), "TRUSTED_PROXIES")
+
+	admin := valid
+	admin.Server.AdminAddr = "127.0.0.1:9090"
+	require.NoError(t, admin.Validate())
+	admin.Server.AdminAddr = "9090"
+	assert.ErrorContains(t, admin.Validate(), "ADMIN_ADDR")
+
+	decision := valid
+	decision.Server.DecisionAPIEnabled = true
+	assert.ErrorContains(t, decision.Validate(), "DECISION_API_SECRET")
+	decision.Server.DecisionAPISecret = "s3cret"
+	require.NoError(t, decision.Validate())
+}
+
+func TestLoadConfigFilesStrict(t *testing.T) {
+	dir := t.TempDir()
+
+	t.Run("Token file reports every problem", func(t *testing.T) {
+		path := dir + "/tokens.json"
+		require.NoError(t, os.WriteFile(path, []byte(`{
+			"plans": {"std": {"limit": 0, "window_seconds": 0}},
+			"tokens": {
+				"a": {"plan": "gold"},
+				"b": {"limit": 10, "window_seconds": 1, "allowed_cidrs": ["10.0.0.0/99"]}
+			}
+		}`), 0o600))
+
+		_, err := LoadTokenFile(path)
+		var errs ValidationErrors
+		require.ErrorAs(t, err, &errs)
+		assert.Len(t, errs, 4)
+		assert.Contains(t, err.Error(), `plans["std"].limit`)
+		assert.Contains(t, err.Error(), "tokens["+TokenFingerprint("a")+"].plan")
+		assert.Contains(t, err.Error(), "tokens["+TokenFingerprint("b")+"].allowed_cidrs[0]")
+		assert.NotContains(t, err.Error(), `"a"`)
+	})
+
+	t.Run("Unknown fields are rejected", func(t *testing.T) {
+		path := dir + "/tokens.json"
+		require.NoError(t, os.WriteFile(path, []byte(`{"tokens": {"t": {"limit": 1, "window_seconds": 1, "windows_seconds": 2}}}`), 0o600))
+		_, err := LoadTokenFile(path)
+		assert.ErrorContains(t, err, `unknown field "windows_seconds"`)
+
+		path = dir + "/rules.json"
+		require.NoError(t, os.WriteFile(path, []byte(`{"strict": {"limit": 1, "window_seconds": 1, "dryrun": true}}`), 0o600))
+		_, err = LoadRuleConfigs(path)
+		assert.ErrorContains(t, err, `unknown field "dryrun"`)
+	})
+
+	t.Run("Invalid rules and routes", func(t *testing.T) {
+		path := dir + "/rules.json"
+		require.NoError(t, os.WriteFile(path, []byte(`{"strict": {"limit": 5, "window_seconds": 0}}`), 0o600))
+		_, err := LoadRuleConfigs(path)
+		assert.ErrorContains(t, err, `["strict"].window_seconds: must be greater than zero`)
+
+		path = dir + "/routes.json"
+		require.NoError(t, os.WriteFile(path, []byte(`[
+			{"path": "/orders/*", "upstream": "http://orders:8080"},
+			{"path": "/orders/*", "upstream": "orders:8080"}
+		]`), 0o600))
+		_, err = LoadRouteConfigs(path)
+		var errs ValidationErrors
+		require.ErrorAs(t, err, &errs)
+		assert.Equal(t, "[1].path", errs[0].Path)
+		assert.Equal(t, "[1].upstream", errs[1].Path)
+	})
+}
+
+func TestLoadPolicy(t *testing.T) {
+	dir := t.TempDir()
+
+	yamlPath := dir + "/policy.yaml"
+	require.NoError(t, os.WriteFile(yamlPath, []byte(`
+version: 1
+defaults:
+  limit: 50
+  window_seconds: 2
+headers:
+  mode: both
+rules:
+  login: {limit: 5, window_seconds: 60, block_duration_seconds: 600}
+routes:
+  - {path: /api/v1/login, method: POST, rule: login}
+plans:
+  std: {limit: 100, window_seconds: 1, block_duration_seconds: 300}
+tokens:
+  std_1: {plan: std, expires_at: 2026-12-31T23:59:59Z}
+access:
+  allow: [10.0.0.0/8]
+`), 0o600))
+
+	policy, err := LoadPolicy(yamlPath)
+	require.NoError(t, err)
+	assert.Equal(t, 50, policy.Defaults.Limit)
+	assert.Equal(t, TokenConfig{Plan: "std", Limit: 100, WindowSeconds: 1, BlockDurationSeconds: 300, ExpiresAt: policy.Tokens["std_1"].ExpiresAt}, policy.Tokens["std_1"])
+	require.NotNil(t, policy.Tokens["std_1"].ExpiresAt)
+	assert.Equal(t, 2026, policy.Tokens["std_1"].ExpiresAt.Year())
+
+	rule, matched := policy.Routes.Match("post", "/api/v1/login")
+	assert.True(t, matched)
+	assert.Equal(t, "login", rule)
+	_, matched = policy.Routes.Match("GET", "/api/v1/login")
+	assert.False(t, matched)
+
+	t.Run("Env vars override the policy", func(t *testing.T) {
+		t.Cleanup(viper.Reset)
+		t.Setenv("POLICY_FILE", yamlPath)
+		// t.Setenv restaura ao final o RATE_LIMIT_IP definido por TestLoadConfig
+		t.Setenv("RATE_LIMIT_IP", "")
+		t.Setenv("RATE_LIMIT_WINDOW_SECONDS", "3")
+		require.NoError(t, os.Unsetenv("RATE_LIMIT_IP"))
+
+		cfg, err := LoadConfig()
+		require.NoError(t, err)
+		require.NotNil(t, cfg.Policy)
+		assert.Equal(t, yamlPath, cfg.PolicyFile)
+		assert.Equal(t, 50, cfg.RateLimit.IPLimit)
+		assert.Equal(t, 3, cfg.RateLimit.WindowSeconds)
+		assert.Equal(t, HeaderModeBoth, cfg.Headers.Mode)
+	})
+
+	t.Run("JSON with every problem reported", func(t *testing.T) {
+		jsonPath := dir + "/policy.json"
+		require.NoError(t, os.WriteFile(jsonPath, []byte(`{
+			"version": 2,
+			"algorithm": "token_bucket",
+			"routes": [{"path": "login", "rule": "missing"}],
+			"rules": {},
+			"access": {"deny": ["nope"]}
+		}`), 0o600))
+
+		_, err := LoadPolicy(jsonPath)
+		var errs ValidationErrors
+		require.ErrorAs(t, err, &errs)
+		paths := make([]string, 0, len(errs))
+		for _, e := range errs {
+			paths = append(paths, e.Path)
+		}
+		assert.Equal(t, []string{"version", "algorithm", "routes[0].path", "routes[0].rule", "access.deny[0]"}, paths)
+	})
+
+	t.Run("Route rules are checked against the loaded rules", func(t *testing.T) {
+		routes := RouteRules{{Path: "/login", Rule: "login"}, {Path: "/search", Rule: "serach"}}
+		rules := RuleConfigs{"login": RuleConfig{Limit: 5, WindowSeconds: 60}}
+
+		err := routes.CheckRules(rules)
+		var errs ValidationErrors
+		require.ErrorAs(t, err, &errs)
+		require.Len(t, errs, 1)
+		assert.Equal(t, "routes[1].rule", errs[0].Path)
+		assert.ErrorContains(t, routes.CheckRules(nil), "routes[0].rule")
+
+		gateway := []RouteConfig{{Path: "/orders/*", Rule: "login"}, {Path: "/files/*"}, {Path: "/x/*", Rule: "missing"}}
+		assert.EqualError(t, CheckRouteConfigRules(gateway, rules), `[2].rule: unknown rule "missing"`)
+	})
+
+	t.Run("Unknown YAML fields are rejected", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(yamlPath, []byte("version: 1\ndefault:\n  limit: 5\n"), 0o600))
+		_, err := LoadPolicy(yamlPath)
+		assert.ErrorContains(t, err, `unknown field "default"`)
+	})
+}
+
+func TestPolicySchemaUpToDate(t *testing.T) {
+	schema, err := PolicySchema()
+	require.NoError(t, err)
+
+	committed, err := os.ReadFile("../../configs/policy.schema.json")
+	require.NoError(t, err)
+	assert.Equal(t, string(committed), string(schema), "run go generate ./internal/config")
+	assert.Contains(t, string(schema), `"required": [
+    "version"
+  ]`)
+}
+
+func TestConfigValidateRedisModes(t *testing.T) {
+	base := Config{
+		Server:    ServerConfig{Port: "8080"},
+		RateLimit: RateLimitConfig{IPLimit: 10, WindowSeconds: 1},
+		Headers:   DefaultHeaderConfig(),
+		Response:  ResponseConfig{ErrorFormat: ErrorFormatJSON},
+		Tokens:    TokensConfig{File: "configs/tokens.json"},
+		Rules:     RulesConfig{File: "configs/rules.json"},
+	}
+
+	cluster := base
+	cluster.Redis = RedisConfig{Mode: RedisModeCluster, Addrs: []string{"node-1:6379"}}
+	assert.NoError(t, cluster.Validate())
+
+	cluster.Redis.DB = 1
+	assert.ErrorContains(t, cluster.Validate(), "REDIS_DB: must be 0 in cluster mode")
+
+	sharded := base
+	sharded.Redis = RedisConfig{Mode: RedisModeSharded, Addrs: []string{"a=redis-1:6379", "redis-2:6379"}, ShardHealthInterval: time.Second}
+	assert.NoError(t, sharded.Validate())
+	assert.Equal(t, []RedisShard{{Name: "a", Addr: "redis-1:6379"}, {Name: "redis-2:6379", Addr: "redis-2:6379"}}, sharded.Redis.Shards())
+
+	sharded.Redis.Addrs = []string{"a=redis-1:6379", "a=redis-3:6379", "b="}
+	err := sharded.Validate()
+	assert.ErrorContains(t, err, `REDIS_ADDRS[1]: duplicate shard "a"`)
+	assert.ErrorContains(t, err, "REDIS_ADDRS[2]")
+
+	sentinel := base
+	sentinel.Redis = RedisConfig{Mode: RedisModeSentinel}
+	err = sentinel.Validate()
+	assert.ErrorContains(t, err, "REDIS_SENTINEL_MASTER")
+	assert.ErrorContains(t, err, "REDIS_ADDRS")
+
+	assert.Equal(t, []string{"a:26379", "b:26379"}, splitList(" a:26379, ,b:26379 "))
+
+	socket := base
+	socket.Redis = RedisConfig{Socket: "/var/run/redis.sock"}
+	assert.NoError(t, socket.Validate())
+
+	connection := base
+	connection.Server.AppEnv = "production"
+	connection.Redis = RedisConfig{
+		Host:            "redis",
+		Port:            "6379",
+		PoolSize:        5,
+		MinIdleConns:    10,
+		MinRetryBackoff: time.Second,
+		MaxRetryBackoff: time.Millisecond,
+		TLS:             RedisTLSConfig{CertFile: "client.pem", InsecureSkipVerify: true},
+	}
+	var errs ValidationErrors
+	require.ErrorAs(t, connection.Validate(), &errs)
+	paths := make([]string, 0, len(errs))
+	for _, e := range errs {
+		paths = append(paths, e.Path)
+	}
+	assert.Equal(t, []string{
+		"REDIS_TLS_CERT_FILE",
+		"REDIS_TLS_ENABLED",
+		"REDIS_TLS_INSECURE_SKIP_VERIFY",
+		"REDIS_MIN_IDLE_CONNS",
+		"REDIS_MIN_RETRY_BACKOFF",
+	}, paths)
+}
+
+func TestConfigValidateStorage(t *testing.T) {
+	gossip := Config{
+		Server:    ServerConfig{Port: "8080"},
+		RateLimit: RateLimitConfig{IPLimit: 10, WindowSeconds: 1, Storage: StorageGossip},
+		Headers:   DefaultHeaderConfig(),
+		Response:  ResponseConfig{ErrorFormat: ErrorFormatJSON},
+		Tokens:    TokensConfig{File: "configs/tokens.json"},
+		Rules:     RulesConfig{File: "configs/rules.json"},
+		Gossip:    GossipConfig{NodeID: "edge-1", BindAddr: ":7946", Peers: []string{"edge-2:7946"}, Interval: 200 * time.Millisecond},
+	}
+	// Sem Redis configurado
+	assert.ErrorContains(t, gossip.Validate(), "GOSSIP_SECRET")
+	gossip.Gossip.Secret = "s3cret"
+	require.NoError(t, gossip.Validate())
+	assert.True(t, gossip.RateLimit.UseGossip())
+
+	gossip.Gossip = GossipConfig{}
+	gossip.Batch = BatchConfig{Enabled: true, SyncInterval: time.Second, LeaseSize: 10}
+	gossip.BlockCache = BlockCacheConfig{Enabled: true, MaxEntries: 10, Channel: "c"}
+	err := gossip.Validate()
+	assert.ErrorContains(t, err, "GOSSIP_NODE_ID")
+	assert.ErrorContains(t, err, "GOSSIP_BIND_ADDR")
+	assert.ErrorContains(t, err, "GOSSIP_INTERVAL")
+	assert.ErrorContains(t, err, "BATCH_ENABLED")
+	assert.ErrorContains(t, err, "BLOCK_CACHE_ENABLED")
+
+	gossip.RateLimit.Storage = "memcached"
+	assert.ErrorContains(t, gossip.Validate(), "RATE_LIMIT_STORAGE")
+
+	// O cache de bloqueios é suportado no storage em arquivo (um único nó)
+	bolt := gossip
+	bolt.RateLimit.Storage = StorageBolt
+	bolt.Batch = BatchConfig{}
+	bolt.Bolt = BoltConfig{Path: "data/rate_limiter.db", CompactInterval: time.Minute}
+	require.NoError(t, bolt.Validate())
+	assert.False(t, bolt.RateLimit.UseRedis())
+
+	bolt.Bolt = BoltConfig{}
+	err = bolt.Validate()
+	assert.ErrorContains(t, err, "BOLT_PATH")
+	assert.ErrorContains(t, err, "BOLT_COMPACT_INTERVAL")
+}
@@ -0,0 +1,232 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/netip"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+)
+
+//go:generate go run ../../cmd/policyschema -o ../../configs/policy.schema.json
+
+// Versão atual do formato do arquivo de política
+const PolicyVersion = 1
+
+// Algoritmos de rate limit suportados
+const (
+	AlgorithmSlidingWindow = "sliding_window"
+)
+
+// Arquivo de política versionado (YAML ou JSON) com todos os limites.
+// As variáveis de ambiente continuam sobrescrevendo defaults e headers.
+type Policy struct {
+	Version   int             `json:"version" jsonschema:"required,enum=1" description:"Versão do formato do arquivo"`
+	Algorithm string          `json:"algorithm,omitempty" jsonschema:"enum=sliding_window" description:"Algoritmo de contagem (default: sliding_window)"`
+	Defaults  *PolicyDefaults `json:"defaults,omitempty" description:"Limites padrão por IP (sobrescritos por RATE_LIMIT_*)"`
+	Headers   *PolicyHeaders  `json:"headers,omitempty" description:"Headers de rate limit (sobrescritos por RATE_LIMIT_HEADER_*)"`
+	Rules     RuleConfigs     `json:"rules,omitempty" description:"Regras nomeadas; substituem configs/rules.json"`
+	Routes    RouteRules      `json:"routes,omitempty" description:"Regras aplicadas por rota; a primeira que casar vence"`
+	Plans     PlanConfigs     `json:"plans,omitempty" description:"Planos referenciados pelos tokens e pelo claim do JWT"`
+	Tokens    TokenConfigs    `json:"tokens,omitempty" description:"Tokens de API; substituem o tokens.json"`
+	Access    *AccessList     `json:"access,omitempty" description:"IPs isentos e bloqueados"`
+}
+
+// Limites padrão por IP da política
+type PolicyDefaults struct {
+	Limit                int    `json:"limit,omitempty" jsonschema:"minimum=1" description:"Requisições por janela (RATE_LIMIT_IP)"`
+	WindowSeconds        int    `json:"window_seconds,omitempty" jsonschema:"minimum=1" description:"Tamanho da janela em segundos (RATE_LIMIT_WINDOW_SECONDS)"`
+	BlockDurationSeconds int    `json:"block_duration_seconds,omitempty" jsonschema:"minimum=0" description:"Bloqueio após exceder o limite (RATE_LIMIT_BLOCK_DURATION_SECONDS)"`
+	DryRun               bool   `json:"dry_run,omitempty" description:"Apenas registra as rejeições (RATE_LIMIT_DRY_RUN)"`
+	Key                  string `json:"key,omitempty" description:"Identificação do cliente, ex.: ip+route (RATE_LIMIT_KEY)"`
+}
+
+// Headers de rate limit da política
+type PolicyHeaders struct {
+	Mode        string `json:"mode,omitempty" jsonschema:"enum=legacy|ietf|both" description:"Headers enviados (RATE_LIMIT_HEADER_MODE)"`
+	ResetFormat string `json:"reset_format,omitempty" jsonschema:"enum=rfc3339|delta|epoch" description:"Formato do X-RateLimit-Reset (RATE_LIMIT_HEADER_RESET_FORMAT)"`
+}
+
+// Regra nomeada aplicada às requisições cujo path casa com Path
+type RouteRule struct {
+	Path   string `json:"path" jsonschema:"required" description:"Glob de path.Match, ex.: /api/v1/login"`
+	Method string `json:"method,omitempty" description:"Método HTTP; vazio casa com qualquer método"`
+	Rule   string `json:"rule" jsonschema:"required" description:"Nome da regra em rules"`
+}
+
+type RouteRules []RouteRule
+
+// Retorna a regra da primeira rota que casa com o método e o path
+func (rr RouteRules) Match(method, requestPath string) (string, bool) {
+	for _, route := range rr {
+		if route.Method != "" && !strings.EqualFold(route.Method, method) {
+			continue
+		}
+		if matched, _ := path.Match(route.Path, requestPath); matched {
+			return route.Rule, true
+		}
+	}
+	return "", false
+}
+
+// Listas de IPs isentos do rate limit e bloqueados. Deny tem prioridade.
+type AccessList struct {
+	Allow []string `json:"allow,omitempty" description:"CIDRs isentos do rate limit"`
+	Deny  []string `json:"deny,omitempty" description:"CIDRs rejeitados com 403"`
+}
+
+// Converte as listas em prefixos; entradas inválidas são ignoradas
+// (a validação da política já as rejeita)
+func (a *AccessList) Prefixes() (allow, deny []netip.Prefix) {
+	parse := func(cidrs []string) []netip.Prefix {
+		prefixes := make([]netip.Prefix, 0, len(cidrs))
+		for _, cidr := range cidrs {
+			if prefix, err := netip.ParsePrefix(cidr); err == nil {
+				prefixes = append(prefixes, prefix)
+			}
+		}
+		return prefixes
+	}
+	return parse(a.Allow), parse(a.Deny)
+}
+
+// Planos e tokens da política no formato do tokens.json. Retorna nil quando a
+// política não define tokens.
+func (p *Policy) TokenFile() *TokenFile {
+	if p.Tokens == nil && p.Plans == nil {
+		return nil
+	}
+	return &TokenFile{Plans: p.Plans, Tokens: p.Tokens}
+}
+
+// Carrega o arquivo de política. O formato é escolhido pela extensão:
+// .yaml/.yml para YAML, qualquer outra para JSON.
+func LoadPolicy(filePath string) (*Policy, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("error opening policy file: %w", err)
+	}
+
+	// YAML é convertido para JSON para reaproveitar as tags e a decodificação estrita
+	switch strings.ToLower(filepath.Ext(filePath)) {
+	case ".yaml", ".yml":
+		var doc interface{}
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			return nil, fmt.Errorf("error decoding policy: %w", err)
+		}
+		if data, err = json.Marshal(doc); err != nil {
+			return nil, fmt.Errorf("error decoding policy: %w", err)
+		}
+	}
+
+	var policy Policy
+	if err := decodeStrict(data, &policy); err != nil {
+		return nil, fmt.Errorf("error decoding policy: %w", err)
+	}
+
+	if err := policy.resolve(); err != nil {
+		return nil, fmt.Errorf("invalid policy: %w", err)
+	}
+
+	return &policy, nil
+}
+
+// Aplica a herança dos planos e valida a política, reportando todos os problemas
+func (p *Policy) resolve() error {
+	var errs ValidationErrors
+
+	if p.Version != PolicyVersion {
+		errs.add("version", "must be %d (got %d)", PolicyVersion, p.Version)
+	}
+	switch p.Algorithm {
+	case "", AlgorithmSlidingWindow:
+	default:
+		errs.add("algorithm", "must be %s (got %q)", AlgorithmSlidingWindow, p.Algorithm)
+	}
+
+	if d := p.Defaults; d != nil {
+		if d.Limit < 0 {
+			errs.add("defaults.limit", "must not be negative (got %d)", d.Limit)
+		}
+		if d.WindowSeconds < 0 {
+			errs.add("defaults.window_seconds", "must not be negative (got %d)", d.WindowSeconds)
+		}
+		if d.BlockDurationSeconds < 0 {
+			errs.add("defaults.block_duration_seconds", "must not be negative (got %d)", d.BlockDurationSeconds)
+		}
+	}
+
+	if h := p.Headers; h != nil {
+		switch h.Mode {
+		case "", HeaderModeLegacy, HeaderModeIETF, HeaderModeBoth:
+		default:
+			errs.add("headers.mode", "must be one of %s, %s or %s (got %q)", HeaderModeLegacy, HeaderModeIETF, HeaderModeBoth, h.Mode)
+		}
+		switch h.ResetFormat {
+		case "", ResetFormatRFC3339, ResetFormatDelta, ResetFormatEpoch:
+		default:
+			errs.add("headers.reset_format", "must be one of %s, %s or %s (got %q)", ResetFormatRFC3339, ResetFormatDelta, ResetFormatEpoch, h.ResetFormat)
+		}
+	}
+
+	p.Rules.validate(&errs, "rules")
+
+	for i, route := range p.Routes {
+		prefix := fmt.Sprintf("routes[%d]", i)
+		if _, err := path.Match(route.Path, ""); err != nil || !strings.HasPrefix(route.Path, "/") {
+			errs.add(prefix+".path", "must be a path.Match glob starting with / (got %q)", route.Path)
+		}
+		// Sem a seção rules, as regras vêm do rules.json e não são conhecidas aqui
+		if p.Rules != nil {
+			if _, exists := p.Rules[route.Rule]; !exists {
+				errs.add(prefix+".rule", "unknown rule %q", route.Rule)
+			}
+		}
+	}
+
+	if tokenFile := p.TokenFile(); tokenFile != nil {
+		tokenFile.resolveInto(&errs)
+	}
+
+	if p.Access != nil {
+		for i, cidr := range p.Access.Allow {
+			if _, err := netip.ParsePrefix(cidr); err != nil {
+				errs.add(fmt.Sprintf("access.allow[%d]", i), "invalid CIDR %q", cidr)
+			}
+		}
+		for i, cidr := range p.Access.Deny {
+			if _, err := netip.ParsePrefix(cidr); err != nil {
+				errs.add(fmt.Sprintf("access.deny[%d]", i), "invalid CIDR %q", cidr)
+			}
+		}
+	}
+
+	return errs.err()
+}
+
+// Usa os valores da política como defaults das variáveis de ambiente, que
+// continuam tendo prioridade
+func (p *Policy) applyDefaults() {
+	if d := p.Defaults; d != nil {
+		setDefaultIf(d.Limit != 0, "RATE_LIMIT_IP", d.Limit)
+		setDefaultIf(d.WindowSeconds != 0, "RATE_LIMIT_WINDOW_SECONDS", d.WindowSeconds)
+		setDefaultIf(d.BlockDurationSeconds != 0, "RATE_LIMIT_BLOCK_DURATION_SECONDS", d.BlockDurationSeconds)
+		setDefaultIf(d.DryRun, "RATE_LIMIT_DRY_RUN", d.DryRun)
+		setDefaultIf(d.Key != "", "RATE_LIMIT_KEY", d.Key)
+	}
+	if h := p.Headers; h != nil {
+		setDefaultIf(h.Mode != "", "RATE_LIMIT_HEADER_MODE", h.Mode)
+		setDefaultIf(h.ResetFormat != "", "RATE_LIMIT_HEADER_RESET_FORMAT", h.ResetFormat)
+	}
+}
+
+func setDefaultIf(condition bool, key string, value interface{}) {
+	if condition {
+		viper.SetDefault(key, value)
+	}
+}
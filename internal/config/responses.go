@@ -0,0 +1,65 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Template da resposta 429 de uma rota. Cada formato aceita o template
+// inline ou um arquivo (relativo ao arquivo de templates).
+type ResponseTemplateConfig struct {
+	JSON     string `json:"json"`
+	JSONFile string `json:"json_file"`
+	Text     string `json:"text"`
+	TextFile string `json:"text_file"`
+	HTML     string `json:"html"`
+	HTMLFile string `json:"html_file"`
+}
+
+type ResponseTemplateConfigs map[string]ResponseTemplateConfig
+
+// Carrega os templates de resposta por rota a partir de um arquivo JSON,
+// resolvendo o conteúdo dos templates referenciados por arquivo
+func LoadResponseTemplates(filePath string) (ResponseTemplateConfigs, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("error opening response templates file: %w", err)
+	}
+
+	var templates ResponseTemplateConfigs
+	if err := decodeStrict(data, &templates); err != nil {
+		return nil, fmt.Errorf("error decoding response templates: %w", err)
+	}
+
+	baseDir := filepath.Dir(filePath)
+	for route, tpl := range templates {
+		if tpl.JSON, err = readTemplateFile(baseDir, tpl.JSON, tpl.JSONFile); err != nil {
+			return nil, fmt.Errorf("route %q: %w", route, err)
+		}
+		if tpl.Text, err = readTemplateFile(baseDir, tpl.Text, tpl.TextFile); err != nil {
+			return nil, fmt.Errorf("route %q: %w", route, err)
+		}
+		if tpl.HTML, err = readTemplateFile(baseDir, tpl.HTML, tpl.HTMLFile); err != nil {
+			return nil, fmt.Errorf("route %q: %w", route, err)
+		}
+		templates[route] = tpl
+	}
+
+	return templates, nil
+}
+
+// Retorna o template inline ou, se houver, o conteúdo do arquivo
+func readTemplateFile(baseDir, inline, file string) (string, error) {
+	if file == "" {
+		return inline, nil
+	}
+	if !filepath.IsAbs(file) {
+		file = filepath.Join(baseDir, file)
+	}
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return "", fmt.Errorf("error reading template file: %w", err)
+	}
+	return string(content), nil
+}
@@ -0,0 +1,38 @@
+package config
+
+import (
+	"fmt"
+	"os"
+)
+
+// Rota do modo gateway: requisições que casam com Path são encaminhadas ao Upstream
+type RouteConfig struct {
+	// Path no formato do chi (ex.: "/orders/*")
+	Path     string `json:"path"`
+	Upstream string `json:"upstream"`
+	// StripPrefix é removido do path antes de encaminhar
+	StripPrefix string `json:"strip_prefix"`
+	// Rule aplica uma regra nomeada em vez dos limites padrão de IP/token
+	Rule string `json:"rule"`
+	// HealthPath habilita health check ativo do upstream
+	HealthPath string `json:"health_path"`
+}
+
+// Carrega as rotas do modo gateway a partir de um arquivo JSON
+func LoadRouteConfigs(filePath string) ([]RouteConfig, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("error opening routes config file: %w", err)
+	}
+
+	var routes []RouteConfig
+	if err := decodeStrict(data, &routes); err != nil {
+		return nil, fmt.Errorf("error decoding routes config: %w", err)
+	}
+
+	if err := validateRoutes(routes); err != nil {
+		return nil, fmt.Errorf("invalid routes config: %w", err)
+	}
+
+	return routes, nil
+}
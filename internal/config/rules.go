@@ -0,0 +1,52 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// Regra nomeada de rate limit, aplicada por rota ou por chamada
+type RuleConfig struct {
+	Limit                int  `json:"limit" jsonschema:"required,minimum=1"`
+	WindowSeconds        int  `json:"window_seconds" jsonschema:"required,minimum=1"`
+	BlockDurationSeconds int  `json:"block_duration_seconds" jsonschema:"minimum=0"`
+	DryRun               bool `json:"dry_run" description:"Apenas registra as rejeições, sem bloquear"`
+}
+
+func (r *RuleConfig) GetWindowDuration() time.Duration {
+	return time.Duration(r.WindowSeconds) * time.Second
+}
+
+func (r *RuleConfig) GetBlockDuration() time.Duration {
+	return time.Duration(r.BlockDurationSeconds) * time.Second
+}
+
+type RuleConfigs map[string]RuleConfig
+
+// Carrega as regras nomeadas a partir de um arquivo JSON
+func LoadRuleConfigs(filePath string) (RuleConfigs, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("error opening rules config file: %w", err)
+	}
+
+	var ruleConfigs RuleConfigs
+	if err := decodeStrict(data, &ruleConfigs); err != nil {
+		return nil, fmt.Errorf("error decoding rules config: %w", err)
+	}
+
+	if err := ruleConfigs.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid rules config: %w", err)
+	}
+
+	return ruleConfigs, nil
+}
+
+func (rc RuleConfigs) GetRuleConfig(name string) (*RuleConfig, bool) {
+	config, exists := rc[name]
+	if !exists {
+		return nil, false
+	}
+	return &config, true
+}
@@ -0,0 +1,49 @@
+package config
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+)
+
+// Modos de armazenamento dos tokens no tokens.json e nas chaves do Redis
+const (
+	TokenHashNone   = "none"
+	TokenHashSHA256 = "sha256"
+	TokenHashHMAC   = "hmac-sha256"
+)
+
+// Converte o token recebido no identificador armazenado (digest em hex ou o próprio token)
+type TokenHasher func(token string) string
+
+// Cria o hasher do modo informado. O modo HMAC exige um segredo.
+func NewTokenHasher(mode, secret string) (TokenHasher, error) {
+	switch mode {
+	case "", TokenHashNone:
+		return func(token string) string { return token }, nil
+	case TokenHashSHA256:
+		return func(token string) string {
+			sum := sha256.Sum256([]byte(token))
+			return hex.EncodeToString(sum[:])
+		}, nil
+	case TokenHashHMAC:
+		if secret == "" {
+			return nil, fmt.Errorf("token hash mode %q requires a secret", mode)
+		}
+		key := []byte(secret)
+		return func(token string) string {
+			mac := hmac.New(sha256.New, key)
+			mac.Write([]byte(token))
+			return hex.EncodeToString(mac.Sum(nil))
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown token hash mode %q", mode)
+	}
+}
+
+// Identificador curto e não reversível do token, seguro para logs
+func TokenFingerprint(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return "sha256:" + hex.EncodeToString(sum[:4])
+}
@@ -2,15 +2,42 @@ package config
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net/netip"
 	"os"
+	"path"
+	"sort"
 	"time"
 )
 
+// Erros de validade e escopo de um token conhecido
+var (
+	ErrTokenDisabled    = errors.New("token disabled")
+	ErrTokenExpired     = errors.New("token expired")
+	ErrTokenNotYetValid = errors.New("token not yet valid")
+	ErrRouteNotAllowed  = errors.New("route not allowed for token")
+	ErrIPNotAllowed     = errors.New("IP not allowed for token")
+)
+
 type TokenConfig struct {
-	Limit                int `json:"limit"`
-	WindowSeconds        int `json:"window_seconds"`
-	BlockDurationSeconds int `json:"block_duration_seconds"`
+	// Plan referencia um plano da seção "plans"; os campos abaixo, quando
+	// informados (diferentes de zero), sobrescrevem os do plano
+	Plan                 string `json:"plan,omitempty" description:"Plano do qual os limites são herdados"`
+	Limit                int    `json:"limit" jsonschema:"minimum=0"`
+	WindowSeconds        int    `json:"window_seconds" jsonschema:"minimum=0"`
+	BlockDurationSeconds int    `json:"block_duration_seconds" jsonschema:"minimum=0"`
+
+	// Validade do token (RFC 3339); ausentes significam sem restrição
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	NotBefore *time.Time `json:"not_before,omitempty"`
+	Disabled  bool       `json:"disabled,omitempty"`
+	// AllowedRoutes restringe os paths aceitos (globs de path.Match, ex.: "/api/v1/*")
+	AllowedRoutes []string `json:"allowed_routes,omitempty" description:"Globs de path.Match aceitos para o token"`
+	// AllowedCIDRs restringe os IPs de origem (ex.: "10.0.0.0/8")
+	AllowedCIDRs []string `json:"allowed_cidrs,omitempty" description:"CIDRs de origem aceitos para o token"`
+	Owner        string   `json:"owner,omitempty"`
+	Description  string   `json:"description,omitempty"`
 }
 
 func (t *TokenConfig) GetWindowDuration() time.Duration {
@@ -21,27 +48,190 @@ func (t *TokenConfig) GetBlockDuration() time.Duration {
 	return time.Duration(t.BlockDurationSeconds) * time.Second
 }
 
+// Verifica se o token está habilitado e dentro do período de validade
+func (t *TokenConfig) CheckValidity(now time.Time) error {
+	if t.Disabled {
+		return ErrTokenDisabled
+	}
+	if t.ExpiresAt != nil && !now.Before(*t.ExpiresAt) {
+		return ErrTokenExpired
+	}
+	if t.NotBefore != nil && now.Before(*t.NotBefore) {
+		return ErrTokenNotYetValid
+	}
+	return nil
+}
+
+// Verifica se o path e o IP de origem estão no escopo do token
+func (t *TokenConfig) CheckScope(requestPath, ip string) error {
+	if len(t.AllowedRoutes) > 0 {
+		allowed := false
+		for _, pattern := range t.AllowedRoutes {
+			if matched, _ := path.Match(pattern, requestPath); matched {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return ErrRouteNotAllowed
+		}
+	}
+
+	if len(t.AllowedCIDRs) > 0 {
+		addr, err := netip.ParseAddr(ip)
+		if err != nil {
+			return ErrIPNotAllowed
+		}
+		for _, cidr := range t.AllowedCIDRs {
+			if prefix, err := netip.ParsePrefix(cidr); err == nil && prefix.Contains(addr.Unmap()) {
+				return nil
+			}
+		}
+		return ErrIPNotAllowed
+	}
+
+	return nil
+}
+
+// Valida globs e CIDRs do escopo
+func (t *TokenConfig) validateScope(errs *ValidationErrors, prefix string) {
+	for i, pattern := range t.AllowedRoutes {
+		if _, err := path.Match(pattern, ""); err != nil {
+			errs.add(fmt.Sprintf("%s.allowed_routes[%d]", prefix, i), "invalid glob %q: %v", pattern, err)
+		}
+	}
+	for i, cidr := range t.AllowedCIDRs {
+		if _, err := netip.ParsePrefix(cidr); err != nil {
+			errs.add(fmt.Sprintf("%s.allowed_cidrs[%d]", prefix, i), "invalid CIDR %q", cidr)
+		}
+	}
+	if t.ExpiresAt != nil && t.NotBefore != nil && !t.NotBefore.Before(*t.ExpiresAt) {
+		errs.add(prefix+".not_before", "must be before expires_at")
+	}
+}
+
 type TokenConfigs map[string]TokenConfig
 
+// Conjunto nomeado de limites (tier) referenciado pelos tokens
+type PlanConfig struct {
+	Limit                int `json:"limit" jsonschema:"required,minimum=1"`
+	WindowSeconds        int `json:"window_seconds" jsonschema:"required,minimum=1"`
+	BlockDurationSeconds int `json:"block_duration_seconds" jsonschema:"minimum=0"`
+}
+
+func (p *PlanConfig) GetWindowDuration() time.Duration {
+	return time.Duration(p.WindowSeconds) * time.Second
+}
+
+func (p *PlanConfig) GetBlockDuration() time.Duration {
+	return time.Duration(p.BlockDurationSeconds) * time.Second
+}
+
+type PlanConfigs map[string]PlanConfig
+
+func (pc PlanConfigs) GetPlanConfig(name string) (*PlanConfig, bool) {
+	config, exists := pc[name]
+	if !exists {
+		return nil, false
+	}
+	return &config, true
+}
+
+// Conteúdo do tokens.json: planos e tokens, já com a herança resolvida
+type TokenFile struct {
+	Plans  PlanConfigs  `json:"plans"`
+	Tokens TokenConfigs `json:"tokens"`
+}
+
 // Carrega configurações de tokens a partir de um arquivo JSON
 func LoadTokenConfigs(filePath string) (TokenConfigs, error) {
-	file, err := os.Open(filePath)
+	tokenFile, err := LoadTokenFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	return tokenFile.Tokens, nil
+}
+
+// Carrega planos e tokens, resolvendo a herança dos planos e validando o
+// resultado. Aceita também o formato antigo, com os tokens no nível raiz.
+func LoadTokenFile(filePath string) (*TokenFile, error) {
+	data, err := os.ReadFile(filePath)
 	if err != nil {
 		return nil, fmt.Errorf("error opening tokens config file: %w", err)
 	}
-	defer func() {
-		if closeErr := file.Close(); closeErr != nil {
-			err = closeErr
-		}
-	}()
 
-	var tokenConfigs TokenConfigs
-	decoder := json.NewDecoder(file)
-	if err := decoder.Decode(&tokenConfigs); err != nil {
+	var sections map[string]json.RawMessage
+	if err := json.Unmarshal(data, &sections); err != nil {
+		return nil, fmt.Errorf("error decoding tokens config: %w", err)
+	}
+
+	var tokenFile TokenFile
+	_, hasPlans := sections["plans"]
+	_, hasTokens := sections["tokens"]
+	if hasPlans || hasTokens {
+		err = decodeStrict(data, &tokenFile)
+	} else {
+		err = decodeStrict(data, &tokenFile.Tokens)
+	}
+	if err != nil {
 		return nil, fmt.Errorf("error decoding tokens config: %w", err)
 	}
 
-	return tokenConfigs, nil
+	if err := tokenFile.resolve(); err != nil {
+		return nil, fmt.Errorf("invalid tokens config: %w", err)
+	}
+
+	return &tokenFile, nil
+}
+
+// Aplica os planos aos tokens e valida planos e tokens resolvidos, reportando
+// todos os problemas. Os erros identificam tokens pela impressão digital, nunca pelo valor.
+func (f *TokenFile) resolve() error {
+	var errs ValidationErrors
+	f.resolveInto(&errs)
+	return errs.err()
+}
+
+func (f *TokenFile) resolveInto(errs *ValidationErrors) {
+	for _, name := range sortedKeys(f.Plans) {
+		plan := f.Plans[name]
+		errs.limits(fmt.Sprintf("plans[%q]", name), plan.Limit, plan.WindowSeconds, plan.BlockDurationSeconds)
+	}
+
+	for _, name := range sortedKeys(f.Tokens) {
+		token := f.Tokens[name]
+		prefix := fmt.Sprintf("tokens[%s]", TokenFingerprint(name))
+
+		if token.Plan != "" {
+			plan, exists := f.Plans[token.Plan]
+			if !exists {
+				errs.add(prefix+".plan", "unknown plan %q", token.Plan)
+				continue
+			}
+			if token.Limit == 0 {
+				token.Limit = plan.Limit
+			}
+			if token.WindowSeconds == 0 {
+				token.WindowSeconds = plan.WindowSeconds
+			}
+			if token.BlockDurationSeconds == 0 {
+				token.BlockDurationSeconds = plan.BlockDurationSeconds
+			}
+		}
+
+		errs.limits(prefix, token.Limit, token.WindowSeconds, token.BlockDurationSeconds)
+		token.validateScope(errs, prefix)
+		f.Tokens[name] = token
+	}
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
 }
 
 func (tc TokenConfigs) GetTokenConfig(token string) (*TokenConfig, bool) {
@@ -0,0 +1,418 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Problema de configuração encontrado na validação, com o caminho do campo
+type FieldError struct {
+	Path    string
+	Message string
+}
+
+func (e FieldError) Error() string {
+	return e.Path + ": " + e.Message
+}
+
+// Todos os problemas encontrados em uma validação
+type ValidationErrors []FieldError
+
+func (v ValidationErrors) Error() string {
+	if len(v) == 1 {
+		return v[0].Error()
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d problems:", len(v))
+	for _, e := range v {
+		b.WriteString("\n  - ")
+		b.WriteString(e.Error())
+	}
+	return b.String()
+}
+
+func (v *ValidationErrors) add(path, format string, args ...interface{}) {
+	*v = append(*v, FieldError{Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+// Retorna nil quando não há problemas, evitando a interface não nula
+func (v ValidationErrors) err() error {
+	if len(v) == 0 {
+		return nil
+	}
+	return v
+}
+
+// Valida limite, janela e bloqueio de um conjunto de limites
+func (v *ValidationErrors) limits(path string, limit, windowSeconds, blockDurationSeconds int) {
+	if limit <= 0 {
+		v.add(path+".limit", "must be greater than zero (got %d)", limit)
+	}
+	if windowSeconds <= 0 {
+		v.add(path+".window_seconds", "must be greater than zero (got %d)", windowSeconds)
+	}
+	if blockDurationSeconds < 0 {
+		v.add(path+".block_duration_seconds", "must not be negative (got %d)", blockDurationSeconds)
+	}
+}
+
+// Decodifica JSON rejeitando campos desconhecidos (erros de digitação nos arquivos)
+func decodeStrict(data []byte, v interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		return err
+	}
+	if decoder.More() {
+		return fmt.Errorf("unexpected data after top-level value")
+	}
+	return nil
+}
+
+// Valida a configuração carregada do ambiente. Os caminhos usam o nome da
+// variável de ambiente correspondente.
+func (c *Config) Validate() error {
+	var errs ValidationErrors
+
+	validatePort(&errs, "SERVER_PORT", c.Server.Port)
+	if c.Server.DecisionAPIEnabled {
+		requireNonEmpty(&errs, "DECISION_API_SECRET", c.Server.DecisionAPISecret)
+	}
+	if c.Server.AdminAddr != "" {
+		if _, port, err := net.SplitHostPort(c.Server.AdminAddr); err != nil {
+			errs.add("ADMIN_ADDR", "must be host:port (got %q)", c.Server.AdminAddr)
+		} else {
+			validatePort(&errs, "ADMIN_ADDR", port)
+		}
+	}
+
+	if c.RateLimit.IPLimit <= 0 {
+		errs.add("RATE_LIMIT_IP", "must be greater than zero (got %d)", c.RateLimit.IPLimit)
+	}
+	if c.RateLimit.WindowSeconds <= 0 {
+		errs.add("RATE_LIMIT_WINDOW_SECONDS", "must be greater than zero (got %d)", c.RateLimit.WindowSeconds)
+	}
+	if c.RateLimit.BlockDurationSeconds < 0 {
+		errs.add("RATE_LIMIT_BLOCK_DURATION_SECONDS", "must not be negative (got %d)", c.RateLimit.BlockDurationSeconds)
+	}
+	if !ValidKeySegment(c.RateLimit.KeyPrefix) {
+		errs.add("RATE_LIMIT_KEY_PREFIX", "must not contain :, *, ?, [, ], \\, { or } (got %q)", c.RateLimit.KeyPrefix)
+	}
+	if _, err := ParseCIDRs(c.RateLimit.TrustedProxies); err != nil {
+		errs.add("TRUSTED_PROXIES", "%v", err)
+	}
+	if !ValidKeySegment(c.RateLimit.Tenant) {
+		errs.add("RATE_LIMIT_TENANT", "must not contain :, *, ?, [, ], \\, { or } (got %q)", c.RateLimit.Tenant)
+	}
+
+	switch c.RateLimit.Storage {
+	case "", StorageRedis:
+		c.validateRedis(&errs)
+	case StorageGossip:
+		c.Gossip.validate(&errs)
+		if c.Batch.Enabled {
+			errs.add("BATCH_ENABLED", "is not supported with RATE_LIMIT_STORAGE=%s", StorageGossip)
+		}
+		// Os contadores já ficam em memória e o cache não teria como ser invalidado pelo Redis
+		if c.BlockCache.Enabled {
+			errs.add("BLOCK_CACHE_ENABLED", "is not supported with RATE_LIMIT_STORAGE=%s", StorageGossip)
+		}
+	case StorageBolt:
+		requireNonEmpty(&errs, "BOLT_PATH", c.Bolt.Path)
+		if c.Bolt.CompactInterval <= 0 {
+			errs.add("BOLT_COMPACT_INTERVAL", "must be greater than zero (got %s)", c.Bolt.CompactInterval)
+		}
+		if c.Batch.Enabled {
+			errs.add("BATCH_ENABLED", "is not supported with RATE_LIMIT_STORAGE=%s", StorageBolt)
+		}
+	default:
+		errs.add("RATE_LIMIT_STORAGE", "must be one of %s, %s or %s (got %q)", StorageRedis, StorageGossip, StorageBolt, c.RateLimit.Storage)
+	}
+
+	switch c.Headers.Mode {
+	case HeaderModeLegacy, HeaderModeIETF, HeaderModeBoth:
+	default:
+		errs.add("RATE_LIMIT_HEADER_MODE", "must be one of %s, %s or %s (got %q)", HeaderModeLegacy, HeaderModeIETF, HeaderModeBoth, c.Headers.Mode)
+	}
+	switch c.Headers.ResetFormat {
+	case ResetFormatRFC3339, ResetFormatDelta, ResetFormatEpoch:
+	default:
+		errs.add("RATE_LIMIT_HEADER_RESET_FORMAT", "must be one of %s, %s or %s (got %q)", ResetFormatRFC3339, ResetFormatDelta, ResetFormatEpoch, c.Headers.ResetFormat)
+	}
+	if c.Headers.SendLegacy() {
+		requireNonEmpty(&errs, "RATE_LIMIT_HEADER_LIMIT_NAME", c.Headers.LimitHeader)
+		requireNonEmpty(&errs, "RATE_LIMIT_HEADER_REMAINING_NAME", c.Headers.RemainingHeader)
+		requireNonEmpty(&errs, "RATE_LIMIT_HEADER_RESET_NAME", c.Headers.ResetHeader)
+	}
+	if c.Headers.SendIETF() {
+		requireNonEmpty(&errs, "RATE_LIMIT_HEADER_POLICY_NAME", c.Headers.PolicyHeader)
+		requireNonEmpty(&errs, "RATE_LIMIT_HEADER_RATELIMIT_NAME", c.Headers.RateLimitHeader)
+	}
+
+	switch c.Response.ErrorFormat {
+	case ErrorFormatJSON, ErrorFormatProblem:
+	default:
+		errs.add("RATE_LIMIT_ERROR_FORMAT", "must be %s or %s (got %q)", ErrorFormatJSON, ErrorFormatProblem, c.Response.ErrorFormat)
+	}
+
+	if c.Envoy.Enabled {
+		validatePort(&errs, "ENVOY_RLS_PORT", c.Envoy.Port)
+		// Escutar em todas as interfaces exige 0.0.0.0 (ou ::) explícito
+		requireNonEmpty(&errs, "ENVOY_RLS_BIND_HOST", c.Envoy.BindHost)
+		if c.Envoy.Port == c.Server.Port {
+			errs.add("ENVOY_RLS_PORT", "must differ from SERVER_PORT (%s)", c.Server.Port)
+		}
+	}
+
+	if c.Proxy.Enabled {
+		requireNonEmpty(&errs, "PROXY_ROUTES_FILE", c.Proxy.RoutesFile)
+		if c.Proxy.HealthIntervalSeconds <= 0 {
+			errs.add("PROXY_HEALTH_INTERVAL_SECONDS", "must be greater than zero (got %d)", c.Proxy.HealthIntervalSeconds)
+		}
+	}
+
+	requireNonEmpty(&errs, "TOKENS_FILE", c.Tokens.File)
+	requireNonEmpty(&errs, "RULES_FILE", c.Rules.File)
+	switch c.Tokens.HashMode {
+	case "", TokenHashNone, TokenHashSHA256:
+	case TokenHashHMAC:
+		if c.Tokens.HashSecret == "" {
+			errs.add("TOKEN_HASH_SECRET", "is required when TOKEN_HASH_MODE is %s", TokenHashHMAC)
+		}
+	default:
+		errs.add("TOKEN_HASH_MODE", "must be one of %s, %s or %s (got %q)", TokenHashNone, TokenHashSHA256, TokenHashHMAC, c.Tokens.HashMode)
+	}
+
+	if c.JWT.Enabled {
+		if c.JWT.HMACSecret == "" && c.JWT.PublicKeyFile == "" && c.JWT.JWKSFile == "" {
+			errs.add("JWT_ENABLED", "requires JWT_HMAC_SECRET, JWT_PUBLIC_KEY_FILE or JWT_JWKS_FILE")
+		}
+		requireNonEmpty(&errs, "JWT_KEY_CLAIM", c.JWT.KeyClaim)
+	}
+
+	if c.BlockCache.Enabled {
+		if c.BlockCache.MaxEntries <= 0 {
+			errs.add("BLOCK_CACHE_MAX_ENTRIES", "must be greater than zero (got %d)", c.BlockCache.MaxEntries)
+		}
+		requireNonEmpty(&errs, "BLOCK_CACHE_CHANNEL", c.BlockCache.Channel)
+	}
+
+	if c.Batch.Enabled {
+		if c.Batch.SyncInterval <= 0 {
+			errs.add("BATCH_SYNC_INTERVAL", "must be greater than zero (got %s)", c.Batch.SyncInterval)
+		}
+		if c.Batch.LeaseSize <= 0 {
+			errs.add("BATCH_LEASE_SIZE", "must be greater than zero (got %d)", c.Batch.LeaseSize)
+		}
+	}
+
+	if c.Concurrency.Limit < 0 {
+		errs.add("CONCURRENCY_LIMIT", "must not be negative (got %d)", c.Concurrency.Limit)
+	}
+	if c.Concurrency.Limit > 0 && c.Concurrency.LeaseTTL <= 0 {
+		errs.add("CONCURRENCY_LEASE_TTL", "must be greater than zero (got %s)", c.Concurrency.LeaseTTL)
+	}
+
+	if c.Wait.MaxWait < 0 {
+		errs.add("RATE_LIMIT_MAX_WAIT", "must not be negative (got %s)", c.Wait.MaxWait)
+	}
+	if c.Wait.MaxWait >= ServerWriteTimeout {
+		errs.add("RATE_LIMIT_MAX_WAIT", "must be below the server write timeout %s (got %s)", ServerWriteTimeout, c.Wait.MaxWait)
+	}
+	if c.Wait.MaxWait > 0 && c.Wait.MaxWaiters <= 0 {
+		errs.add("RATE_LIMIT_MAX_WAITERS", "must be greater than zero (got %d)", c.Wait.MaxWaiters)
+	}
+
+	return errs.err()
+}
+
+// Valida o modo e os endereços do Redis
+func (c *Config) validateRedis(errs *ValidationErrors) {
+	switch c.Redis.Mode {
+	case "", RedisModeStandalone:
+		if c.Redis.Socket == "" {
+			if c.Redis.Host == "" {
+				errs.add("REDIS_HOST", "must not be empty")
+			}
+			validatePort(errs, "REDIS_PORT", c.Redis.Port)
+		}
+	case RedisModeSentinel:
+		requireNonEmpty(errs, "REDIS_SENTINEL_MASTER", c.Redis.MasterName)
+		if len(c.Redis.Addrs) == 0 {
+			errs.add("REDIS_ADDRS", "must list the sentinel addresses in sentinel mode")
+		}
+	case RedisModeCluster:
+		if len(c.Redis.Addrs) == 0 {
+			errs.add("REDIS_ADDRS", "must list the seed node addresses in cluster mode")
+		}
+		if c.Redis.DB != 0 {
+			errs.add("REDIS_DB", "must be 0 in cluster mode (got %d)", c.Redis.DB)
+		}
+	case RedisModeSharded:
+		if len(c.Redis.Addrs) == 0 {
+			errs.add("REDIS_ADDRS", "must list the shard addresses in sharded mode")
+		}
+		names := make(map[string]bool)
+		for i, shard := range c.Redis.Shards() {
+			if shard.Name == "" || shard.Addr == "" {
+				errs.add(fmt.Sprintf("REDIS_ADDRS[%d]", i), "must be host:port or name=host:port (got %q)", c.Redis.Addrs[i])
+				continue
+			}
+			if names[shard.Name] {
+				errs.add(fmt.Sprintf("REDIS_ADDRS[%d]", i), "duplicate shard %q", shard.Name)
+			}
+			names[shard.Name] = true
+		}
+		if c.Redis.ShardHealthInterval <= 0 {
+			errs.add("REDIS_SHARD_HEALTH_INTERVAL", "must be greater than zero (got %s)", c.Redis.ShardHealthInterval)
+		}
+	default:
+		errs.add("REDIS_MODE", "must be one of %s, %s, %s or %s (got %q)", RedisModeStandalone, RedisModeSentinel, RedisModeCluster, RedisModeSharded, c.Redis.Mode)
+	}
+	if c.Redis.DB < 0 {
+		errs.add("REDIS_DB", "must not be negative (got %d)", c.Redis.DB)
+	}
+	c.Redis.validateConnection(errs, c.Server.AppEnv)
+}
+
+// Valida o storage por gossip
+func (g *GossipConfig) validate(errs *ValidationErrors) {
+	requireNonEmpty(errs, "GOSSIP_NODE_ID", g.NodeID)
+	requireNonEmpty(errs, "GOSSIP_BIND_ADDR", g.BindAddr)
+	// Com peers o endpoint precisa aceitar conexões de fora; sem o segredo
+	// qualquer um poderia bloquear ou zerar chaves
+	if len(g.Peers) > 0 && g.Secret == "" {
+		errs.add("GOSSIP_SECRET", "is required when GOSSIP_PEERS is set")
+	}
+	if g.Interval <= 0 {
+		errs.add("GOSSIP_INTERVAL", "must be greater than zero (got %s)", g.Interval)
+	}
+}
+
+// Valida socket, TLS, pool e timeouts da conexão com o Redis
+func (r *RedisConfig) validateConnection(errs *ValidationErrors, appEnv string) {
+	if r.Socket != "" && r.Mode != "" && r.Mode != RedisModeStandalone {
+		errs.add("REDIS_SOCKET", "is only supported in standalone mode")
+	}
+
+	if (r.TLS.CertFile == "") != (r.TLS.KeyFile == "") {
+		errs.add("REDIS_TLS_CERT_FILE", "must be set together with REDIS_TLS_KEY_FILE")
+	}
+	if !r.TLS.Enabled && (r.TLS.CAFile != "" || r.TLS.CertFile != "" || r.TLS.InsecureSkipVerify) {
+		errs.add("REDIS_TLS_ENABLED", "must be true when REDIS_TLS_* options are set")
+	}
+	if r.TLS.InsecureSkipVerify && appEnv == "production" {
+		errs.add("REDIS_TLS_INSECURE_SKIP_VERIFY", "must not be enabled when APP_ENV is production")
+	}
+
+	if r.PoolSize < 0 {
+		errs.add("REDIS_POOL_SIZE", "must not be negative (got %d)", r.PoolSize)
+	}
+	if r.MinIdleConns < 0 {
+		errs.add("REDIS_MIN_IDLE_CONNS", "must not be negative (got %d)", r.MinIdleConns)
+	} else if r.PoolSize > 0 && r.MinIdleConns > r.PoolSize {
+		errs.add("REDIS_MIN_IDLE_CONNS", "must not exceed REDIS_POOL_SIZE (%d > %d)", r.MinIdleConns, r.PoolSize)
+	}
+	for _, timeout := range []struct {
+		path  string
+		value time.Duration
+	}{
+		{"REDIS_DIAL_TIMEOUT", r.DialTimeout},
+		{"REDIS_READ_TIMEOUT", r.ReadTimeout},
+		{"REDIS_WRITE_TIMEOUT", r.WriteTimeout},
+		{"REDIS_MIN_RETRY_BACKOFF", r.MinRetryBackoff},
+		{"REDIS_MAX_RETRY_BACKOFF", r.MaxRetryBackoff},
+	} {
+		if timeout.value < 0 {
+			errs.add(timeout.path, "must not be negative (got %s)", timeout.value)
+		}
+	}
+	if r.MaxRetries < -1 {
+		errs.add("REDIS_MAX_RETRIES", "must be -1 (disabled) or greater (got %d)", r.MaxRetries)
+	}
+	if r.MaxRetryBackoff > 0 && r.MinRetryBackoff > r.MaxRetryBackoff {
+		errs.add("REDIS_MIN_RETRY_BACKOFF", "must not exceed REDIS_MAX_RETRY_BACKOFF (%s > %s)", r.MinRetryBackoff, r.MaxRetryBackoff)
+	}
+}
+
+func validatePort(errs *ValidationErrors, path, port string) {
+	n, err := strconv.Atoi(port)
+	if err != nil || n < 1 || n > 65535 {
+		errs.add(path, "must be a port number between 1 and 65535 (got %q)", port)
+	}
+}
+
+func requireNonEmpty(errs *ValidationErrors, path, value string) {
+	if value == "" {
+		errs.add(path, "must not be empty")
+	}
+}
+
+// Valida as regras nomeadas
+func (rc RuleConfigs) Validate() error {
+	var errs ValidationErrors
+	rc.validate(&errs, "")
+	return errs.err()
+}
+
+func (rc RuleConfigs) validate(errs *ValidationErrors, prefix string) {
+	for _, name := range sortedKeys(rc) {
+		rule := rc[name]
+		errs.limits(fmt.Sprintf("%s[%q]", prefix, name), rule.Limit, rule.WindowSeconds, rule.BlockDurationSeconds)
+	}
+}
+
+// Verifica se as regras referenciadas pelas rotas da política existem nas
+// regras carregadas (da política ou do rules.json)
+func (r RouteRules) CheckRules(rules RuleConfigs) error {
+	var errs ValidationErrors
+	for i, route := range r {
+		if _, exists := rules[route.Rule]; !exists {
+			errs.add(fmt.Sprintf("routes[%d].rule", i), "unknown rule %q", route.Rule)
+		}
+	}
+	return errs.err()
+}
+
+// Mesma verificação para as rotas do modo gateway, em que a regra é opcional
+func CheckRouteConfigRules(routes []RouteConfig, rules RuleConfigs) error {
+	var errs ValidationErrors
+	for i, route := range routes {
+		if _, exists := rules[route.Rule]; route.Rule != "" && !exists {
+			errs.add(fmt.Sprintf("[%d].rule", i), "unknown rule %q", route.Rule)
+		}
+	}
+	return errs.err()
+}
+
+// Valida as rotas do modo gateway
+func validateRoutes(routes []RouteConfig) error {
+	var errs ValidationErrors
+	seen := make(map[string]int, len(routes))
+	for i, route := range routes {
+		path := fmt.Sprintf("[%d]", i)
+		if !strings.HasPrefix(route.Path, "/") {
+			errs.add(path+".path", "must start with / (got %q)", route.Path)
+		} else if first, exists := seen[route.Path]; exists {
+			errs.add(path+".path", "duplicates route [%d] (%q)", first, route.Path)
+		} else {
+			seen[route.Path] = i
+		}
+		if u, err := url.Parse(route.Upstream); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs.add(path+".upstream", "must be an absolute http(s) URL (got %q)", route.Upstream)
+		}
+		if route.StripPrefix != "" && !strings.HasPrefix(route.Path, route.StripPrefix) {
+			errs.add(path+".strip_prefix", "must be a prefix of path %q (got %q)", route.Path, route.StripPrefix)
+		}
+		if route.HealthPath != "" && !strings.HasPrefix(route.HealthPath, "/") {
+			errs.add(path+".health_path", "must start with / (got %q)", route.HealthPath)
+		}
+	}
+	return errs.err()
+}
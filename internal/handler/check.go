@@ -0,0 +1,257 @@
+package handler
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"fc-pos-golang-rate-limiter/internal/limiter"
+	"fc-pos-golang-rate-limiter/pkg/response"
+)
+
+// Tipos de chave aceitos pela API de decisão
+const (
+	KeyTypeIP    = "ip"
+	KeyTypeToken = "token"
+)
+
+// Quantidade máxima de verificações em uma chamada batch
+const MaxBatchChecks = 100
+
+// Tamanho máximo do corpo das requisições da API de decisão
+const MaxRequestBodyBytes = 64 << 10
+
+// Mensagem das falhas do storage; o erro em si fica apenas no log
+const unavailableMessage = "rate limit check unavailable"
+
+// Expõe o RateLimiter como serviço de decisão para aplicações que não usam o middleware
+type CheckHandler struct {
+	rateLimiter *limiter.RateLimiter
+	secret      string
+}
+
+// As chamadas precisam enviar "Authorization: Bearer <secret>". Com secret
+// vazio todas as chamadas são recusadas.
+func NewCheckHandler(rateLimiter *limiter.RateLimiter, secret string) *CheckHandler {
+	return &CheckHandler{rateLimiter: rateLimiter, secret: secret}
+}
+
+type CheckRequest struct {
+	Identifier string `json:"identifier"`
+	KeyType    string `json:"key_type"`
+	Cost       int    `json:"cost,omitempty"`
+	Rule       string `json:"rule,omitempty"`
+	// Tenant isola os contadores; vazio usa RATE_LIMIT_TENANT
+	Tenant string `json:"tenant,omitempty"`
+}
+
+type CheckResponse struct {
+	Allowed       bool      `json:"allowed"`
+	Remaining     int       `json:"remaining"`
+	ResetTime     time.Time `json:"reset_time"`
+	Limit         int       `json:"limit"`
+	WindowSeconds int       `json:"window_seconds"`
+	Identifier    string    `json:"identifier"`
+	KeyType       string    `json:"key_type"`
+	Rule          string    `json:"rule,omitempty"`
+	DryRun        bool      `json:"dry_run"`
+	// Decisão da regra em dry-run avaliada junto com o limite aplicado
+	Shadow *CheckResponse `json:"shadow,omitempty"`
+}
+
+type BatchCheckRequest struct {
+	Checks []CheckRequest `json:"checks"`
+}
+
+// Resultado de um item do batch: a decisão ou o erro daquele item
+type BatchCheckItem struct {
+	*CheckResponse
+	Error string `json:"error,omitempty"`
+}
+
+type BatchCheckResponse struct {
+	Results []BatchCheckItem `json:"results"`
+}
+
+// @Summary Decisão de rate limit
+// @Description Verifica e consome o limite de um identificador (IP ou token), opcionalmente com custo e regra nomeada
+// @Tags decision
+// @Accept json
+// @Produce json
+// @Param request body CheckRequest true "Identificador a verificar"
+// @Success 200 {object} CheckResponse
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 401 {object} response.ErrorResponse
+// @Failure 413 {object} response.ErrorResponse
+// @Failure 503 {object} response.ErrorResponse
+// @Router /v1/check [post]
+func (h *CheckHandler) Check(w http.ResponseWriter, r *http.Request) {
+	var req CheckRequest
+	if !h.decode(w, r, &req) {
+		return
+	}
+
+	result, err := h.evaluate(r, req)
+	if err != nil {
+		status := statusForError(err)
+		response.WriteError(w, status, errorMessage(status, err))
+		return
+	}
+
+	writeJSON(w, http.StatusOK, result)
+}
+
+// @Summary Decisões de rate limit em lote
+// @Description Verifica vários identificadores em uma única chamada. Erros são reportados por item.
+// @Tags decision
+// @Accept json
+// @Produce json
+// @Param request body BatchCheckRequest true "Lista de verificações"
+// @Success 200 {object} BatchCheckResponse
+// @Failure 400 {object} response.ErrorResponse
+// @Failure 401 {object} response.ErrorResponse
+// @Failure 413 {object} response.ErrorResponse
+// @Router /v1/check/batch [post]
+func (h *CheckHandler) CheckBatch(w http.ResponseWriter, r *http.Request) {
+	var req BatchCheckRequest
+	if !h.decode(w, r, &req) {
+		return
+	}
+
+	if len(req.Checks) == 0 {
+		response.WriteError(w, http.StatusBadRequest, "checks must not be empty")
+		return
+	}
+	if len(req.Checks) > MaxBatchChecks {
+		response.WriteError(w, http.StatusBadRequest, fmt.Sprintf("at most %d checks per batch", MaxBatchChecks))
+		return
+	}
+
+	results := make([]BatchCheckItem, len(req.Checks))
+	for i, check := range req.Checks {
+		result, err := h.evaluate(r, check)
+		if err != nil {
+			results[i] = BatchCheckItem{Error: errorMessage(statusForError(err), err)}
+			continue
+		}
+		results[i] = BatchCheckItem{CheckResponse: result}
+	}
+
+	writeJSON(w, http.StatusOK, BatchCheckResponse{Results: results})
+}
+
+var errInvalidCheck = errors.New("invalid check")
+
+// Autentica a chamada e lê o corpo, limitado a MaxRequestBodyBytes. Em caso
+// de falha a resposta já foi escrita.
+func (h *CheckHandler) decode(w http.ResponseWriter, r *http.Request, dst interface{}) bool {
+	if !h.authorized(r) {
+		response.WriteError(w, http.StatusUnauthorized, "invalid or missing credentials")
+		return false
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, MaxRequestBodyBytes)
+	if err := json.NewDecoder(r.Body).Decode(dst); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			response.WriteError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("request body larger than %d bytes", MaxRequestBodyBytes))
+			return false
+		}
+		response.WriteError(w, http.StatusBadRequest, "invalid request body")
+		return false
+	}
+	return true
+}
+
+// Compara o bearer token com o segredo em tempo constante
+func (h *CheckHandler) authorized(r *http.Request) bool {
+	if h.secret == "" {
+		return false
+	}
+	scheme, token, found := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(strings.TrimSpace(token)), []byte(h.secret)) == 1
+}
+
+// Valida e executa uma verificação
+func (h *CheckHandler) evaluate(r *http.Request, req CheckRequest) (*CheckResponse, error) {
+	if req.Identifier == "" {
+		return nil, fmt.Errorf("%w: identifier is required", errInvalidCheck)
+	}
+
+	var isToken bool
+	switch req.KeyType {
+	case KeyTypeIP, "":
+		isToken = false
+	case KeyTypeToken:
+		isToken = true
+	default:
+		return nil, fmt.Errorf("%w: key_type must be %q or %q", errInvalidCheck, KeyTypeIP, KeyTypeToken)
+	}
+
+	result, err := h.rateLimiter.Evaluate(r.Context(), limiter.CheckRequest{
+		Identifier: req.Identifier,
+		IsToken:    isToken,
+		Rule:       req.Rule,
+		Cost:       req.Cost,
+		Tenant:     req.Tenant,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return toCheckResponse(result), nil
+}
+
+func toCheckResponse(result *limiter.CheckResult) *CheckResponse {
+	keyType := KeyTypeIP
+	if result.IsToken {
+		keyType = KeyTypeToken
+	}
+
+	resp := &CheckResponse{
+		Allowed:       result.Allowed,
+		Remaining:     result.Remaining,
+		ResetTime:     result.ResetTime,
+		Limit:         result.Limit,
+		WindowSeconds: int(result.Window.Seconds()),
+		Identifier:    result.Identifier,
+		KeyType:       keyType,
+		Rule:          result.Rule,
+		DryRun:        result.DryRun,
+	}
+	if result.Shadow != nil {
+		resp.Shadow = toCheckResponse(result.Shadow)
+	}
+	return resp
+}
+
+// Erros de entrada viram 400; falhas do storage viram 503
+func statusForError(err error) int {
+	if errors.Is(err, errInvalidCheck) || errors.Is(err, limiter.ErrUnknownRule) || errors.Is(err, limiter.ErrInvalidCost) || errors.Is(err, limiter.ErrInvalidTenant) {
+		return http.StatusBadRequest
+	}
+	return http.StatusServiceUnavailable
+}
+
+// Erros de entrada são devolvidos ao cliente; falhas do storage apenas no log
+func errorMessage(status int, err error) string {
+	if status != http.StatusServiceUnavailable {
+		return err.Error()
+	}
+	log.Printf("Decision API error: %v", err)
+	return unavailableMessage
+}
+
+func writeJSON(w http.ResponseWriter, statusCode int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	_ = json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,162 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"fc-pos-golang-rate-limiter/internal/config"
+	"fc-pos-golang-rate-limiter/internal/limiter"
+	"fc-pos-golang-rate-limiter/internal/limiter/limitertest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Storage em memória simples que conta o custo consumido por chave
+func newTestCheckHandler(storage *limitertest.Storage) *CheckHandler {
+	rateLimiter := limiter.NewRateLimiter(storage, &config.RateLimitConfig{
+		IPLimit:              10,
+		WindowSeconds:        1,
+		BlockDurationSeconds: 300,
+	}, config.TokenConfigs{
+		"test_token": config.TokenConfig{Limit: 100, WindowSeconds: 1, BlockDurationSeconds: 300},
+	})
+	rateLimiter.SetRules(config.RuleConfigs{
+		"search": config.RuleConfig{Limit: 5, WindowSeconds: 60, BlockDurationSeconds: 60},
+	})
+	return NewCheckHandler(rateLimiter, testSecret)
+}
+
+const testSecret = "decision-secret"
+
+func postJSON(handler http.HandlerFunc, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/v1/check", bytes.NewBufferString(body))
+	req.Header.Set("Authorization", "Bearer "+testSecret)
+	rr := httptest.NewRecorder()
+	handler(rr, req)
+	return rr
+}
+
+func TestCheckHandler(t *testing.T) {
+	storage := limitertest.NewStorage()
+	h := newTestCheckHandler(storage)
+
+	t.Run("IP check", func(t *testing.T) {
+		rr := postJSON(h.Check, `{"identifier":"192.168.1.1","key_type":"ip"}`)
+		require.Equal(t, http.StatusOK, rr.Code)
+
+		var resp CheckResponse
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+		assert.True(t, resp.Allowed)
+		assert.Equal(t, 10, resp.Limit)
+		assert.Equal(t, 9, resp.Remaining)
+		assert.Equal(t, 1, resp.WindowSeconds)
+		assert.Equal(t, KeyTypeIP, resp.KeyType)
+	})
+
+	t.Run("Token check with cost", func(t *testing.T) {
+		rr := postJSON(h.Check, `{"identifier":"test_token","key_type":"token","cost":30}`)
+		require.Equal(t, http.StatusOK, rr.Code)
+
+		var resp CheckResponse
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+		assert.True(t, resp.Allowed)
+		assert.Equal(t, 100, resp.Limit)
+		assert.Equal(t, 70, resp.Remaining)
+		assert.Equal(t, KeyTypeToken, resp.KeyType)
+		assert.Equal(t, 30, storage.Used("token:test_token"))
+	})
+
+	t.Run("Rule check over limit", func(t *testing.T) {
+		rr := postJSON(h.Check, `{"identifier":"10.0.0.1","rule":"search","cost":6}`)
+		require.Equal(t, http.StatusOK, rr.Code)
+
+		var resp CheckResponse
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+		assert.False(t, resp.Allowed)
+		assert.Equal(t, "search", resp.Rule)
+		assert.Equal(t, 5, resp.Limit)
+	})
+
+	t.Run("Validation errors", func(t *testing.T) {
+		assert.Equal(t, http.StatusBadRequest, postJSON(h.Check, `not json`).Code)
+		assert.Equal(t, http.StatusBadRequest, postJSON(h.Check, `{"key_type":"ip"}`).Code)
+		assert.Equal(t, http.StatusBadRequest, postJSON(h.Check, `{"identifier":"x","key_type":"user"}`).Code)
+		assert.Equal(t, http.StatusBadRequest, postJSON(h.Check, `{"identifier":"x","rule":"missing"}`).Code)
+		assert.Equal(t, http.StatusBadRequest, postJSON(h.Check, `{"identifier":"x","cost":-1}`).Code)
+		assert.Equal(t, http.StatusBadRequest, postJSON(h.Check, `{"identifier":"x","tenant":"a:b"}`).Code)
+	})
+
+	t.Run("Storage error", func(t *testing.T) {
+		storage.SetError("ip:10.9.9.9", assert.AnError)
+
+		rr := postJSON(h.Check, `{"identifier":"10.9.9.9"}`)
+		assert.Equal(t, http.StatusServiceUnavailable, rr.Code)
+		assert.NotContains(t, rr.Body.String(), assert.AnError.Error())
+	})
+
+	t.Run("Requires the shared secret", func(t *testing.T) {
+		for _, authorization := range []string{"", "Bearer wrong", testSecret} {
+			req := httptest.NewRequest(http.MethodPost, "/v1/check", bytes.NewBufferString(`{"identifier":"10.8.0.1"}`))
+			req.Header.Set("Authorization", authorization)
+			rr := httptest.NewRecorder()
+			h.Check(rr, req)
+			assert.Equal(t, http.StatusUnauthorized, rr.Code, authorization)
+		}
+		assert.Zero(t, storage.Used("ip:10.8.0.1"))
+
+		open := NewCheckHandler(h.rateLimiter, "")
+		req := httptest.NewRequest(http.MethodPost, "/v1/check", bytes.NewBufferString(`{"identifier":"10.8.0.1"}`))
+		req.Header.Set("Authorization", "Bearer ")
+		rr := httptest.NewRecorder()
+		open.Check(rr, req)
+		assert.Equal(t, http.StatusUnauthorized, rr.Code)
+	})
+
+	t.Run("Body size limit", func(t *testing.T) {
+		body := `{"identifier":"` + strings.Repeat("a", MaxRequestBodyBytes) + `"}`
+		assert.Equal(t, http.StatusRequestEntityTooLarge, postJSON(h.Check, body).Code)
+	})
+}
+
+func TestCheckBatchHandler(t *testing.T) {
+	storage := limitertest.NewStorage()
+	h := newTestCheckHandler(storage)
+
+	rr := postJSON(h.CheckBatch, `{"checks":[
+		{"identifier":"192.168.1.1","key_type":"ip"},
+		{"identifier":"test_token","key_type":"token","cost":2},
+		{"identifier":"192.168.1.1","key_type":"invalid"}
+	]}`)
+	require.Equal(t, http.StatusOK, rr.Code)
+
+	var resp BatchCheckResponse
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &resp))
+	require.Len(t, resp.Results, 3)
+
+	require.NotNil(t, resp.Results[0].CheckResponse)
+	assert.True(t, resp.Results[0].Allowed)
+	assert.Equal(t, 9, resp.Results[0].Remaining)
+
+	require.NotNil(t, resp.Results[1].CheckResponse)
+	assert.Equal(t, 98, resp.Results[1].Remaining)
+
+	assert.Nil(t, resp.Results[2].CheckResponse)
+	assert.Contains(t, resp.Results[2].Error, "key_type")
+
+	t.Run("Empty and oversized batches", func(t *testing.T) {
+		assert.Equal(t, http.StatusBadRequest, postJSON(h.CheckBatch, `{"checks":[]}`).Code)
+
+		checks := make([]CheckRequest, MaxBatchChecks+1)
+		for i := range checks {
+			checks[i] = CheckRequest{Identifier: "192.168.1.1"}
+		}
+		body, err := json.Marshal(BatchCheckRequest{Checks: checks})
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusBadRequest, postJSON(h.CheckBatch, string(body)).Code)
+	})
+}
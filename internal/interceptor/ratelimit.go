@@ -0,0 +1,230 @@
+package interceptor
+
+import (
+	"context"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"fc-pos-golang-rate-limiter/internal/config"
+	"fc-pos-golang-rate-limiter/internal/limiter"
+	"fc-pos-golang-rate-limiter/internal/middleware"
+	"fc-pos-golang-rate-limiter/pkg/response"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// Chave de metadata padrão com a chave de API do cliente
+const DefaultMetadataKey = "api_key"
+
+type contextKey string
+
+const (
+	rateLimitInfoKey contextKey = "rate_limit_info"
+)
+
+// Configura o comportamento opcional dos interceptors
+type Option func(*options)
+
+type options struct {
+	headers     config.HeaderConfig
+	rule        string
+	metadataKey string
+}
+
+// Define o modo e os nomes dos trailers de rate limit
+func WithHeaders(cfg config.HeaderConfig) Option {
+	return func(o *options) {
+		o.headers = cfg
+	}
+}
+
+// Aplica uma regra nomeada no lugar dos limites padrão de IP/token
+func WithRule(name string) Option {
+	return func(o *options) {
+		o.rule = name
+	}
+}
+
+// Define a chave de metadata que carrega a chave de API
+func WithMetadataKey(key string) Option {
+	return func(o *options) {
+		o.metadataKey = strings.ToLower(key)
+	}
+}
+
+func newOptions(opts []Option) options {
+	o := options{
+		headers:     config.DefaultHeaderConfig(),
+		metadataKey: DefaultMetadataKey,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// Cria um interceptor unário de rate limiting
+func UnaryServerInterceptor(rateLimiter *limiter.RateLimiter, opts ...Option) grpc.UnaryServerInterceptor {
+	o := newOptions(opts)
+
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		result, err := o.check(ctx, rateLimiter, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+		if result == nil {
+			return handler(ctx, req)
+		}
+
+		if !result.DryRun {
+			if err := grpc.SetTrailer(ctx, o.trailer(result, time.Now())); err != nil {
+				log.Printf("Rate limiter: failed to set trailer: %v", err)
+			}
+		}
+		if !result.Allowed && !result.DryRun {
+			return nil, resourceExhausted(result, time.Now())
+		}
+
+		return handler(context.WithValue(ctx, rateLimitInfoKey, result), req)
+	}
+}
+
+// Cria um interceptor de stream de rate limiting. A verificação é feita uma vez,
+// na abertura do stream.
+func StreamServerInterceptor(rateLimiter *limiter.RateLimiter, opts ...Option) grpc.StreamServerInterceptor {
+	o := newOptions(opts)
+
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		result, err := o.check(ss.Context(), rateLimiter, info.FullMethod)
+		if err != nil {
+			return err
+		}
+		if result == nil {
+			return handler(srv, ss)
+		}
+
+		if !result.DryRun {
+			ss.SetTrailer(o.trailer(result, time.Now()))
+		}
+		if !result.Allowed && !result.DryRun {
+			return resourceExhausted(result, time.Now())
+		}
+
+		return handler(srv, &serverStream{
+			ServerStream: ss,
+			ctx:          context.WithValue(ss.Context(), rateLimitInfoKey, result),
+		})
+	}
+}
+
+// Verifica o limite da chamada. Erros do limiter liberam a chamada (resultado nil).
+func (o options) check(ctx context.Context, rateLimiter *limiter.RateLimiter, method string) (*limiter.CheckResult, error) {
+	identifier, isToken := o.identify(ctx)
+	if identifier == "" {
+		return nil, status.Error(codes.InvalidArgument, "unable to identify client")
+	}
+
+	result, err := rateLimiter.Evaluate(ctx, limiter.CheckRequest{
+		Identifier: identifier,
+		IsToken:    isToken,
+		Rule:       o.rule,
+	})
+	if err != nil {
+		// Loga o erro mas permite que a chamada continue
+		log.Printf("Rate limiter error: %v | Method: %s | Identifier: %s | IsToken: %v",
+			err, method, limiter.SafeIdentifier(identifier, isToken), isToken)
+		return nil, nil
+	}
+
+	// Em dry-run apenas registra a rejeição que teria acontecido
+	if result.DryRun && !result.Allowed {
+		log.Printf("Rate limit dry-run: would reject | Method: %s | Identifier: %s | IsToken: %v | Rule: %s | Limit: %d",
+			method, limiter.SafeIdentifier(identifier, isToken), isToken, result.Rule, result.Limit)
+	}
+	if shadow := result.Shadow; shadow != nil && !shadow.Allowed {
+		log.Printf("Rate limit dry-run: would reject | Method: %s | Identifier: %s | IsToken: %v | Rule: %s | Limit: %d",
+			method, limiter.SafeIdentifier(identifier, isToken), isToken, shadow.Rule, shadow.Limit)
+	}
+
+	return result, nil
+}
+
+// Prioridade: chave de API na metadata > endereço do peer
+func (o options) identify(ctx context.Context) (string, bool) {
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get(o.metadataKey); len(values) > 0 && values[0] != "" {
+			return values[0], true
+		}
+	}
+
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return "", false
+	}
+
+	addr := p.Addr.String()
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host, false
+	}
+	return addr, false
+}
+
+// Converte os headers de rate limit em trailers (chaves em minúsculas)
+func (o options) trailer(result *limiter.CheckResult, now time.Time) metadata.MD {
+	h := http.Header{}
+	middleware.SetRateLimitHeaders(h, o.headers, result, now)
+	if !result.Allowed {
+		h.Set("Retry-After", strconv.Itoa(response.RetryAfterSeconds(result.ResetTime, now)))
+	}
+
+	md := metadata.MD{}
+	for name, values := range h {
+		md.Append(name, values...)
+	}
+	return md
+}
+
+// Status ResourceExhausted com RetryInfo e QuotaFailure nos detalhes
+func resourceExhausted(result *limiter.CheckResult, now time.Time) error {
+	st := status.New(codes.ResourceExhausted, "rate limit exceeded")
+
+	retryAfter := time.Duration(response.RetryAfterSeconds(result.ResetTime, now)) * time.Second
+	detailed, err := st.WithDetails(
+		&errdetails.RetryInfo{RetryDelay: durationpb.New(retryAfter)},
+		&errdetails.QuotaFailure{Violations: []*errdetails.QuotaFailure_Violation{{
+			Subject:     middleware.PolicyName(result),
+			Description: "limit of " + strconv.Itoa(result.Limit) + " requests per " + result.Window.String() + " exceeded",
+		}}},
+	)
+	if err != nil {
+		return st.Err()
+	}
+	return detailed.Err()
+}
+
+// Stream com o contexto enriquecido com o resultado do rate limit
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
+
+func GetRateLimitInfo(ctx context.Context) *limiter.CheckResult {
+	if info, ok := ctx.Value(rateLimitInfoKey).(*limiter.CheckResult); ok {
+		return info
+	}
+	return nil
+}
@@ -0,0 +1,140 @@
+package interceptor
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"fc-pos-golang-rate-limiter/internal/config"
+	"fc-pos-golang-rate-limiter/internal/limiter"
+	"fc-pos-golang-rate-limiter/internal/limiter/limitertest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func newTestClient(t *testing.T, rateLimiter *limiter.RateLimiter, opts ...Option) healthpb.HealthClient {
+	listener := bufconn.Listen(1024 * 1024)
+	grpcServer := grpc.NewServer(
+		grpc.UnaryInterceptor(UnaryServerInterceptor(rateLimiter, opts...)),
+		grpc.StreamInterceptor(StreamServerInterceptor(rateLimiter, opts...)),
+	)
+	healthpb.RegisterHealthServer(grpcServer, health.NewServer())
+	go func() { _ = grpcServer.Serve(listener) }()
+	t.Cleanup(grpcServer.Stop)
+
+	conn, err := grpc.DialContext(context.Background(), "bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+
+	return healthpb.NewHealthClient(conn)
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	mockStorage := limitertest.NewStorage()
+	rateLimiter := limiter.NewRateLimiter(mockStorage, &config.RateLimitConfig{
+		IPLimit:              5,
+		WindowSeconds:        1,
+		BlockDurationSeconds: 300,
+	}, config.TokenConfigs{
+		"test_token": config.TokenConfig{Limit: 10, WindowSeconds: 1, BlockDurationSeconds: 300},
+	})
+	client := newTestClient(t, rateLimiter)
+
+	t.Run("Peer address is used without api_key", func(t *testing.T) {
+		var trailer metadata.MD
+		_, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{}, grpc.Trailer(&trailer))
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{"5"}, trailer.Get("X-RateLimit-Limit"))
+		assert.Equal(t, []string{"4"}, trailer.Get("X-RateLimit-Remaining"))
+		// bufconn usa "bufconn" como endereço do peer
+		assert.Equal(t, 1, mockStorage.Calls("ip:bufconn"))
+	})
+
+	t.Run("api_key metadata takes priority", func(t *testing.T) {
+		ctx := metadata.AppendToOutgoingContext(context.Background(), "api_key", "test_token")
+
+		var trailer metadata.MD
+		_, err := client.Check(ctx, &healthpb.HealthCheckRequest{}, grpc.Trailer(&trailer))
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{"10"}, trailer.Get("X-RateLimit-Limit"))
+		assert.Equal(t, 1, mockStorage.Calls("token:test_token"))
+	})
+
+	t.Run("Blocked call returns ResourceExhausted with retry info", func(t *testing.T) {
+		mockStorage.SetResult("token:blocked_token", false)
+		ctx := metadata.AppendToOutgoingContext(context.Background(), "api_key", "blocked_token")
+
+		var trailer metadata.MD
+		_, err := client.Check(ctx, &healthpb.HealthCheckRequest{}, grpc.Trailer(&trailer))
+		require.Error(t, err)
+
+		st := status.Convert(err)
+		assert.Equal(t, codes.ResourceExhausted, st.Code())
+
+		var retryInfo *errdetails.RetryInfo
+		for _, detail := range st.Details() {
+			if ri, ok := detail.(*errdetails.RetryInfo); ok {
+				retryInfo = ri
+			}
+		}
+		require.NotNil(t, retryInfo)
+		assert.Equal(t, 300*time.Second, retryInfo.RetryDelay.AsDuration())
+		assert.Equal(t, []string{"300"}, trailer.Get("Retry-After"))
+		assert.Equal(t, []string{"0"}, trailer.Get("X-RateLimit-Remaining"))
+	})
+}
+
+func TestStreamServerInterceptor(t *testing.T) {
+	mockStorage := limitertest.NewStorage()
+	rateLimiter := limiter.NewRateLimiter(mockStorage, &config.RateLimitConfig{
+		IPLimit:              5,
+		WindowSeconds:        1,
+		BlockDurationSeconds: 300,
+	}, nil)
+	rateLimiter.SetRules(config.RuleConfigs{
+		"streams": config.RuleConfig{Limit: 2, WindowSeconds: 60, BlockDurationSeconds: 60},
+	})
+	client := newTestClient(t, rateLimiter, WithRule("streams"), WithMetadataKey("X-Client-Id"))
+
+	t.Run("Stream is checked once on open", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(metadata.AppendToOutgoingContext(context.Background(), "x-client-id", "client-a"))
+		defer cancel()
+
+		stream, err := client.Watch(ctx, &healthpb.HealthCheckRequest{})
+		require.NoError(t, err)
+
+		_, err = stream.Recv()
+		require.NoError(t, err)
+		assert.Equal(t, 1, mockStorage.Calls("rule:streams:token:client-a"))
+	})
+
+	t.Run("Blocked stream returns ResourceExhausted", func(t *testing.T) {
+		mockStorage.SetResult("rule:streams:token:client-b", false)
+		ctx := metadata.AppendToOutgoingContext(context.Background(), "x-client-id", "client-b")
+
+		stream, err := client.Watch(ctx, &healthpb.HealthCheckRequest{})
+		require.NoError(t, err)
+
+		_, err = stream.Recv()
+		assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+		assert.Equal(t, []string{"2"}, stream.Trailer().Get("X-RateLimit-Limit"))
+	})
+}
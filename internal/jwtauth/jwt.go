@@ -0,0 +1,227 @@
+package jwtauth
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"fc-pos-golang-rate-limiter/internal/config"
+)
+
+// Algoritmos suportados
+const (
+	AlgHS256 = "HS256"
+	AlgRS256 = "RS256"
+)
+
+var (
+	// Retornado quando o token não é um JWS compacto válido
+	ErrMalformedToken = errors.New("malformed token")
+	// Retornado quando o algoritmo não corresponde à chave configurada
+	ErrUnsupportedAlgorithm = errors.New("unsupported algorithm")
+	// Retornado quando a assinatura não confere ou a chave não é encontrada
+	ErrInvalidSignature = errors.New("invalid signature")
+	// Retornado quando exp já passou ou nbf ainda não chegou
+	ErrTokenExpired  = errors.New("token expired")
+	ErrTokenNotValid = errors.New("token not valid yet")
+)
+
+// Claims do payload do JWT
+type Claims map[string]interface{}
+
+// Valor do claim como texto; números são retornados como escritos no JSON
+func (c Claims) String(name string) string {
+	switch v := c[name].(type) {
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	case bool:
+		return strconv.FormatBool(v)
+	default:
+		return ""
+	}
+}
+
+// Valor do claim como inteiro (aceita número ou texto numérico; decimais são truncados)
+func (c Claims) Int(name string) (int, bool) {
+	n, err := strconv.ParseFloat(c.String(name), 64)
+	if err != nil {
+		return 0, false
+	}
+	return int(n), true
+}
+
+type header struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+// Valida a assinatura e a validade temporal de JWTs HS256 ou RS256.
+// O algoritmo aceito é definido pela chave configurada, nunca pelo token.
+type Verifier struct {
+	secret  []byte
+	rsaKeys map[string]*rsa.PublicKey
+	now     func() time.Time
+}
+
+// Verificador HS256 com segredo compartilhado
+func NewHS256Verifier(secret []byte) *Verifier {
+	return &Verifier{secret: secret, now: time.Now}
+}
+
+// Verificador RS256 com uma chave pública
+func NewRS256Verifier(key *rsa.PublicKey) *Verifier {
+	return &Verifier{rsaKeys: map[string]*rsa.PublicKey{"": key}, now: time.Now}
+}
+
+// Verificador RS256 com as chaves de um JWKS, selecionadas pelo kid do token
+func NewJWKSVerifier(keys map[string]*rsa.PublicKey) *Verifier {
+	return &Verifier{rsaKeys: keys, now: time.Now}
+}
+
+// Cria o verificador a partir da configuração: segredo HS256, chave pública
+// RS256 ou arquivo JWKS, nessa ordem de prioridade
+func NewVerifierFromConfig(cfg config.JWTConfig) (*Verifier, error) {
+	switch {
+	case cfg.HMACSecret != "":
+		return NewHS256Verifier([]byte(cfg.HMACSecret)), nil
+	case cfg.PublicKeyFile != "":
+		key, err := LoadRSAPublicKey(cfg.PublicKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		return NewRS256Verifier(key), nil
+	case cfg.JWKSFile != "":
+		keys, err := LoadJWKS(cfg.JWKSFile)
+		if err != nil {
+			return nil, err
+		}
+		return NewJWKSVerifier(keys), nil
+	default:
+		return nil, fmt.Errorf("JWT enabled without HMAC secret, public key or JWKS file")
+	}
+}
+
+// Carrega uma chave pública RSA em PEM (PKIX ou PKCS#1)
+func LoadRSAPublicKey(path string) (*rsa.PublicKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening public key file: %w", err)
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("public key file %s: no PEM block found", path)
+	}
+
+	if key, err := x509.ParsePKCS1PublicKey(block.Bytes); err == nil {
+		return key, nil
+	}
+
+	parsed, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing public key: %w", err)
+	}
+	key, ok := parsed.(*rsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("public key file %s: not an RSA key", path)
+	}
+	return key, nil
+}
+
+// Valida o token e retorna seus claims
+func (v *Verifier) Verify(token string) (Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrMalformedToken
+	}
+
+	var h header
+	if err := decodeSegment(parts[0], &h); err != nil {
+		return nil, err
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrMalformedToken
+	}
+	signed := []byte(parts[0] + "." + parts[1])
+
+	if err := v.verifySignature(h, signed, signature); err != nil {
+		return nil, err
+	}
+
+	var claims Claims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, err
+	}
+
+	now := v.now()
+	if exp, ok := claims.Int("exp"); ok && !now.Before(time.Unix(int64(exp), 0)) {
+		return nil, ErrTokenExpired
+	}
+	if nbf, ok := claims.Int("nbf"); ok && now.Before(time.Unix(int64(nbf), 0)) {
+		return nil, ErrTokenNotValid
+	}
+
+	return claims, nil
+}
+
+func (v *Verifier) verifySignature(h header, signed, signature []byte) error {
+	switch {
+	case h.Alg == AlgHS256 && v.secret != nil:
+		mac := hmac.New(sha256.New, v.secret)
+		mac.Write(signed)
+		if !hmac.Equal(mac.Sum(nil), signature) {
+			return ErrInvalidSignature
+		}
+		return nil
+
+	case h.Alg == AlgRS256 && v.rsaKeys != nil:
+		key, ok := v.rsaKeys[h.Kid]
+		if !ok && len(v.rsaKeys) == 1 {
+			// Chave única: o kid do token é irrelevante
+			for _, only := range v.rsaKeys {
+				key, ok = only, true
+			}
+		}
+		if !ok {
+			return fmt.Errorf("%w: unknown kid %q", ErrInvalidSignature, h.Kid)
+		}
+
+		digest := sha256.Sum256(signed)
+		if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature); err != nil {
+			return ErrInvalidSignature
+		}
+		return nil
+
+	default:
+		return fmt.Errorf("%w: %q", ErrUnsupportedAlgorithm, h.Alg)
+	}
+}
+
+func decodeSegment(segment string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return ErrMalformedToken
+	}
+
+	decoder := json.NewDecoder(strings.NewReader(string(data)))
+	decoder.UseNumber()
+	if err := decoder.Decode(v); err != nil {
+		return ErrMalformedToken
+	}
+	return nil
+}
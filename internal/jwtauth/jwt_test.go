@@ -0,0 +1,145 @@
+package jwtauth
+
+import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"fc-pos-golang-rate-limiter/internal/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func encodeSegment(t *testing.T, v interface{}) string {
+	data, err := json.Marshal(v)
+	require.NoError(t, err)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func signHS256(t *testing.T, secret []byte, claims map[string]interface{}) string {
+	signed := encodeSegment(t, map[string]string{"alg": AlgHS256, "typ": "JWT"}) + "." + encodeSegment(t, claims)
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(signed))
+	return signed + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func signRS256(t *testing.T, key *rsa.PrivateKey, kid string, claims map[string]interface{}) string {
+	signed := encodeSegment(t, map[string]string{"alg": AlgRS256, "typ": "JWT", "kid": kid}) + "." + encodeSegment(t, claims)
+	digest := sha256.Sum256([]byte(signed))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	require.NoError(t, err)
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func TestVerifyHS256(t *testing.T) {
+	secret := []byte("test-secret")
+	verifier := NewHS256Verifier(secret)
+	future := time.Now().Add(time.Hour).Unix()
+
+	t.Run("Valid token", func(t *testing.T) {
+		claims, err := verifier.Verify(signHS256(t, secret, map[string]interface{}{
+			"sub": "user-1", "plan": "pro", "limit": 500, "exp": future,
+		}))
+		require.NoError(t, err)
+
+		assert.Equal(t, "user-1", claims.String("sub"))
+		assert.Equal(t, "pro", claims.String("plan"))
+		limit, ok := claims.Int("limit")
+		assert.True(t, ok)
+		assert.Equal(t, 500, limit)
+	})
+
+	t.Run("Wrong secret", func(t *testing.T) {
+		_, err := verifier.Verify(signHS256(t, []byte("other"), map[string]interface{}{"sub": "user-1"}))
+		assert.ErrorIs(t, err, ErrInvalidSignature)
+	})
+
+	t.Run("Expired and not yet valid", func(t *testing.T) {
+		_, err := verifier.Verify(signHS256(t, secret, map[string]interface{}{"sub": "user-1", "exp": time.Now().Add(-time.Minute).Unix()}))
+		assert.ErrorIs(t, err, ErrTokenExpired)
+
+		_, err = verifier.Verify(signHS256(t, secret, map[string]interface{}{"sub": "user-1", "nbf": future}))
+		assert.ErrorIs(t, err, ErrTokenNotValid)
+	})
+
+	t.Run("Unsigned and malformed tokens", func(t *testing.T) {
+		unsigned := encodeSegment(t, map[string]string{"alg": "none"}) + "." + encodeSegment(t, map[string]string{"sub": "user-1"}) + "."
+		_, err := verifier.Verify(unsigned)
+		assert.ErrorIs(t, err, ErrUnsupportedAlgorithm)
+
+		_, err = verifier.Verify("not-a-jwt")
+		assert.ErrorIs(t, err, ErrMalformedToken)
+	})
+}
+
+func TestVerifyRS256(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	claims := map[string]interface{}{"sub": "user-2", "org_id": "acme"}
+
+	t.Run("Public key file", func(t *testing.T) {
+		der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+		require.NoError(t, err)
+		path := filepath.Join(t.TempDir(), "public.pem")
+		require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0o600))
+
+		verifier, err := NewVerifierFromConfig(config.JWTConfig{PublicKeyFile: path})
+		require.NoError(t, err)
+
+		parsed, err := verifier.Verify(signRS256(t, key, "", claims))
+		require.NoError(t, err)
+		assert.Equal(t, "acme", parsed.String("org_id"))
+
+		// Um token HS256 assinado com a chave pública não é aceito por um verificador RSA
+		_, err = verifier.Verify(signHS256(t, der, claims))
+		assert.ErrorIs(t, err, ErrUnsupportedAlgorithm)
+	})
+
+	t.Run("JWKS file", func(t *testing.T) {
+		other, err := rsa.GenerateKey(rand.Reader, 2048)
+		require.NoError(t, err)
+
+		jwk := func(kid string, pub *rsa.PublicKey) map[string]string {
+			return map[string]string{
+				"kty": "RSA",
+				"kid": kid,
+				"use": "sig",
+				"n":   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+			}
+		}
+		data, err := json.Marshal(map[string]interface{}{"keys": []interface{}{jwk("k1", &key.PublicKey), jwk("k2", &other.PublicKey)}})
+		require.NoError(t, err)
+		path := filepath.Join(t.TempDir(), "jwks.json")
+		require.NoError(t, os.WriteFile(path, data, 0o600))
+
+		verifier, err := NewVerifierFromConfig(config.JWTConfig{JWKSFile: path})
+		require.NoError(t, err)
+
+		_, err = verifier.Verify(signRS256(t, other, "k2", claims))
+		assert.NoError(t, err)
+
+		_, err = verifier.Verify(signRS256(t, other, "k1", claims))
+		assert.ErrorIs(t, err, ErrInvalidSignature)
+
+		_, err = verifier.Verify(signRS256(t, key, "unknown", claims))
+		assert.ErrorIs(t, err, ErrInvalidSignature)
+	})
+
+	t.Run("Missing key configuration", func(t *testing.T) {
+		_, err := NewVerifierFromConfig(config.JWTConfig{})
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,273 @@
+package limiter
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// Defaults do modo aproximado
+const (
+	DefaultBatchSyncInterval = 100 * time.Millisecond
+	DefaultBatchLeaseSize    = 10
+)
+
+// Storage usado pelo BatchStrategy: verifica com custo e registra o consumo
+// concedido localmente
+type BatchStorage interface {
+	StorageStrategy
+	CostStrategy
+	RecordStrategy
+}
+
+// Modo aproximado: cada instância concede localmente até LeaseSize unidades
+// por chave entre as sincronizações e envia o consumo ao storage em lote a cada
+// SyncInterval. Com N instâncias, uma chave pode exceder o limite em até
+// N × LeaseSize unidades por janela, em troca de uma operação no storage a cada
+// LeaseSize requisições em vez de uma por requisição.
+type BatchStrategy struct {
+	storage      BatchStorage
+	syncInterval time.Duration
+	leaseSize    int
+	now          func() time.Time
+
+	mu     sync.Mutex
+	leases map[string]*lease
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// Saldo local de uma chave desde a última sincronização
+type lease struct {
+	mu sync.Mutex
+
+	limit         int
+	window        time.Duration
+	blockDuration time.Duration
+
+	// budget é quanto ainda pode ser concedido sem consultar o storage;
+	// pending é o consumo concedido ainda não enviado
+	budget       int
+	pending      int
+	remaining    int
+	resetTime    time.Time
+	blockedUntil time.Time
+	lastUsed     time.Time
+	// evicted indica que a chave saiu do mapa e o saldo não deve mais ser usado
+	evicted bool
+}
+
+type BatchOption func(*BatchStrategy)
+
+// Define o intervalo entre os envios do consumo local ao storage
+func WithBatchSyncInterval(interval time.Duration) BatchOption {
+	return func(b *BatchStrategy) {
+		b.syncInterval = interval
+	}
+}
+
+// Define quantas unidades cada instância concede por chave sem consultar o storage
+func WithBatchLeaseSize(size int) BatchOption {
+	return func(b *BatchStrategy) {
+		b.leaseSize = size
+	}
+}
+
+func NewBatchStrategy(storage BatchStorage, opts ...BatchOption) *BatchStrategy {
+	ctx, cancel := context.WithCancel(context.Background())
+	b := &BatchStrategy{
+		storage:      storage,
+		syncInterval: DefaultBatchSyncInterval,
+		leaseSize:    DefaultBatchLeaseSize,
+		now:          time.Now,
+		leases:       make(map[string]*lease),
+		cancel:       cancel,
+		done:         make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(b)
+	}
+
+	go b.run(ctx)
+
+	return b
+}
+
+func (b *BatchStrategy) Allow(ctx context.Context, key string, limit int, window time.Duration, blockDuration time.Duration) (bool, int, time.Time, error) {
+	return b.AllowN(ctx, key, 1, limit, window, blockDuration)
+}
+
+// Concede localmente enquanto houver saldo; sem saldo, envia o consumo pendente
+// e consulta o storage, renovando o saldo local
+func (b *BatchStrategy) AllowN(ctx context.Context, key string, cost int, limit int, window time.Duration, blockDuration time.Duration) (bool, int, time.Time, error) {
+	l := b.lease(key)
+	defer l.mu.Unlock()
+
+	now := b.now()
+	l.lastUsed = now
+
+	if now.Before(l.blockedUntil) {
+		return false, 0, l.blockedUntil, nil
+	}
+
+	// Limites diferentes (ex.: plano alterado) descartam o saldo local
+	sameLimits := l.limit == limit && l.window == window && l.blockDuration == blockDuration
+	if sameLimits && l.budget >= cost && now.Before(l.resetTime) {
+		l.budget -= cost
+		l.pending += cost
+		l.remaining -= cost
+		if l.remaining < 0 {
+			l.remaining = 0
+		}
+		return true, l.remaining, l.resetTime, nil
+	}
+
+	if err := b.flush(ctx, key, l); err != nil {
+		return false, 0, time.Time{}, err
+	}
+
+	allowed, remaining, resetTime, err := b.storage.AllowN(ctx, key, cost, limit, window, blockDuration)
+	if err != nil {
+		return false, 0, time.Time{}, err
+	}
+
+	l.limit, l.window, l.blockDuration = limit, window, blockDuration
+	l.remaining, l.resetTime = remaining, resetTime
+	l.budget = 0
+	switch {
+	case allowed:
+		l.budget = min(b.leaseSize, remaining)
+	case remaining == 0:
+		// Bloqueada no storage: rejeita localmente até o reset
+		l.blockedUntil = resetTime
+	}
+
+	return allowed, remaining, resetTime, nil
+}
+
+// Descarta o saldo local e reseta a chave no storage. Resets em outras
+// instâncias só são vistos por esta após o saldo local acabar.
+func (b *BatchStrategy) Reset(ctx context.Context, key string) error {
+	b.mu.Lock()
+	l, exists := b.leases[key]
+	delete(b.leases, key)
+	b.mu.Unlock()
+
+	if exists {
+		l.mu.Lock()
+		l.evicted = true
+		l.mu.Unlock()
+	}
+
+	return b.storage.Reset(ctx, key)
+}
+
+// Envia o consumo pendente e fecha o storage
+func (b *BatchStrategy) Close() error {
+	b.cancel()
+	<-b.done
+	return errors.Join(b.Sync(context.Background()), b.storage.Close())
+}
+
+// Envia ao storage o consumo pendente de todas as chaves, renovando o saldo
+// local com o total da janela. Retorna o primeiro erro encontrado.
+func (b *BatchStrategy) Sync(ctx context.Context) error {
+	now := b.now()
+
+	b.mu.Lock()
+	keys := make([]string, 0, len(b.leases))
+	leases := make([]*lease, 0, len(b.leases))
+	for key, l := range b.leases {
+		keys = append(keys, key)
+		leases = append(leases, l)
+	}
+	b.mu.Unlock()
+
+	var firstErr error
+	for i, l := range leases {
+		l.mu.Lock()
+		err := b.flush(ctx, keys[i], l)
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+
+		// Chaves sem uso há mais de uma janela deixam de ocupar memória
+		if l.pending == 0 && now.Sub(l.lastUsed) > l.window && !now.Before(l.blockedUntil) {
+			b.mu.Lock()
+			if b.leases[keys[i]] == l {
+				delete(b.leases, keys[i])
+			}
+			b.mu.Unlock()
+			l.evicted = true
+		}
+		l.mu.Unlock()
+	}
+	return firstErr
+}
+
+// Quantidade de chaves com saldo local
+func (b *BatchStrategy) Len() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.leases)
+}
+
+// Retorna o saldo da chave com l.mu adquirido
+func (b *BatchStrategy) lease(key string) *lease {
+	for {
+		b.mu.Lock()
+		l, exists := b.leases[key]
+		if !exists {
+			l = &lease{}
+			b.leases[key] = l
+		}
+		b.mu.Unlock()
+
+		l.mu.Lock()
+		if !l.evicted {
+			return l
+		}
+		// Removida entre a busca e o lock: busca de novo
+		l.mu.Unlock()
+	}
+}
+
+// Registra o consumo pendente da chave. Deve ser chamado com l.mu adquirido.
+func (b *BatchStrategy) flush(ctx context.Context, key string, l *lease) error {
+	if l.pending == 0 {
+		return nil
+	}
+
+	count, err := b.storage.Record(ctx, key, l.pending, l.window)
+	if err != nil {
+		// O consumo continua pendente e o saldo local é suspenso até o próximo envio
+		l.budget = 0
+		return err
+	}
+	l.pending = 0
+
+	l.remaining = l.limit - count
+	if l.remaining < 0 {
+		l.remaining = 0
+	}
+	l.budget = min(b.leaseSize, l.remaining)
+	return nil
+}
+
+func (b *BatchStrategy) run(ctx context.Context) {
+	defer close(b.done)
+
+	ticker := time.NewTicker(b.syncInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			b.Sync(ctx)
+		}
+	}
+}
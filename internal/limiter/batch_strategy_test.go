@@ -0,0 +1,154 @@
+package limiter
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Storage em memória com contagem simples (sem expiração da janela) que
+// registra quantas operações chegaram a ele
+type countingStorage struct {
+	mu      sync.Mutex
+	counts  map[string]int
+	blocked map[string]bool
+	ops     int
+}
+
+func newCountingStorage() *countingStorage {
+	return &countingStorage{counts: make(map[string]int), blocked: make(map[string]bool)}
+}
+
+func (s *countingStorage) Allow(ctx context.Context, key string, limit int, window time.Duration, blockDuration time.Duration) (bool, int, time.Time, error) {
+	return s.AllowN(ctx, key, 1, limit, window, blockDuration)
+}
+
+func (s *countingStorage) AllowN(ctx context.Context, key string, cost int, limit int, window time.Duration, blockDuration time.Duration) (bool, int, time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ops++
+
+	if s.blocked[key] {
+		return false, 0, time.Now().Add(blockDuration), nil
+	}
+	count := s.counts[key]
+	if count+cost > limit {
+		if count >= limit {
+			s.blocked[key] = true
+			return false, 0, time.Now().Add(blockDuration), nil
+		}
+		return false, limit - count, time.Now().Add(window), nil
+	}
+	s.counts[key] += cost
+	return true, limit - s.counts[key], time.Now().Add(window), nil
+}
+
+func (s *countingStorage) Record(ctx context.Context, key string, cost int, window time.Duration) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ops++
+	s.counts[key] += cost
+	return s.counts[key], nil
+}
+
+func (s *countingStorage) Reset(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.counts, key)
+	delete(s.blocked, key)
+	return nil
+}
+
+func (s *countingStorage) Close() error {
+	return nil
+}
+
+func TestBatchStrategy(t *testing.T) {
+	ctx := context.Background()
+	window := time.Minute
+	blockDuration := time.Minute
+
+	t.Run("Grants locally and records in batches", func(t *testing.T) {
+		storage := newCountingStorage()
+		batch := NewBatchStrategy(storage, WithBatchLeaseSize(10), WithBatchSyncInterval(time.Hour))
+
+		for i := 0; i < 23; i++ {
+			allowed, _, _, err := batch.Allow(ctx, "token:pro", 100, window, blockDuration)
+			require.NoError(t, err)
+			assert.True(t, allowed)
+		}
+		// 1 verificação e 10 concessões locais; depois, a cada 11 requisições,
+		// o envio do lote e 1 verificação: 5 operações no lugar de 23
+		assert.Equal(t, 5, storage.ops)
+
+		require.NoError(t, batch.Sync(ctx))
+		assert.Equal(t, 23, storage.counts["token:pro"])
+
+		require.NoError(t, batch.Close())
+	})
+
+	t.Run("Overshoot is bounded by instances times lease size", func(t *testing.T) {
+		storage := newCountingStorage()
+		instances := []*BatchStrategy{
+			NewBatchStrategy(storage, WithBatchLeaseSize(5), WithBatchSyncInterval(time.Hour)),
+			NewBatchStrategy(storage, WithBatchLeaseSize(5), WithBatchSyncInterval(time.Hour)),
+		}
+
+		limit := 20
+		allowedTotal := 0
+		for i := 0; i < 100; i++ {
+			allowed, _, _, err := instances[i%2].Allow(ctx, "ip:1.2.3.4", limit, window, blockDuration)
+			require.NoError(t, err)
+			if allowed {
+				allowedTotal++
+			}
+		}
+
+		assert.GreaterOrEqual(t, allowedTotal, limit)
+		assert.LessOrEqual(t, allowedTotal, limit+2*5)
+		assert.True(t, storage.blocked["ip:1.2.3.4"])
+
+		for _, instance := range instances {
+			require.NoError(t, instance.Close())
+		}
+	})
+
+	t.Run("Reset discards the local lease", func(t *testing.T) {
+		storage := newCountingStorage()
+		batch := NewBatchStrategy(storage, WithBatchLeaseSize(5), WithBatchSyncInterval(time.Hour))
+		defer batch.Close()
+
+		for i := 0; i < 4; i++ {
+			batch.Allow(ctx, "ip:5.6.7.8", 3, window, blockDuration)
+		}
+		allowed, _, _, _ := batch.Allow(ctx, "ip:5.6.7.8", 3, window, blockDuration)
+		assert.False(t, allowed)
+
+		require.NoError(t, batch.Reset(ctx, "ip:5.6.7.8"))
+		allowed, remaining, _, err := batch.Allow(ctx, "ip:5.6.7.8", 3, window, blockDuration)
+		require.NoError(t, err)
+		assert.True(t, allowed)
+		assert.Equal(t, 2, remaining)
+	})
+
+	t.Run("Idle keys are dropped on sync", func(t *testing.T) {
+		storage := newCountingStorage()
+		batch := NewBatchStrategy(storage, WithBatchSyncInterval(time.Hour))
+		defer batch.Close()
+
+		now := time.Now()
+		batch.now = func() time.Time { return now }
+		batch.Allow(ctx, "ip:9.9.9.9", 10, time.Second, blockDuration)
+		batch.Allow(ctx, "ip:9.9.9.9", 10, time.Second, blockDuration)
+		assert.Equal(t, 1, batch.Len())
+
+		now = now.Add(2 * time.Second)
+		require.NoError(t, batch.Sync(ctx))
+		assert.Equal(t, 0, batch.Len())
+		assert.Equal(t, 2, storage.counts["ip:9.9.9.9"])
+	})
+}
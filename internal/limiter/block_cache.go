@@ -0,0 +1,199 @@
+package limiter
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Quantidade padrão de bloqueios mantidos em memória
+const DefaultBlockCacheMaxEntries = 100000
+
+// Propaga os resets entre as instâncias que usam BlockCacheStrategy.
+// Uma chave vazia invalida o cache inteiro.
+type BlockInvalidator interface {
+	// Publish avisa todas as instâncias (inclusive a atual) que a chave foi resetada
+	Publish(ctx context.Context, key string) error
+	// Subscribe chama invalidate para cada chave publicada até ctx ser cancelado.
+	// Deve chamar invalidate("") sempre que a assinatura for (re)estabelecida,
+	// já que mensagens podem ter sido perdidas.
+	Subscribe(ctx context.Context, invalidate func(key string)) error
+}
+
+// Decorator que guarda em memória os bloqueios conhecidos até o reset, rejeitando
+// as requisições de clientes bloqueados sem consultar o storage
+type BlockCacheStrategy struct {
+	storage     StorageStrategy
+	invalidator BlockInvalidator
+	maxEntries  int
+	now         func() time.Time
+
+	mu     sync.RWMutex
+	blocks map[string]time.Time
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+type BlockCacheOption func(*BlockCacheStrategy)
+
+// Limita a quantidade de bloqueios em memória; acima dele os novos bloqueios
+// não são cacheados
+func WithBlockCacheMaxEntries(maxEntries int) BlockCacheOption {
+	return func(c *BlockCacheStrategy) {
+		c.maxEntries = maxEntries
+	}
+}
+
+// Propaga os resets para as outras instâncias. Sem invalidator o Reset só
+// limpa o cache local.
+func WithBlockInvalidator(invalidator BlockInvalidator) BlockCacheOption {
+	return func(c *BlockCacheStrategy) {
+		c.invalidator = invalidator
+	}
+}
+
+func NewBlockCacheStrategy(storage StorageStrategy, opts ...BlockCacheOption) *BlockCacheStrategy {
+	c := &BlockCacheStrategy{
+		storage:    storage,
+		maxEntries: DefaultBlockCacheMaxEntries,
+		now:        time.Now,
+		blocks:     make(map[string]time.Time),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	if c.invalidator != nil {
+		ctx, cancel := context.WithCancel(context.Background())
+		c.cancel = cancel
+		c.done = make(chan struct{})
+		go func() {
+			defer close(c.done)
+			c.invalidator.Subscribe(ctx, c.invalidate)
+		}()
+	}
+
+	return c
+}
+
+func (c *BlockCacheStrategy) Allow(ctx context.Context, key string, limit int, window time.Duration, blockDuration time.Duration) (bool, int, time.Time, error) {
+	if resetTime, blocked := c.cached(key); blocked {
+		return false, 0, resetTime, nil
+	}
+
+	allowed, remaining, resetTime, err := c.storage.Allow(ctx, key, limit, window, blockDuration)
+	c.remember(key, allowed, remaining, resetTime, err)
+	return allowed, remaining, resetTime, err
+}
+
+// Repassa o custo ao storage, que precisa implementar CostStrategy
+func (c *BlockCacheStrategy) AllowN(ctx context.Context, key string, cost int, limit int, window time.Duration, blockDuration time.Duration) (bool, int, time.Time, error) {
+	if resetTime, blocked := c.cached(key); blocked {
+		return false, 0, resetTime, nil
+	}
+
+	costStorage, ok := c.storage.(CostStrategy)
+	if !ok {
+		return false, 0, time.Time{}, fmt.Errorf("%w: storage does not support cost %d", ErrInvalidCost, cost)
+	}
+	allowed, remaining, resetTime, err := costStorage.AllowN(ctx, key, cost, limit, window, blockDuration)
+	c.remember(key, allowed, remaining, resetTime, err)
+	return allowed, remaining, resetTime, err
+}
+
+// Reseta a chave no storage e invalida o cache local e o das outras instâncias
+func (c *BlockCacheStrategy) Reset(ctx context.Context, key string) error {
+	if err := c.storage.Reset(ctx, key); err != nil {
+		return err
+	}
+
+	c.invalidate(key)
+	if c.invalidator != nil {
+		if err := c.invalidator.Publish(ctx, key); err != nil {
+			return fmt.Errorf("failed to publish block cache invalidation: %w", err)
+		}
+	}
+	return nil
+}
+
+// Encerra a assinatura das invalidações e fecha o storage
+func (c *BlockCacheStrategy) Close() error {
+	if c.cancel != nil {
+		c.cancel()
+		<-c.done
+	}
+	return c.storage.Close()
+}
+
+// Quantidade de bloqueios em memória, incluindo os já expirados ainda não removidos
+func (c *BlockCacheStrategy) Len() int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return len(c.blocks)
+}
+
+// Retorna o reset do bloqueio cacheado, se ainda estiver valendo
+func (c *BlockCacheStrategy) cached(key string) (time.Time, bool) {
+	c.mu.RLock()
+	resetTime, exists := c.blocks[key]
+	c.mu.RUnlock()
+
+	if !exists {
+		return time.Time{}, false
+	}
+	if !c.now().Before(resetTime) {
+		c.mu.Lock()
+		if c.blocks[key] == resetTime {
+			delete(c.blocks, key)
+		}
+		c.mu.Unlock()
+		return time.Time{}, false
+	}
+
+	recordBlockCache(MetricBlockCacheHits)
+	return resetTime, true
+}
+
+// Guarda a resposta do storage quando ela indica um bloqueio. Negações por
+// custo acima do saldo (remaining > 0) não bloqueiam a chave e não são cacheadas.
+func (c *BlockCacheStrategy) remember(key string, allowed bool, remaining int, resetTime time.Time, err error) {
+	if err != nil || allowed || remaining > 0 || !c.now().Before(resetTime) {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, exists := c.blocks[key]; !exists && len(c.blocks) >= c.maxEntries {
+		c.evictExpired()
+		if len(c.blocks) >= c.maxEntries {
+			return
+		}
+	}
+	c.blocks[key] = resetTime
+}
+
+// Remove os bloqueios já expirados. Deve ser chamado com o lock adquirido.
+func (c *BlockCacheStrategy) evictExpired() {
+	now := c.now()
+	for key, resetTime := range c.blocks {
+		if !now.Before(resetTime) {
+			delete(c.blocks, key)
+		}
+	}
+}
+
+// Remove a chave do cache; chave vazia limpa o cache inteiro
+func (c *BlockCacheStrategy) invalidate(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if key == "" {
+		c.blocks = make(map[string]time.Time)
+	} else {
+		delete(c.blocks, key)
+	}
+	recordBlockCache(MetricBlockCacheInvalidations)
+}
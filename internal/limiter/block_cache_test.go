@@ -0,0 +1,146 @@
+package limiter
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Simula o pub/sub entre instâncias em memória
+type memoryInvalidator struct {
+	mu          sync.Mutex
+	subscribers []func(key string)
+	subscribed  chan struct{}
+}
+
+func newMemoryInvalidator() *memoryInvalidator {
+	return &memoryInvalidator{subscribed: make(chan struct{}, 10)}
+}
+
+func (m *memoryInvalidator) Publish(ctx context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, fn := range m.subscribers {
+		fn(key)
+	}
+	return nil
+}
+
+func (m *memoryInvalidator) Subscribe(ctx context.Context, invalidate func(key string)) error {
+	m.mu.Lock()
+	m.subscribers = append(m.subscribers, invalidate)
+	m.mu.Unlock()
+	invalidate("")
+	m.subscribed <- struct{}{}
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestBlockCacheStrategy(t *testing.T) {
+	ctx := context.Background()
+	window := time.Second
+	blockDuration := time.Minute
+
+	t.Run("Blocked keys are served from memory until reset", func(t *testing.T) {
+		storage := NewMockStorageStrategy()
+		cache := NewBlockCacheStrategy(storage)
+		defer cache.Close()
+
+		now := time.Now()
+		cache.now = func() time.Time { return now }
+
+		// Remaining 0 com negação indica bloqueio
+		storage.SetAllowResult("ip:1.1.1.1", false, 10)
+		allowed, _, _, err := cache.Allow(ctx, "ip:1.1.1.1", 10, window, blockDuration)
+		require.NoError(t, err)
+		assert.False(t, allowed)
+		assert.Equal(t, 1, cache.Len())
+
+		hits := BlockCacheCount(MetricBlockCacheHits)
+		for i := 0; i < 5; i++ {
+			allowed, remaining, _, err := cache.Allow(ctx, "ip:1.1.1.1", 10, window, blockDuration)
+			require.NoError(t, err)
+			assert.False(t, allowed)
+			assert.Equal(t, 0, remaining)
+		}
+		assert.Equal(t, 1, storage.GetCallCount("ip:1.1.1.1"))
+		assert.Equal(t, hits+5, BlockCacheCount(MetricBlockCacheHits))
+
+		// Após o reset do bloqueio o storage volta a ser consultado
+		now = now.Add(window + time.Hour)
+		storage.SetAllowResult("ip:1.1.1.1", true, 0)
+		allowed, _, _, err = cache.Allow(ctx, "ip:1.1.1.1", 10, window, blockDuration)
+		require.NoError(t, err)
+		assert.True(t, allowed)
+		assert.Equal(t, 2, storage.GetCallCount("ip:1.1.1.1"))
+		assert.Equal(t, 0, cache.Len())
+	})
+
+	t.Run("Allowed requests, cost denials and errors are not cached", func(t *testing.T) {
+		storage := NewMockStorageStrategy()
+		cache := NewBlockCacheStrategy(storage)
+		defer cache.Close()
+
+		_, _, _, err := cache.Allow(ctx, "ip:2.2.2.2", 10, window, blockDuration)
+		require.NoError(t, err)
+
+		storage.SetAllowResult("ip:3.3.3.3", false, 5)
+		_, _, _, err = cache.Allow(ctx, "ip:3.3.3.3", 10, window, blockDuration)
+		require.NoError(t, err)
+
+		storage.SetAllowError("ip:4.4.4.4", assert.AnError)
+		_, _, _, err = cache.Allow(ctx, "ip:4.4.4.4", 10, window, blockDuration)
+		assert.ErrorIs(t, err, assert.AnError)
+
+		assert.Equal(t, 0, cache.Len())
+	})
+
+	t.Run("Max entries", func(t *testing.T) {
+		storage := NewMockStorageStrategy()
+		cache := NewBlockCacheStrategy(storage, WithBlockCacheMaxEntries(1))
+		defer cache.Close()
+
+		storage.SetAllowResult("ip:5.5.5.5", false, 10)
+		storage.SetAllowResult("ip:6.6.6.6", false, 10)
+		cache.Allow(ctx, "ip:5.5.5.5", 10, window, blockDuration)
+		cache.Allow(ctx, "ip:6.6.6.6", 10, window, blockDuration)
+		assert.Equal(t, 1, cache.Len())
+	})
+
+	t.Run("Reset invalidates every instance", func(t *testing.T) {
+		bus := newMemoryInvalidator()
+		storage := NewMockStorageStrategy()
+		first := NewBlockCacheStrategy(storage, WithBlockInvalidator(bus))
+		second := NewBlockCacheStrategy(storage, WithBlockInvalidator(bus))
+		<-bus.subscribed
+		<-bus.subscribed
+
+		storage.SetAllowResult("ip:7.7.7.7", false, 10)
+		first.Allow(ctx, "ip:7.7.7.7", 10, window, blockDuration)
+		second.Allow(ctx, "ip:7.7.7.7", 10, window, blockDuration)
+		assert.Equal(t, 1, second.Len())
+
+		require.NoError(t, first.Reset(ctx, "ip:7.7.7.7"))
+		assert.Equal(t, 0, first.Len())
+		assert.Equal(t, 0, second.Len())
+
+		allowed, _, _, err := second.Allow(ctx, "ip:7.7.7.7", 10, window, blockDuration)
+		require.NoError(t, err)
+		assert.True(t, allowed)
+
+		require.NoError(t, first.Close())
+		require.NoError(t, second.Close())
+	})
+
+	t.Run("Cost requires a cost strategy", func(t *testing.T) {
+		cache := NewBlockCacheStrategy(NewMockStorageStrategy())
+		defer cache.Close()
+
+		_, _, _, err := cache.AllowN(ctx, "ip:8.8.8.8", 2, 10, window, blockDuration)
+		assert.ErrorIs(t, err, ErrInvalidCost)
+	})
+}
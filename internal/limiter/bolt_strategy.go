@@ -0,0 +1,326 @@
+package limiter
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// Intervalo padrão da compactação das janelas expiradas
+const DefaultBoltCompactInterval = time.Minute
+
+// Bucket com um registro por chave
+var boltBucket = []byte("rate_limiter")
+
+// Storage persistente de um único nó em um arquivo bbolt. Usa a mesma janela
+// deslizante do RedisStrategy e mantém os bloqueios entre reinícios do
+// processo. O arquivo fica travado enquanto aberto: apenas um processo por vez.
+type BoltStrategy struct {
+	db              *bolt.DB
+	compactInterval time.Duration
+	noSync          bool
+	now             func() time.Time
+
+	cancel    context.CancelFunc
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// Registro de uma chave: o bloqueio, a janela e as requisições dentro dela
+// (instante e custo), em ordem de chegada
+type boltRecord struct {
+	blockedUntil int64
+	window       int64
+	hits         []boltHit
+}
+
+type boltHit struct {
+	at   int64
+	cost uint32
+}
+
+type BoltOption func(*BoltStrategy)
+
+// Define o intervalo da remoção das chaves sem requisições na janela e sem bloqueio
+func WithBoltCompactInterval(interval time.Duration) BoltOption {
+	return func(b *BoltStrategy) {
+		b.compactInterval = interval
+	}
+}
+
+// Desliga o fsync de cada transação. Uma queda do processo não perde dados,
+// mas uma queda do sistema operacional pode perder as contagens e os bloqueios
+// mais recentes ou corromper o arquivo, que então precisa ser removido.
+func WithBoltNoSync(noSync bool) BoltOption {
+	return func(b *BoltStrategy) {
+		b.noSync = noSync
+	}
+}
+
+// Abre (ou cria) o arquivo em path. Falha se outro processo estiver com ele aberto.
+func NewBoltStrategy(path string, opts ...BoltOption) (*BoltStrategy, error) {
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, fmt.Errorf("failed to create bolt directory: %w", err)
+		}
+	}
+
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open bolt file %s: %w", path, err)
+	}
+	if err := db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(boltBucket)
+		return err
+	}); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create bolt bucket: %w", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	b := &BoltStrategy{
+		db:              db,
+		compactInterval: DefaultBoltCompactInterval,
+		now:             time.Now,
+		cancel:          cancel,
+		done:            make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(b)
+	}
+	db.NoSync = b.noSync
+
+	go b.run(ctx)
+
+	return b, nil
+}
+
+func (b *BoltStrategy) Allow(ctx context.Context, key string, limit int, window time.Duration, blockDuration time.Duration) (bool, int, time.Time, error) {
+	return b.AllowN(ctx, key, 1, limit, window, blockDuration)
+}
+
+// Verifica e consome cost unidades em uma única transação. Sem WithBoltNoSync
+// cada transação faz um fsync; db.Batch não é usado porque a espera pelo lote
+// (MaxBatchDelay) atrasa todas as requisições quando há pouca concorrência.
+func (b *BoltStrategy) AllowN(ctx context.Context, key string, cost int, limit int, window time.Duration, blockDuration time.Duration) (bool, int, time.Time, error) {
+	var allowed bool
+	var remaining int
+	var resetTime time.Time
+
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(boltBucket)
+		record, err := decodeBoltRecord(bucket.Get([]byte(key)))
+		if err != nil {
+			return fmt.Errorf("key %s: %w", key, err)
+		}
+
+		now := b.now()
+		if blockedUntil := time.Unix(0, record.blockedUntil); now.Before(blockedUntil) {
+			resetTime = blockedUntil
+			return nil
+		}
+
+		record.window = int64(window)
+		record.compact(now)
+		count := record.count()
+
+		if count+cost > limit {
+			// Custo maior que o saldo da janela apenas nega, sem bloquear a chave
+			if count < limit {
+				remaining, resetTime = limit-count, now.Add(window)
+				return nil
+			}
+			record.blockedUntil = now.Add(blockDuration).UnixNano()
+			resetTime = time.Unix(0, record.blockedUntil)
+			// Com bloqueio curto (ou zero) o saldo só volta quando a janela libera
+			if len(record.hits) > 0 {
+				resetTime = time.Unix(0, max(record.blockedUntil, record.hits[0].at+record.window))
+			}
+			return bucket.Put([]byte(key), record.encode())
+		}
+
+		resetTime = now.Add(window)
+		if len(record.hits) > 0 {
+			resetTime = time.Unix(0, record.hits[0].at).Add(window)
+		}
+		record.blockedUntil = 0
+		record.hits = append(record.hits, boltHit{at: now.UnixNano(), cost: uint32(cost)})
+		allowed, remaining = true, limit-count-cost
+		return bucket.Put([]byte(key), record.encode())
+	})
+	if err != nil {
+		return false, 0, time.Time{}, fmt.Errorf("bolt transaction failed: %w", err)
+	}
+	return allowed, remaining, resetTime, nil
+}
+
+// Remove a contagem e o bloqueio da chave
+func (b *BoltStrategy) Reset(ctx context.Context, key string) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltBucket).Delete([]byte(key))
+	})
+}
+
+// Encerra a compactação e fecha o arquivo
+func (b *BoltStrategy) Close() error {
+	var err error
+	b.closeOnce.Do(func() {
+		b.cancel()
+		<-b.done
+		// Sem fsync por transação, grava tudo em disco no encerramento
+		if b.noSync {
+			err = b.db.Sync()
+		}
+		err = errors.Join(err, b.db.Close())
+	})
+	return err
+}
+
+// Remove as requisições fora da janela e as chaves sem requisições e sem
+// bloqueio. Retorna quantas chaves foram removidas.
+func (b *BoltStrategy) Compact(ctx context.Context) (int, error) {
+	removed := 0
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(boltBucket)
+		now := b.now()
+
+		// Alterar o bucket durante a iteração invalida o cursor: as mudanças
+		// são aplicadas depois
+		var deletes [][]byte
+		updates := make(map[string][]byte)
+		err := bucket.ForEach(func(key, value []byte) error {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+
+			record, err := decodeBoltRecord(value)
+			if err != nil {
+				// Registro ilegível não tem como ser usado: descarta
+				deletes = append(deletes, append([]byte(nil), key...))
+				return nil
+			}
+
+			before := len(record.hits)
+			record.compact(now)
+			switch {
+			case len(record.hits) == 0 && !now.Before(time.Unix(0, record.blockedUntil)):
+				deletes = append(deletes, append([]byte(nil), key...))
+			case len(record.hits) != before:
+				updates[string(key)] = record.encode()
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, key := range deletes {
+			if err := bucket.Delete(key); err != nil {
+				return err
+			}
+		}
+		for key, value := range updates {
+			if err := bucket.Put([]byte(key), value); err != nil {
+				return err
+			}
+		}
+		removed = len(deletes)
+		return nil
+	})
+	return removed, err
+}
+
+// Quantidade de chaves no arquivo
+func (b *BoltStrategy) Len() int {
+	n := 0
+	b.db.View(func(tx *bolt.Tx) error {
+		n = tx.Bucket(boltBucket).Stats().KeyN
+		return nil
+	})
+	return n
+}
+
+func (b *BoltStrategy) run(ctx context.Context) {
+	defer close(b.done)
+
+	ticker := time.NewTicker(b.compactInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			b.Compact(ctx)
+		}
+	}
+}
+
+// Descarta as requisições mais antigas que a janela
+func (r *boltRecord) compact(now time.Time) {
+	windowStart := now.UnixNano() - r.window
+	i := 0
+	for i < len(r.hits) && r.hits[i].at <= windowStart {
+		i++
+	}
+	r.hits = r.hits[i:]
+}
+
+func (r *boltRecord) count() int {
+	count := 0
+	for _, hit := range r.hits {
+		count += int(hit.cost)
+	}
+	return count
+}
+
+// Formato: blockedUntil (8 bytes), window (8 bytes) e, por requisição,
+// instante (8 bytes) e custo (4 bytes), em big endian
+const (
+	boltHeaderSize = 16
+	boltHitSize    = 12
+)
+
+var errInvalidBoltRecord = errors.New("invalid bolt record")
+
+func (r *boltRecord) encode() []byte {
+	buf := make([]byte, boltHeaderSize+len(r.hits)*boltHitSize)
+	binary.BigEndian.PutUint64(buf[0:], uint64(r.blockedUntil))
+	binary.BigEndian.PutUint64(buf[8:], uint64(r.window))
+	for i, hit := range r.hits {
+		offset := boltHeaderSize + i*boltHitSize
+		binary.BigEndian.PutUint64(buf[offset:], uint64(hit.at))
+		binary.BigEndian.PutUint32(buf[offset+8:], hit.cost)
+	}
+	return buf
+}
+
+// O valor retornado pelo bbolt só vale durante a transação: os dados são copiados
+func decodeBoltRecord(value []byte) (*boltRecord, error) {
+	record := &boltRecord{}
+	if value == nil {
+		return record, nil
+	}
+	if len(value) < boltHeaderSize || (len(value)-boltHeaderSize)%boltHitSize != 0 {
+		return nil, errInvalidBoltRecord
+	}
+
+	record.blockedUntil = int64(binary.BigEndian.Uint64(value[0:]))
+	record.window = int64(binary.BigEndian.Uint64(value[8:]))
+	record.hits = make([]boltHit, (len(value)-boltHeaderSize)/boltHitSize)
+	for i := range record.hits {
+		offset := boltHeaderSize + i*boltHitSize
+		record.hits[i] = boltHit{
+			at:   int64(binary.BigEndian.Uint64(value[offset:])),
+			cost: binary.BigEndian.Uint32(value[offset+8:]),
+		}
+	}
+	return record, nil
+}
@@ -0,0 +1,119 @@
+package limiter
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBoltStrategy(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "data", "rate_limiter.db")
+
+	t.Run("Block survives restart", func(t *testing.T) {
+		storage, err := NewBoltStrategy(path)
+		require.NoError(t, err)
+
+		for i := 0; i < 2; i++ {
+			allowed, remaining, _, err := storage.Allow(ctx, "ip:1.1.1.1", 2, time.Minute, time.Hour)
+			require.NoError(t, err)
+			assert.True(t, allowed)
+			assert.Equal(t, 1-i, remaining)
+		}
+		allowed, _, blockedUntil, err := storage.Allow(ctx, "ip:1.1.1.1", 2, time.Minute, time.Hour)
+		require.NoError(t, err)
+		assert.False(t, allowed)
+		require.NoError(t, storage.Close())
+
+		// Reabre o mesmo arquivo no meio do bloqueio
+		storage, err = NewBoltStrategy(path)
+		require.NoError(t, err)
+		defer storage.Close()
+
+		allowed, remaining, resetTime, err := storage.Allow(ctx, "ip:1.1.1.1", 2, time.Minute, time.Hour)
+		require.NoError(t, err)
+		assert.False(t, allowed)
+		assert.Equal(t, 0, remaining)
+		assert.True(t, blockedUntil.Equal(resetTime), "expected %s, got %s", blockedUntil, resetTime)
+
+		require.NoError(t, storage.Reset(ctx, "ip:1.1.1.1"))
+		allowed, _, _, err = storage.Allow(ctx, "ip:1.1.1.1", 2, time.Minute, time.Hour)
+		require.NoError(t, err)
+		assert.True(t, allowed)
+	})
+
+	t.Run("Cost over remaining denies without blocking", func(t *testing.T) {
+		storage, err := NewBoltStrategy(filepath.Join(t.TempDir(), "cost.db"))
+		require.NoError(t, err)
+		defer storage.Close()
+
+		allowed, remaining, _, err := storage.AllowN(ctx, "token:abc", 7, 10, time.Minute, time.Hour)
+		require.NoError(t, err)
+		assert.True(t, allowed)
+		assert.Equal(t, 3, remaining)
+
+		allowed, remaining, _, err = storage.AllowN(ctx, "token:abc", 5, 10, time.Minute, time.Hour)
+		require.NoError(t, err)
+		assert.False(t, allowed)
+		assert.Equal(t, 3, remaining)
+
+		allowed, remaining, _, err = storage.AllowN(ctx, "token:abc", 3, 10, time.Minute, time.Hour)
+		require.NoError(t, err)
+		assert.True(t, allowed)
+		assert.Equal(t, 0, remaining)
+	})
+
+	t.Run("NoSync skips fsync and keeps data on close", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "nosync.db")
+		storage, err := NewBoltStrategy(path, WithBoltNoSync(true))
+		require.NoError(t, err)
+		assert.True(t, storage.db.NoSync)
+
+		storage.Allow(ctx, "ip:4.4.4.4", 1, time.Minute, time.Hour)
+		storage.Allow(ctx, "ip:4.4.4.4", 1, time.Minute, time.Hour)
+		require.NoError(t, storage.Close())
+
+		storage, err = NewBoltStrategy(path)
+		require.NoError(t, err)
+		defer storage.Close()
+		assert.False(t, storage.db.NoSync)
+
+		allowed, _, _, err := storage.Allow(ctx, "ip:4.4.4.4", 1, time.Minute, time.Hour)
+		require.NoError(t, err)
+		assert.False(t, allowed)
+	})
+
+	t.Run("Compaction removes expired windows", func(t *testing.T) {
+		storage, err := NewBoltStrategy(filepath.Join(t.TempDir(), "compact.db"), WithBoltCompactInterval(time.Hour))
+		require.NoError(t, err)
+		defer storage.Close()
+
+		now := time.Now()
+		storage.now = func() time.Time { return now }
+
+		storage.Allow(ctx, "ip:2.2.2.2", 5, 10*time.Second, time.Minute)
+		storage.Allow(ctx, "ip:3.3.3.3", 1, 10*time.Second, time.Minute)
+		storage.Allow(ctx, "ip:3.3.3.3", 1, 10*time.Second, time.Minute)
+		assert.Equal(t, 2, storage.Len())
+
+		// A janela expirou, mas o bloqueio de 3.3.3.3 continua
+		now = now.Add(11 * time.Second)
+		removed, err := storage.Compact(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 1, removed)
+		assert.Equal(t, 1, storage.Len())
+
+		allowed, _, _, _ := storage.Allow(ctx, "ip:3.3.3.3", 1, 10*time.Second, time.Minute)
+		assert.False(t, allowed)
+
+		now = now.Add(time.Minute)
+		removed, err = storage.Compact(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 1, removed)
+		assert.Equal(t, 0, storage.Len())
+	})
+}
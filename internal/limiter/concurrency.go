@@ -0,0 +1,265 @@
+package limiter
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"fc-pos-golang-rate-limiter/internal/config"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Validade padrão dos slots; enquanto a requisição executa o slot é renovado
+// a cada metade desse tempo
+const DefaultConcurrencyLeaseTTL = 30 * time.Second
+
+// Retornado por AcquireSlot quando nenhum ConcurrencyStrategy foi configurado
+var ErrConcurrencyDisabled = errors.New("concurrency limiter not configured")
+
+// Controla as requisições simultâneas por chave. Cada slot ocupado é um lease
+// com validade: slots de instâncias que caíram expiram sozinhos.
+type ConcurrencyStrategy interface {
+	// Acquire ocupa um slot se houver menos de limit em uso; id vazio indica
+	// que não há slot livre. inFlight é o total em uso após a tentativa.
+	Acquire(ctx context.Context, key string, limit int, ttl time.Duration) (id string, inFlight int, err error)
+	// Refresh renova a validade de um slot ocupado
+	Refresh(ctx context.Context, key, id string, ttl time.Duration) error
+	// Release libera o slot
+	Release(ctx context.Context, key, id string) error
+}
+
+// Slot de concorrência obtido por AcquireSlot
+type Slot struct {
+	Acquired bool
+	InFlight int
+	Limit    int
+
+	strategy ConcurrencyStrategy
+	key      string
+	id       string
+	stop     chan struct{}
+	once     sync.Once
+}
+
+// Define onde os slots de concorrência ficam e a validade de cada um. Deve ser
+// chamado antes de atender requisições.
+func (rl *RateLimiter) SetConcurrency(strategy ConcurrencyStrategy, leaseTTL time.Duration) {
+	if leaseTTL <= 0 {
+		leaseTTL = DefaultConcurrencyLeaseTTL
+	}
+	rl.concurrency = strategy
+	rl.leaseTTL = leaseTTL
+}
+
+// Ocupa um dos limit slots do cliente (ou da regra). Com o slot ocupado, a
+// validade é renovada até Release; quem chama deve sempre liberar o slot.
+func (rl *RateLimiter) AcquireSlot(ctx context.Context, req CheckRequest, limit int) (*Slot, error) {
+	if rl.concurrency == nil {
+		return nil, ErrConcurrencyDisabled
+	}
+	if !config.ValidKeySegment(req.Tenant) {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidTenant, req.Tenant)
+	}
+
+	key := "inflight:" + rl.requestKey(req)
+	if req.Rule != "" {
+		key = fmt.Sprintf("rule:%s:%s", req.Rule, key)
+	}
+	key = rl.namespace(req.Tenant) + key
+
+	id, inFlight, err := rl.concurrency.Acquire(ctx, key, limit, rl.leaseTTL)
+	if err != nil {
+		recordError()
+		return nil, fmt.Errorf("concurrency acquire failed: %w", err)
+	}
+
+	slot := &Slot{Acquired: id != "", InFlight: inFlight, Limit: limit}
+	if !slot.Acquired {
+		recordConcurrencyRejected()
+		return slot, nil
+	}
+
+	slot.strategy, slot.key, slot.id = rl.concurrency, key, id
+	slot.stop = make(chan struct{})
+	go slot.keepAlive(rl.leaseTTL)
+
+	return slot, nil
+}
+
+// Libera o slot e encerra a renovação. Pode ser chamado mais de uma vez e em
+// slots não obtidos.
+func (s *Slot) Release(ctx context.Context) error {
+	if !s.Acquired {
+		return nil
+	}
+
+	var err error
+	s.once.Do(func() {
+		close(s.stop)
+		err = s.strategy.Release(ctx, s.key, s.id)
+	})
+	return err
+}
+
+// Renova o slot enquanto a requisição executa, para que requisições mais
+// longas que o lease não percam a vaga
+func (s *Slot) keepAlive(ttl time.Duration) {
+	ticker := time.NewTicker(ttl / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), ttl/2)
+			s.strategy.Refresh(ctx, s.key, s.id, ttl)
+			cancel()
+		}
+	}
+}
+
+// Slots em um Sorted Set por chave: o membro é o id do slot e o score, o
+// instante em que ele expira
+type RedisConcurrencyStrategy struct {
+	client redis.UniversalClient
+}
+
+func NewRedisConcurrencyStrategy(client redis.UniversalClient) *RedisConcurrencyStrategy {
+	return &RedisConcurrencyStrategy{client: client}
+}
+
+// Remove os slots expirados, conta os restantes e ocupa um se houver vaga,
+// tudo de forma atômica
+var acquireSlotScript = redis.NewScript(`
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', ARGV[1])
+local inflight = redis.call('ZCARD', KEYS[1])
+if inflight >= tonumber(ARGV[2]) then
+	return {0, inflight}
+end
+redis.call('ZADD', KEYS[1], ARGV[3], ARGV[4])
+redis.call('PEXPIRE', KEYS[1], ARGV[5])
+return {1, inflight + 1}
+`)
+
+func (r *RedisConcurrencyStrategy) Acquire(ctx context.Context, key string, limit int, ttl time.Duration) (string, int, error) {
+	id, err := newSlotID()
+	if err != nil {
+		return "", 0, err
+	}
+
+	now := time.Now()
+	values, err := acquireSlotScript.Run(ctx, r.client, []string{slotKey(key)},
+		now.UnixNano(), limit, now.Add(ttl).UnixNano(), id, ttl.Milliseconds(),
+	).Int64Slice()
+	if err != nil {
+		return "", 0, fmt.Errorf("redis acquire failed: %w", err)
+	}
+	if len(values) != 2 {
+		return "", 0, fmt.Errorf("unexpected acquire reply: %v", values)
+	}
+
+	if values[0] == 0 {
+		return "", int(values[1]), nil
+	}
+	return id, int(values[1]), nil
+}
+
+// Atualiza a validade apenas se o slot ainda existir (XX)
+func (r *RedisConcurrencyStrategy) Refresh(ctx context.Context, key, id string, ttl time.Duration) error {
+	key = slotKey(key)
+	expiresAt := time.Now().Add(ttl).UnixNano()
+
+	pipe := r.client.Pipeline()
+	pipe.ZAddXX(ctx, key, &redis.Z{Score: float64(expiresAt), Member: id})
+	pipe.PExpire(ctx, key, ttl)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+func (r *RedisConcurrencyStrategy) Release(ctx context.Context, key, id string) error {
+	return r.client.ZRem(ctx, slotKey(key), id).Err()
+}
+
+// Slots em memória, para uma única instância (ou storages sem Redis)
+type MemoryConcurrencyStrategy struct {
+	mu     sync.Mutex
+	now    func() time.Time
+	nextID uint64
+	// Validade de cada slot ocupado, por chave
+	slots map[string]map[string]time.Time
+}
+
+func NewMemoryConcurrencyStrategy() *MemoryConcurrencyStrategy {
+	return &MemoryConcurrencyStrategy{
+		now:   time.Now,
+		slots: make(map[string]map[string]time.Time),
+	}
+}
+
+func (m *MemoryConcurrencyStrategy) Acquire(ctx context.Context, key string, limit int, ttl time.Duration) (string, int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	slots := m.slots[key]
+	for id, expiresAt := range slots {
+		if !now.Before(expiresAt) {
+			delete(slots, id)
+		}
+	}
+	if len(slots) >= limit {
+		return "", len(slots), nil
+	}
+
+	if slots == nil {
+		slots = make(map[string]time.Time)
+		m.slots[key] = slots
+	}
+	m.nextID++
+	id := strconv.FormatUint(m.nextID, 10)
+	slots[id] = now.Add(ttl)
+	return id, len(slots), nil
+}
+
+func (m *MemoryConcurrencyStrategy) Refresh(ctx context.Context, key, id string, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.slots[key][id]; exists {
+		m.slots[key][id] = m.now().Add(ttl)
+	}
+	return nil
+}
+
+func (m *MemoryConcurrencyStrategy) Release(ctx context.Context, key, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.slots[key], id)
+	if len(m.slots[key]) == 0 {
+		delete(m.slots, key)
+	}
+	return nil
+}
+
+// Slots em uso na chave, incluindo os expirados ainda não removidos
+func (m *MemoryConcurrencyStrategy) InFlight(key string) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.slots[key])
+}
+
+func newSlotID() (string, error) {
+	var buf [16]byte
+	if _, err := rand.Read(buf[:]); err != nil {
+		return "", fmt.Errorf("failed to generate slot id: %w", err)
+	}
+	return hex.EncodeToString(buf[:]), nil
+}
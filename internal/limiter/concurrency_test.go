@@ -0,0 +1,81 @@
+package limiter
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"fc-pos-golang-rate-limiter/internal/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConcurrencySlots(t *testing.T) {
+	ctx := context.Background()
+	rateLimiter := NewRateLimiter(NewMockStorageStrategy(), &config.RateLimitConfig{IPLimit: 10, WindowSeconds: 1, Tenant: "acme"}, nil)
+
+	_, err := rateLimiter.AcquireSlot(ctx, CheckRequest{Identifier: "1.2.3.4"}, 2)
+	assert.ErrorIs(t, err, ErrConcurrencyDisabled)
+
+	slots := NewMemoryConcurrencyStrategy()
+	now := time.Now()
+	slots.now = func() time.Time { return now }
+	rateLimiter.SetConcurrency(slots, time.Hour)
+
+	first, err := rateLimiter.AcquireSlot(ctx, CheckRequest{Identifier: "1.2.3.4"}, 2)
+	require.NoError(t, err)
+	assert.True(t, first.Acquired)
+	second, err := rateLimiter.AcquireSlot(ctx, CheckRequest{Identifier: "1.2.3.4"}, 2)
+	require.NoError(t, err)
+	assert.True(t, second.Acquired)
+	assert.Equal(t, 2, second.InFlight)
+
+	rejectedBefore := DecisionCount(MetricConcurrencyRejected)
+	third, err := rateLimiter.AcquireSlot(ctx, CheckRequest{Identifier: "1.2.3.4"}, 2)
+	require.NoError(t, err)
+	assert.False(t, third.Acquired)
+	assert.Equal(t, 2, third.InFlight)
+	assert.Equal(t, rejectedBefore+1, DecisionCount(MetricConcurrencyRejected))
+	assert.NoError(t, third.Release(ctx))
+
+	// Slots usam o namespace do tenant e são separados por regra
+	assert.Equal(t, 2, slots.InFlight("t=acme:inflight:ip:1.2.3.4"))
+	ruled, err := rateLimiter.AcquireSlot(ctx, CheckRequest{Identifier: "1.2.3.4", Rule: "upload"}, 1)
+	require.NoError(t, err)
+	assert.True(t, ruled.Acquired)
+	require.NoError(t, ruled.Release(ctx))
+
+	require.NoError(t, first.Release(ctx))
+	require.NoError(t, first.Release(ctx))
+	assert.Equal(t, 1, slots.InFlight("t=acme:inflight:ip:1.2.3.4"))
+
+	// Slots de instâncias que caíram (nunca liberados) expiram
+	now = now.Add(2 * time.Hour)
+	fourth, err := rateLimiter.AcquireSlot(ctx, CheckRequest{Identifier: "1.2.3.4"}, 1)
+	require.NoError(t, err)
+	assert.True(t, fourth.Acquired)
+	assert.Equal(t, 1, fourth.InFlight)
+	require.NoError(t, fourth.Release(ctx))
+	require.NoError(t, second.Release(ctx))
+
+	t.Run("Lease is renewed while the slot is held", func(t *testing.T) {
+		slots := NewMemoryConcurrencyStrategy()
+		rateLimiter.SetConcurrency(slots, 40*time.Millisecond)
+
+		held, err := rateLimiter.AcquireSlot(ctx, CheckRequest{Identifier: "5.6.7.8"}, 1)
+		require.NoError(t, err)
+		require.True(t, held.Acquired)
+
+		time.Sleep(150 * time.Millisecond)
+		other, err := rateLimiter.AcquireSlot(ctx, CheckRequest{Identifier: "5.6.7.8"}, 1)
+		require.NoError(t, err)
+		assert.False(t, other.Acquired)
+
+		require.NoError(t, held.Release(ctx))
+		other, err = rateLimiter.AcquireSlot(ctx, CheckRequest{Identifier: "5.6.7.8"}, 1)
+		require.NoError(t, err)
+		assert.True(t, other.Acquired)
+		require.NoError(t, other.Release(ctx))
+	})
+}
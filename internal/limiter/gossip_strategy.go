@@ -0,0 +1,435 @@
+package limiter
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Defaults do storage distribuído por gossip
+const (
+	DefaultGossipInterval = 200 * time.Millisecond
+	// Bloqueio máximo aceito dos peers até um bloqueio local maior ser visto
+	// (o mesmo padrão de RATE_LIMIT_BLOCK_DURATION_SECONDS)
+	DefaultGossipMaxBlock = 5 * time.Minute
+	// Quantidade máxima de chaves que os peers podem criar neste nó
+	DefaultGossipMaxKeys = 100000
+	// Cada janela é dividida em buckets; a precisão da janela deslizante é de um bucket
+	gossipBucketsPerWindow = 10
+	// Path em que os nós recebem as mensagens dos peers
+	GossipPath = "/gossip"
+	// Header com o segredo compartilhado entre os nós
+	GossipTokenHeader = "X-Gossip-Token"
+)
+
+// Storage em memória sem Redis: cada nó conta localmente e envia periodicamente
+// aos peers os incrementos (deltas) por chave, via HTTP. O limite é global e
+// aproximado: entre duas rodadas de gossip cada nó só conhece o próprio
+// consumo, e mensagens perdidas não são reenviadas.
+type GossipStrategy struct {
+	nodeID   string
+	interval time.Duration
+	secret   string
+	maxBlock time.Duration
+	maxKeys  int
+	client   *http.Client
+	now      func() time.Time
+
+	mu      sync.Mutex
+	peers   []string
+	entries map[string]*gossipEntry
+	pending map[string]*GossipDelta
+	resets  []string
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// Contagem global de uma chave em buckets de tempo
+type gossipEntry struct {
+	window       time.Duration
+	buckets      map[int64]int
+	blockedUntil time.Time
+}
+
+// Mensagem trocada entre os nós
+type GossipMessage struct {
+	Node   string        `json:"node"`
+	Deltas []GossipDelta `json:"deltas,omitempty"`
+	Resets []string      `json:"resets,omitempty"`
+}
+
+// Incremento de uma chave desde a última rodada. Buckets e BlockedUntil usam
+// unix nanos.
+type GossipDelta struct {
+	Key          string        `json:"key"`
+	WindowMillis int64         `json:"window_ms"`
+	Buckets      map[int64]int `json:"buckets,omitempty"`
+	BlockedUntil int64         `json:"blocked_until,omitempty"`
+}
+
+type GossipOption func(*GossipStrategy)
+
+// Define o identificador do nó nas mensagens (ex.: hostname)
+func WithGossipNodeID(nodeID string) GossipOption {
+	return func(g *GossipStrategy) {
+		g.nodeID = nodeID
+	}
+}
+
+// Define os peers (host:porta ou URL base) que recebem os deltas
+func WithGossipPeers(peers ...string) GossipOption {
+	return func(g *GossipStrategy) {
+		g.peers = normalizePeers(peers)
+	}
+}
+
+// Define o intervalo entre as rodadas de gossip
+func WithGossipInterval(interval time.Duration) GossipOption {
+	return func(g *GossipStrategy) {
+		g.interval = interval
+	}
+}
+
+// Exige o segredo no header X-Gossip-Token das mensagens recebidas e o envia aos peers
+func WithGossipSecret(secret string) GossipOption {
+	return func(g *GossipStrategy) {
+		g.secret = secret
+	}
+}
+
+// Limita a duração dos bloqueios recebidos dos peers (now + maxBlock). O
+// limite sobe sozinho quando este nó aplica um bloqueio maior.
+func WithGossipMaxBlock(maxBlock time.Duration) GossipOption {
+	return func(g *GossipStrategy) {
+		g.maxBlock = maxBlock
+	}
+}
+
+// Limita as chaves que os deltas dos peers podem criar; chaves já conhecidas
+// continuam recebendo os deltas
+func WithGossipMaxKeys(maxKeys int) GossipOption {
+	return func(g *GossipStrategy) {
+		g.maxKeys = maxKeys
+	}
+}
+
+func NewGossipStrategy(opts ...GossipOption) *GossipStrategy {
+	ctx, cancel := context.WithCancel(context.Background())
+	g := &GossipStrategy{
+		interval: DefaultGossipInterval,
+		maxBlock: DefaultGossipMaxBlock,
+		maxKeys:  DefaultGossipMaxKeys,
+		client:   &http.Client{Timeout: 2 * time.Second},
+		now:      time.Now,
+		entries:  make(map[string]*gossipEntry),
+		pending:  make(map[string]*GossipDelta),
+		cancel:   cancel,
+		done:     make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(g)
+	}
+
+	go g.run(ctx)
+
+	return g
+}
+
+func (g *GossipStrategy) Allow(ctx context.Context, key string, limit int, window time.Duration, blockDuration time.Duration) (bool, int, time.Time, error) {
+	return g.AllowN(ctx, key, 1, limit, window, blockDuration)
+}
+
+// Mesma semântica do RedisStrategy: bloqueia ao exceder o limite e nega sem
+// bloquear quando o custo é maior que o saldo
+func (g *GossipStrategy) AllowN(ctx context.Context, key string, cost int, limit int, window time.Duration, blockDuration time.Duration) (bool, int, time.Time, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	now := g.now()
+	if blockDuration > g.maxBlock {
+		g.maxBlock = blockDuration
+	}
+	entry := g.entry(key, window)
+	if now.Before(entry.blockedUntil) {
+		return false, 0, entry.blockedUntil, nil
+	}
+
+	count, oldest := entry.count(now)
+	if count+cost > limit {
+		if count < limit {
+			return false, limit - count, now.Add(window), nil
+		}
+		// Sem bloqueio o saldo volta quando o bucket mais antigo sai da janela
+		if blockDuration <= 0 {
+			return false, 0, oldest.Add(window), nil
+		}
+		entry.blockedUntil = now.Add(blockDuration)
+		g.delta(key, window).BlockedUntil = entry.blockedUntil.UnixNano()
+		return false, 0, entry.blockedUntil, nil
+	}
+
+	bucket := entry.bucket(now)
+	entry.buckets[bucket] += cost
+	g.delta(key, window).Buckets[bucket] += cost
+
+	resetTime := now.Add(window)
+	if count > 0 {
+		resetTime = oldest.Add(window)
+	}
+	return true, limit - count - cost, resetTime, nil
+}
+
+// Remove a chave neste nó e nos peers
+func (g *GossipStrategy) Reset(ctx context.Context, key string) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	delete(g.entries, key)
+	delete(g.pending, key)
+	g.resets = append(g.resets, key)
+	return nil
+}
+
+// Envia os deltas pendentes e encerra o gossip
+func (g *GossipStrategy) Close() error {
+	g.cancel()
+	<-g.done
+	return g.Gossip(context.Background())
+}
+
+// Substitui a lista de peers
+func (g *GossipStrategy) SetPeers(peers []string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.peers = normalizePeers(peers)
+}
+
+// Envia os deltas acumulados a todos os peers. Retorna os erros de envio; os
+// deltas não entregues a um peer são descartados para ele.
+func (g *GossipStrategy) Gossip(ctx context.Context) error {
+	g.mu.Lock()
+	msg := GossipMessage{Node: g.nodeID, Resets: g.resets}
+	for _, delta := range g.pending {
+		msg.Deltas = append(msg.Deltas, *delta)
+	}
+	g.pending = make(map[string]*GossipDelta)
+	g.resets = nil
+	peers := g.peers
+	g.prune(g.now())
+	g.mu.Unlock()
+
+	if len(msg.Deltas) == 0 && len(msg.Resets) == 0 {
+		return nil
+	}
+
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	// Envia em paralelo para um peer lento não atrasar os demais
+	errs := make([]error, len(peers))
+	var wg sync.WaitGroup
+	for i, peer := range peers {
+		wg.Add(1)
+		go func(i int, peer string) {
+			defer wg.Done()
+			if err := g.send(ctx, peer, body); err != nil {
+				errs[i] = fmt.Errorf("peer %s: %w", peer, err)
+			}
+		}(i, peer)
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// Recebe as mensagens dos peers (POST /gossip)
+func (g *GossipStrategy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if g.secret != "" && subtle.ConstantTimeCompare([]byte(r.Header.Get(GossipTokenHeader)), []byte(g.secret)) != 1 {
+		http.Error(w, "invalid gossip token", http.StatusUnauthorized)
+		return
+	}
+
+	var msg GossipMessage
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 8<<20)).Decode(&msg); err != nil {
+		http.Error(w, "invalid gossip message", http.StatusBadRequest)
+		return
+	}
+
+	g.Apply(msg)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Aplica os deltas e resets recebidos de outro nó. Chaves novas além de
+// maxKeys são ignoradas, buckets futuros ou negativos descartados e os
+// bloqueios limitados a now + maxBlock.
+func (g *GossipStrategy) Apply(msg GossipMessage) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	for _, key := range msg.Resets {
+		delete(g.entries, key)
+	}
+
+	now := g.now()
+	maxBlockedUntil := now.Add(g.maxBlock)
+	for _, delta := range msg.Deltas {
+		window := time.Duration(delta.WindowMillis) * time.Millisecond
+		if _, exists := g.entries[delta.Key]; !exists && (window <= 0 || len(g.entries) >= g.maxKeys) {
+			continue
+		}
+
+		entry := g.entry(delta.Key, window)
+		latest := now.Add(entry.window).UnixNano()
+		for bucket, count := range delta.Buckets {
+			if count > 0 && bucket <= latest {
+				entry.buckets[bucket] += count
+			}
+		}
+
+		if delta.BlockedUntil <= 0 {
+			continue
+		}
+		blockedUntil := time.Unix(0, delta.BlockedUntil)
+		if blockedUntil.After(maxBlockedUntil) {
+			blockedUntil = maxBlockedUntil
+		}
+		if blockedUntil.After(entry.blockedUntil) {
+			entry.blockedUntil = blockedUntil
+		}
+	}
+}
+
+// Quantidade de chaves conhecidas por este nó
+func (g *GossipStrategy) Len() int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return len(g.entries)
+}
+
+func (g *GossipStrategy) send(ctx context.Context, peer string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, peer, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if g.secret != "" {
+		req.Header.Set(GossipTokenHeader, g.secret)
+	}
+
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+// Deve ser chamado com g.mu adquirido
+func (g *GossipStrategy) entry(key string, window time.Duration) *gossipEntry {
+	entry, exists := g.entries[key]
+	if !exists {
+		entry = &gossipEntry{buckets: make(map[int64]int)}
+		g.entries[key] = entry
+	}
+	if window > 0 {
+		entry.window = window
+	}
+	return entry
+}
+
+// Deve ser chamado com g.mu adquirido
+func (g *GossipStrategy) delta(key string, window time.Duration) *GossipDelta {
+	delta, exists := g.pending[key]
+	if !exists {
+		delta = &GossipDelta{Key: key, WindowMillis: window.Milliseconds(), Buckets: make(map[int64]int)}
+		g.pending[key] = delta
+	}
+	return delta
+}
+
+// Remove as chaves sem contagem na janela e sem bloqueio. Deve ser chamado com g.mu adquirido.
+func (g *GossipStrategy) prune(now time.Time) {
+	for key, entry := range g.entries {
+		if count, _ := entry.count(now); count == 0 && !now.Before(entry.blockedUntil) {
+			delete(g.entries, key)
+		}
+	}
+}
+
+// Início do bucket do instante
+func (e *gossipEntry) bucket(at time.Time) int64 {
+	size := e.window / gossipBucketsPerWindow
+	if size <= 0 {
+		return at.UnixNano()
+	}
+	return at.Truncate(size).UnixNano()
+}
+
+// Soma os buckets dentro da janela, descartando os expirados, e retorna o
+// início do bucket mais antigo
+func (e *gossipEntry) count(now time.Time) (int, time.Time) {
+	windowStart := now.Add(-e.window).UnixNano()
+	count := 0
+	oldest := int64(0)
+	for bucket, n := range e.buckets {
+		if bucket <= windowStart {
+			delete(e.buckets, bucket)
+			continue
+		}
+		count += n
+		if oldest == 0 || bucket < oldest {
+			oldest = bucket
+		}
+	}
+	return count, time.Unix(0, oldest)
+}
+
+func (g *GossipStrategy) run(ctx context.Context) {
+	defer close(g.done)
+
+	ticker := time.NewTicker(g.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			g.Gossip(ctx)
+		}
+	}
+}
+
+// Aceita host:porta ou URL base e monta a URL do endpoint de gossip
+func normalizePeers(peers []string) []string {
+	urls := make([]string, 0, len(peers))
+	for _, peer := range peers {
+		peer = strings.TrimSpace(peer)
+		if peer == "" {
+			continue
+		}
+		if !strings.Contains(peer, "://") {
+			peer = "http://" + peer
+		}
+		urls = append(urls, strings.TrimSuffix(peer, "/")+GossipPath)
+	}
+	return urls
+}
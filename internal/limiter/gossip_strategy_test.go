@@ -0,0 +1,171 @@
+package limiter
+
+import (
+	"context"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Sobe n nós de gossip em localhost, cada um com os demais como peers
+func startGossipNodes(t *testing.T, n int, opts ...GossipOption) []*GossipStrategy {
+	t.Helper()
+
+	nodes := make([]*GossipStrategy, n)
+	addrs := make([]string, n)
+	for i := range nodes {
+		nodes[i] = NewGossipStrategy(append([]GossipOption{WithGossipNodeID(fmt.Sprintf("node-%d", i))}, opts...)...)
+		server := httptest.NewServer(nodes[i])
+		t.Cleanup(server.Close)
+		addrs[i] = server.URL
+	}
+	for i, node := range nodes {
+		peers := make([]string, 0, n-1)
+		for j, addr := range addrs {
+			if j != i {
+				peers = append(peers, addr)
+			}
+		}
+		node.SetPeers(peers)
+	}
+	return nodes
+}
+
+func TestGossipStrategy(t *testing.T) {
+	ctx := context.Background()
+	window := time.Minute
+	blockDuration := time.Minute
+
+	t.Run("Nodes share counts and blocks", func(t *testing.T) {
+		nodes := startGossipNodes(t, 3, WithGossipInterval(time.Hour))
+
+		limit := 30
+		for _, node := range nodes {
+			for i := 0; i < 10; i++ {
+				allowed, _, _, err := node.Allow(ctx, "ip:1.2.3.4", limit, window, blockDuration)
+				require.NoError(t, err)
+				assert.True(t, allowed)
+			}
+		}
+		for _, node := range nodes {
+			require.NoError(t, node.Gossip(ctx))
+		}
+
+		// Após o gossip o primeiro nó conhece o consumo global e bloqueia
+		allowed, remaining, _, err := nodes[0].Allow(ctx, "ip:1.2.3.4", limit, window, blockDuration)
+		require.NoError(t, err)
+		assert.False(t, allowed)
+		assert.Equal(t, 0, remaining)
+
+		require.NoError(t, nodes[0].Gossip(ctx))
+		allowed, _, resetTime, err := nodes[2].Allow(ctx, "ip:1.2.3.4", limit, window, blockDuration)
+		require.NoError(t, err)
+		assert.False(t, allowed)
+		assert.WithinDuration(t, time.Now().Add(blockDuration), resetTime, time.Second)
+
+		// O reset também é propagado
+		require.NoError(t, nodes[1].Reset(ctx, "ip:1.2.3.4"))
+		require.NoError(t, nodes[1].Gossip(ctx))
+		for _, node := range nodes {
+			allowed, _, _, err := node.Allow(ctx, "ip:1.2.3.4", limit, window, blockDuration)
+			require.NoError(t, err)
+			assert.True(t, allowed)
+		}
+
+		for _, node := range nodes {
+			require.NoError(t, node.Close())
+		}
+	})
+
+	t.Run("Background gossip converges", func(t *testing.T) {
+		nodes := startGossipNodes(t, 2, WithGossipInterval(10*time.Millisecond))
+		defer nodes[0].Close()
+		defer nodes[1].Close()
+
+		for i := 0; i < 5; i++ {
+			nodes[0].Allow(ctx, "token:edge", 5, window, blockDuration)
+		}
+		assert.Eventually(t, func() bool {
+			allowed, _, _, err := nodes[1].Allow(ctx, "token:edge", 5, window, blockDuration)
+			return err == nil && !allowed
+		}, time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("Window slides by bucket", func(t *testing.T) {
+		node := NewGossipStrategy(WithGossipInterval(time.Hour))
+		defer node.Close()
+
+		now := time.Now()
+		node.now = func() time.Time { return now }
+
+		for i := 0; i < 3; i++ {
+			allowed, _, _, _ := node.Allow(ctx, "ip:5.5.5.5", 3, 10*time.Second, 0)
+			assert.True(t, allowed)
+		}
+		allowed, _, _, _ := node.Allow(ctx, "ip:5.5.5.5", 3, 10*time.Second, 0)
+		assert.False(t, allowed)
+
+		now = now.Add(11 * time.Second)
+		allowed, remaining, _, _ := node.Allow(ctx, "ip:5.5.5.5", 3, 10*time.Second, 0)
+		assert.True(t, allowed)
+		assert.Equal(t, 2, remaining)
+	})
+
+	t.Run("Secret is required", func(t *testing.T) {
+		receiver := NewGossipStrategy(WithGossipInterval(time.Hour), WithGossipSecret("s3cret"))
+		defer receiver.Close()
+		server := httptest.NewServer(receiver)
+		defer server.Close()
+
+		sender := NewGossipStrategy(WithGossipInterval(time.Hour), WithGossipPeers(server.URL))
+		sender.Allow(ctx, "ip:6.6.6.6", 10, window, blockDuration)
+		assert.ErrorContains(t, sender.Gossip(ctx), "401")
+		assert.Equal(t, 0, receiver.Len())
+		sender.Close()
+
+		sender = NewGossipStrategy(WithGossipInterval(time.Hour), WithGossipPeers(server.URL), WithGossipSecret("s3cret"))
+		sender.Allow(ctx, "ip:6.6.6.6", 10, window, blockDuration)
+		require.NoError(t, sender.Close())
+		assert.Equal(t, 1, receiver.Len())
+	})
+
+	t.Run("Peer deltas are bounded", func(t *testing.T) {
+		node := NewGossipStrategy(WithGossipInterval(time.Hour), WithGossipMaxBlock(blockDuration), WithGossipMaxKeys(2))
+		defer node.Close()
+		now := time.Now()
+
+		// Bloqueios além do maior bloqueio conhecido são encurtados
+		node.Apply(GossipMessage{Deltas: []GossipDelta{{
+			Key: "ip:7.7.7.7", WindowMillis: window.Milliseconds(), BlockedUntil: now.Add(24 * time.Hour).UnixNano(),
+		}}})
+		_, _, resetTime, err := node.Allow(ctx, "ip:7.7.7.7", 10, window, blockDuration)
+		require.NoError(t, err)
+		assert.WithinDuration(t, now.Add(blockDuration), resetTime, time.Second)
+
+		// Buckets futuros ou negativos não contam
+		node.Apply(GossipMessage{Deltas: []GossipDelta{{
+			Key: "ip:8.8.8.8", WindowMillis: window.Milliseconds(),
+			Buckets: map[int64]int{now.Add(24 * time.Hour).UnixNano(): 100, now.UnixNano(): -100},
+		}}})
+		allowed, remaining, _, err := node.Allow(ctx, "ip:8.8.8.8", 10, window, blockDuration)
+		require.NoError(t, err)
+		assert.True(t, allowed)
+		assert.Equal(t, 9, remaining)
+
+		// Com maxKeys atingido só as chaves conhecidas recebem deltas
+		node.Apply(GossipMessage{Deltas: []GossipDelta{
+			{Key: "ip:9.9.9.9", WindowMillis: window.Milliseconds(), Buckets: map[int64]int{now.UnixNano(): 1}},
+			{Key: "ip:8.8.8.8", WindowMillis: window.Milliseconds(), Buckets: map[int64]int{now.UnixNano(): 1}},
+		}})
+		assert.Equal(t, 2, node.Len())
+		_, remaining, _, err = node.Allow(ctx, "ip:8.8.8.8", 10, window, blockDuration)
+		require.NoError(t, err)
+		assert.Equal(t, 7, remaining)
+	})
+
+	assert.Equal(t, []string{"http://10.0.0.2:7946/gossip", "https://edge-2/gossip"}, normalizePeers([]string{"10.0.0.2:7946", " https://edge-2/ ", ""}))
+}
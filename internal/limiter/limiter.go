@@ -2,16 +2,34 @@ package limiter
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 
 	"fc-pos-golang-rate-limiter/internal/config"
 )
 
+var (
+	// Retornado quando a verificação referencia uma regra não configurada
+	ErrUnknownRule = errors.New("unknown rate limit rule")
+	// Retornado quando o custo é inválido ou o storage não suporta custo maior que 1
+	ErrInvalidCost = errors.New("invalid cost")
+	// Retornado quando o tenant contém caracteres reservados das chaves
+	ErrInvalidTenant = errors.New("invalid tenant")
+)
+
 type RateLimiter struct {
 	storage      StorageStrategy
 	ipConfig     *config.RateLimitConfig
 	tokenConfigs config.TokenConfigs
+	rules        config.RuleConfigs
+	plans        config.PlanConfigs
+	hashToken    config.TokenHasher
+	// Slots de requisições simultâneas (opcional)
+	concurrency ConcurrencyStrategy
+	leaseTTL    time.Duration
+	// Filas das requisições em Wait
+	waitQueues waitQueues
 }
 
 func NewRateLimiter(storage StorageStrategy, ipConfig *config.RateLimitConfig, tokenConfigs config.TokenConfigs) *RateLimiter {
@@ -22,73 +40,337 @@ func NewRateLimiter(storage StorageStrategy, ipConfig *config.RateLimitConfig, t
 	}
 }
 
+// Define as regras nomeadas disponíveis. Deve ser chamado antes de atender requisições.
+func (rl *RateLimiter) SetRules(rules config.RuleConfigs) {
+	rl.rules = rules
+}
+
+// Define os planos disponíveis para CheckRequest.Plan. Deve ser chamado antes de atender requisições.
+func (rl *RateLimiter) SetPlans(plans config.PlanConfigs) {
+	rl.plans = plans
+}
+
+// Define como os tokens são convertidos antes da busca no tokens.json e da
+// montagem da chave no storage (ex.: SHA-256), evitando tokens em texto puro
+func (rl *RateLimiter) SetTokenHasher(hasher config.TokenHasher) {
+	rl.hashToken = hasher
+}
+
+// Busca a configuração de um token recebido (ainda não convertido pelo hasher)
+func (rl *RateLimiter) TokenConfig(token string) (*config.TokenConfig, bool) {
+	return rl.tokenConfigs.GetTokenConfig(rl.tokenID(token))
+}
+
+// Parâmetros de uma verificação de rate limit
+type CheckRequest struct {
+	Identifier string
+	IsToken    bool
+	// Token é o token buscado no tokens.json quando Identifier é uma chave
+	// composta (ex.: tenant|token); vazio usa o Identifier
+	Token string
+	// Rule seleciona uma regra nomeada no lugar dos limites de IP/token
+	Rule string
+	// Cost é quantas unidades do limite a requisição consome (default 1)
+	Cost int
+	// Plan seleciona os limites do cliente (ex.: vindo de um claim do JWT).
+	// Planos desconhecidos são ignorados.
+	Plan string
+	// Limit sobrescreve o limite do cliente, mantendo janela e bloqueio.
+	// Ignorado quando Rule é informada.
+	Limit int
+	// Tenant isola os contadores por cliente do serviço; vazio usa o tenant configurado
+	Tenant string
+	// Descriptor indica que Identifier é uma chave genérica (ex.: as entradas de
+	// um descriptor do Envoy), com chaves "descriptor:" e os limites de IP
+	Descriptor bool
+	// JWT indica que Identifier é um claim de um JWT verificado: as chaves usam
+	// "jwt:" e os limites vêm de Plan/Limit (ou do limite de IP), nunca do tokens.json
+	JWT bool
+
+	noBlock bool
+	// As tentativas intermediárias de Wait não entram nas métricas de decisão
+	noRecord bool
+}
+
 type CheckResult struct {
 	Allowed    bool
 	Remaining  int
 	ResetTime  time.Time
 	Limit      int
+	Window     time.Duration
 	Identifier string
 	IsToken    bool
+	Rule       string
+	Plan       string
+	// DryRun indica que a decisão é apenas observada (modo shadow) e não deve bloquear
+	DryRun bool
+	// Concurrency indica rejeição por falta de slot de requisições simultâneas
+	Concurrency bool
+	// Shadow é o resultado da regra (ou plano) em dry-run avaliada junto com o
+	// limite aplicado; nil quando não há regra em dry-run
+	Shadow *CheckResult
+}
+
+// Em Reserve a requisição acima do limite é negada sem aplicar o bloqueio
+func (req CheckRequest) withoutBlock() CheckRequest {
+	req.noBlock = true
+	return req
+}
+
+func (req CheckRequest) token() string {
+	if req.Token != "" {
+		return req.Token
+	}
+	return req.Identifier
+}
+
+// Limites resolvidos para uma verificação
+type limits struct {
+	limit         int
+	window        time.Duration
+	blockDuration time.Duration
+	plan          string
+	dryRun        bool
 }
 
 // Verifica se uma requisição é permitida baseada no IP ou Token
 func (rl *RateLimiter) Check(ctx context.Context, identifier string, isToken bool) (*CheckResult, error) {
-	var limit int
-	var window time.Duration
-	var blockDuration time.Duration
+	return rl.Evaluate(ctx, CheckRequest{Identifier: identifier, IsToken: isToken})
+}
 
-	if isToken {
+// Verifica uma requisição aplicando a regra pedida ou, sem regra, os limites de IP/token.
+// Uma regra (ou plano) em dry-run não substitui o limite aplicado: ela é
+// avaliada à parte e retornada em CheckResult.Shadow.
+func (rl *RateLimiter) Evaluate(ctx context.Context, req CheckRequest) (*CheckResult, error) {
+	var l limits
+	dryRun := rl.ipConfig.DryRun
+
+	cost := req.Cost
+	if cost == 0 {
+		cost = 1
+	}
+	if cost < 0 {
+		return nil, fmt.Errorf("%w: %d", ErrInvalidCost, cost)
+	}
+	if !config.ValidKeySegment(req.Tenant) {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidTenant, req.Tenant)
+	}
+
+	if req.Rule != "" {
+		// Usa a regra nomeada
+		ruleConfig, exists := rl.rules.GetRuleConfig(req.Rule)
+		if !exists {
+			recordError()
+			return nil, fmt.Errorf("%w: %s", ErrUnknownRule, req.Rule)
+		}
+		l = limits{
+			limit:         ruleConfig.Limit,
+			window:        ruleConfig.GetWindowDuration(),
+			blockDuration: ruleConfig.GetBlockDuration(),
+			dryRun:        dryRun || ruleConfig.DryRun,
+		}
+		if ruleConfig.DryRun && !dryRun {
+			enforced := req
+			enforced.Rule = ""
+			return rl.evaluateShadow(ctx, req, enforced, cost, l)
+		}
+	} else if planConfig, exists := rl.resolvePlan(req.Plan); exists {
+		// Usa os limites do plano do cliente
+		l = limits{
+			limit:         planConfig.Limit,
+			window:        planConfig.GetWindowDuration(),
+			blockDuration: planConfig.GetBlockDuration(),
+			plan:          req.Plan,
+			dryRun:        dryRun || planConfig.DryRun,
+		}
+		if planConfig.DryRun && !dryRun {
+			if req.Limit > 0 {
+				l.limit = req.Limit
+			}
+			enforced := req
+			enforced.Plan = ""
+			return rl.evaluateShadow(ctx, req, enforced, cost, l)
+		}
+	} else if req.IsToken && !req.JWT {
 		// Verifica se o token existe na configuração
-		tokenConfig, exists := rl.tokenConfigs.GetTokenConfig(identifier)
+		tokenConfig, exists := rl.TokenConfig(req.token())
 		if !exists {
 			// Token não encontrado, volta para o limite de IP
-			limit = rl.ipConfig.IPLimit
-			window = rl.ipConfig.GetWindowDuration()
-			blockDuration = rl.ipConfig.GetBlockDuration()
+			l = rl.ipLimits(dryRun)
 		} else {
 			// Usa a configuração específica do token
-			limit = tokenConfig.Limit
-			window = tokenConfig.GetWindowDuration()
-			blockDuration = tokenConfig.GetBlockDuration()
+			l = limits{
+				limit:         tokenConfig.Limit,
+				window:        tokenConfig.GetWindowDuration(),
+				blockDuration: tokenConfig.GetBlockDuration(),
+				plan:          tokenConfig.Plan,
+				dryRun:        dryRun,
+			}
 		}
 	} else {
 		// Usa a configuração de IP
-		limit = rl.ipConfig.IPLimit
-		window = rl.ipConfig.GetWindowDuration()
-		blockDuration = rl.ipConfig.GetBlockDuration()
+		l = rl.ipLimits(dryRun)
+	}
+
+	if req.Rule == "" && req.Limit > 0 {
+		l.limit = req.Limit
 	}
 
+	return rl.check(ctx, req, cost, l)
+}
+
+func (rl *RateLimiter) ipLimits(dryRun bool) limits {
+	return limits{
+		limit:         rl.ipConfig.IPLimit,
+		window:        rl.ipConfig.GetWindowDuration(),
+		blockDuration: rl.ipConfig.GetBlockDuration(),
+		dryRun:        dryRun,
+	}
+}
+
+// Aplica o limite de enforced e avalia a regra em dry-run no namespace shadow.
+// Falhas do contador shadow não afetam a decisão aplicada.
+func (rl *RateLimiter) evaluateShadow(ctx context.Context, req, enforced CheckRequest, cost int, shadow limits) (*CheckResult, error) {
+	result, err := rl.Evaluate(ctx, enforced)
+	if err != nil {
+		return nil, err
+	}
+	if shadowResult, err := rl.check(ctx, req, cost, shadow); err == nil {
+		result.Shadow = shadowResult
+	}
+	return result, nil
+}
+
+// Consome o custo na chave da requisição e monta o resultado
+func (rl *RateLimiter) check(ctx context.Context, req CheckRequest, cost int, l limits) (*CheckResult, error) {
 	// Cria a chave de armazenamento
-	key := rl.createKey(identifier, isToken)
+	key := rl.requestKey(req)
+	if req.Rule != "" {
+		key = fmt.Sprintf("rule:%s:%s", req.Rule, key)
+	}
+	if l.dryRun {
+		// Contadores shadow ficam em outro namespace para não afetar os limites aplicados
+		key = "shadow:" + key
+	}
+	key = rl.namespace(req.Tenant) + key
+
+	if req.noBlock {
+		l.blockDuration = 0
+	}
 
 	// Verifica com o armazenamento
-	allowed, remaining, resetTime, err := rl.storage.Allow(ctx, key, limit, window, blockDuration)
+	allowed, remaining, resetTime, err := rl.allow(ctx, key, cost, l.limit, l.window, l.blockDuration)
 	if err != nil {
+		recordError()
 		return nil, fmt.Errorf("storage check failed: %w", err)
 	}
 
-	return &CheckResult{
+	result := &CheckResult{
 		Allowed:    allowed,
 		Remaining:  remaining,
 		ResetTime:  resetTime,
-		Limit:      limit,
-		Identifier: identifier,
-		IsToken:    isToken,
-	}, nil
+		Limit:      l.limit,
+		Window:     l.window,
+		Identifier: req.Identifier,
+		IsToken:    req.IsToken,
+		Rule:       req.Rule,
+		Plan:       l.plan,
+		DryRun:     l.dryRun,
+	}
+	if !req.noRecord {
+		recordDecision(result)
+	}
+
+	return result, nil
+}
+
+// Procura o plano entre os planos do tokens.json e, em seguida, entre as regras nomeadas
+func (rl *RateLimiter) resolvePlan(name string) (*config.RuleConfig, bool) {
+	if name == "" {
+		return nil, false
+	}
+	if planConfig, exists := rl.plans.GetPlanConfig(name); exists {
+		return &config.RuleConfig{
+			Limit:                planConfig.Limit,
+			WindowSeconds:        planConfig.WindowSeconds,
+			BlockDurationSeconds: planConfig.BlockDurationSeconds,
+		}, true
+	}
+	return rl.rules.GetRuleConfig(name)
+}
+
+// Consome o custo no storage, exigindo suporte a custo quando maior que 1
+func (rl *RateLimiter) allow(ctx context.Context, key string, cost int, limit int, window time.Duration, blockDuration time.Duration) (bool, int, time.Time, error) {
+	if cost == 1 {
+		return rl.storage.Allow(ctx, key, limit, window, blockDuration)
+	}
+
+	costStorage, ok := rl.storage.(CostStrategy)
+	if !ok {
+		return false, 0, time.Time{}, fmt.Errorf("%w: storage does not support cost %d", ErrInvalidCost, cost)
+	}
+	return costStorage.AllowN(ctx, key, cost, limit, window, blockDuration)
 }
 
 func (rl *RateLimiter) Reset(ctx context.Context, identifier string, isToken bool) error {
-	key := rl.createKey(identifier, isToken)
+	key := rl.namespace("") + rl.createKey(identifier, isToken)
 	return rl.storage.Reset(ctx, key)
 }
 
+// Prefixo das chaves do tenant (ou do tenant configurado, se vazio)
+func (rl *RateLimiter) namespace(tenant string) string {
+	if tenant == "" {
+		tenant = rl.ipConfig.Tenant
+	}
+	return KeyNamespace(rl.ipConfig.KeyPrefix, tenant)
+}
+
+// Monta o namespace "p=<prefixo>:t=<tenant>:" que antecede todas as chaves do
+// storage; segmentos vazios são omitidos. Os marcadores p= e t= mantêm os
+// namespaces disjuntos: só prefixo, só tenant e prefixo+tenant nunca colidem
+func KeyNamespace(prefix, tenant string) string {
+	var namespace string
+	if prefix != "" {
+		namespace = "p=" + prefix + ":"
+	}
+	if tenant != "" {
+		namespace += "t=" + tenant + ":"
+	}
+	return namespace
+}
+
+func (rl *RateLimiter) requestKey(req CheckRequest) string {
+	if req.JWT {
+		return fmt.Sprintf("jwt:%s", req.Identifier)
+	}
+	if req.Descriptor && !req.IsToken {
+		return fmt.Sprintf("descriptor:%s", req.Identifier)
+	}
+	return rl.createKey(req.Identifier, req.IsToken)
+}
+
 func (rl *RateLimiter) createKey(identifier string, isToken bool) string {
 	if isToken {
-		return fmt.Sprintf("token:%s", identifier)
+		return fmt.Sprintf("token:%s", rl.tokenID(identifier))
 	}
 	return fmt.Sprintf("ip:%s", identifier)
 }
 
+func (rl *RateLimiter) tokenID(token string) string {
+	if rl.hashToken == nil {
+		return token
+	}
+	return rl.hashToken(token)
+}
+
+// Identificador seguro para logs: IPs como estão, tokens apenas pela impressão digital
+func SafeIdentifier(identifier string, isToken bool) string {
+	if isToken {
+		return config.TokenFingerprint(identifier)
+	}
+	return identifier
+}
+
 func (rl *RateLimiter) GetConfig() (*config.RateLimitConfig, config.TokenConfigs) {
 	return rl.ipConfig, rl.tokenConfigs
 }
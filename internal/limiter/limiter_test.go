@@ -2,11 +2,13 @@ package limiter
 
 import (
 	"context"
+	"strings"
 	"testing"
 	"time"
 
 	"fc-pos-golang-rate-limiter/internal/config"
 
+	"github.com/go-redis/redis/v8"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -214,3 +216,326 @@ func TestRateLimiterCreateKey(t *testing.T) {
 	assert.Equal(t, 1, mockStorage.GetCallCount("token:test_token"))
 }
 
+
+func TestRateLimiterRulesAndDryRun(t *testing.T) {
+	mockStorage := NewMockStorageStrategy()
+	ipConfig := &config.RateLimitConfig{
+		IPLimit:              10,
+		WindowSeconds:        1,
+		BlockDurationSeconds: 300,
+	}
+
+	rateLimiter := NewRateLimiter(mockStorage, ipConfig, nil)
+	rateLimiter.SetRules(config.RuleConfigs{
+		"strict": config.RuleConfig{
+			Limit:                2,
+			WindowSeconds:        10,
+			BlockDurationSeconds: 60,
+		},
+		"candidate": config.RuleConfig{
+			Limit:                1,
+			WindowSeconds:        1,
+			BlockDurationSeconds: 60,
+			DryRun:               true,
+		},
+	})
+	ctx := context.Background()
+
+	t.Run("Named rule", func(t *testing.T) {
+		result, err := rateLimiter.Evaluate(ctx, CheckRequest{Identifier: "192.168.1.1", Rule: "strict"})
+		require.NoError(t, err)
+
+		assert.Equal(t, 2, result.Limit)
+		assert.Equal(t, 10*time.Second, result.Window)
+		assert.Equal(t, "strict", result.Rule)
+		assert.False(t, result.DryRun)
+		assert.Equal(t, 1, mockStorage.GetCallCount("rule:strict:ip:192.168.1.1"))
+	})
+
+	t.Run("Unknown rule", func(t *testing.T) {
+		_, err := rateLimiter.Evaluate(ctx, CheckRequest{Identifier: "192.168.1.1", Rule: "missing"})
+		assert.ErrorIs(t, err, ErrUnknownRule)
+	})
+
+	t.Run("Dry-run rule is shadowed alongside the enforced limit", func(t *testing.T) {
+		mockStorage.SetAllowResult("shadow:rule:candidate:ip:192.168.1.2", false, 1)
+		before := DecisionCount(MetricShadowRejected)
+
+		result, err := rateLimiter.Evaluate(ctx, CheckRequest{Identifier: "192.168.1.2", Rule: "candidate"})
+		require.NoError(t, err)
+
+		// O limite de IP continua aplicado
+		assert.False(t, result.DryRun)
+		assert.True(t, result.Allowed)
+		assert.Equal(t, 10, result.Limit)
+		assert.Empty(t, result.Rule)
+		assert.Equal(t, 1, mockStorage.GetCallCount("ip:192.168.1.2"))
+
+		require.NotNil(t, result.Shadow)
+		assert.True(t, result.Shadow.DryRun)
+		assert.False(t, result.Shadow.Allowed)
+		assert.Equal(t, "candidate", result.Shadow.Rule)
+		assert.Equal(t, 1, result.Shadow.Limit)
+		assert.Equal(t, 1, mockStorage.GetCallCount("shadow:rule:candidate:ip:192.168.1.2"))
+		assert.Equal(t, 0, mockStorage.GetCallCount("rule:candidate:ip:192.168.1.2"))
+		assert.Equal(t, before+1, DecisionCount(MetricShadowRejected))
+	})
+
+	t.Run("Enforced limit still rejects under a dry-run rule", func(t *testing.T) {
+		mockStorage.SetAllowResult("ip:192.168.1.4", false, 10)
+
+		result, err := rateLimiter.Evaluate(ctx, CheckRequest{Identifier: "192.168.1.4", Rule: "candidate"})
+		require.NoError(t, err)
+
+		assert.False(t, result.Allowed)
+		assert.False(t, result.DryRun)
+		require.NotNil(t, result.Shadow)
+		assert.True(t, result.Shadow.Allowed)
+	})
+
+	t.Run("Global dry-run", func(t *testing.T) {
+		dryRunLimiter := NewRateLimiter(mockStorage, &config.RateLimitConfig{
+			IPLimit:              10,
+			WindowSeconds:        1,
+			BlockDurationSeconds: 300,
+			DryRun:               true,
+		}, nil)
+
+		result, err := dryRunLimiter.Check(ctx, "192.168.1.3", false)
+		require.NoError(t, err)
+
+		assert.True(t, result.DryRun)
+		assert.Equal(t, 1, mockStorage.GetCallCount("shadow:ip:192.168.1.3"))
+	})
+}
+
+func TestRateLimiterCost(t *testing.T) {
+	ipConfig := &config.RateLimitConfig{
+		IPLimit:              10,
+		WindowSeconds:        1,
+		BlockDurationSeconds: 300,
+	}
+	rateLimiter := NewRateLimiter(NewMockStorageStrategy(), ipConfig, nil)
+	ctx := context.Background()
+
+	_, err := rateLimiter.Evaluate(ctx, CheckRequest{Identifier: "192.168.1.1", Cost: -1})
+	assert.ErrorIs(t, err, ErrInvalidCost)
+
+	// O mock não implementa CostStrategy
+	_, err = rateLimiter.Evaluate(ctx, CheckRequest{Identifier: "192.168.1.1", Cost: 3})
+	assert.ErrorIs(t, err, ErrInvalidCost)
+
+	result, err := rateLimiter.Evaluate(ctx, CheckRequest{Identifier: "192.168.1.1", Cost: 1})
+	require.NoError(t, err)
+	assert.True(t, result.Allowed)
+}
+
+func TestRateLimiterPlans(t *testing.T) {
+	mockStorage := NewMockStorageStrategy()
+	rateLimiter := NewRateLimiter(mockStorage, &config.RateLimitConfig{
+		IPLimit:              10,
+		WindowSeconds:        1,
+		BlockDurationSeconds: 300,
+	}, config.TokenConfigs{
+		"pro_token": config.TokenConfig{Plan: "pro", Limit: 1000, WindowSeconds: 1, BlockDurationSeconds: 60},
+	})
+	rateLimiter.SetPlans(config.PlanConfigs{
+		"pro": config.PlanConfig{Limit: 1000, WindowSeconds: 1, BlockDurationSeconds: 60},
+	})
+	rateLimiter.SetRules(config.RuleConfigs{
+		"pro":    config.RuleConfig{Limit: 1, WindowSeconds: 1, BlockDurationSeconds: 1},
+		"strict": config.RuleConfig{Limit: 2, WindowSeconds: 60, BlockDurationSeconds: 60},
+	})
+	ctx := context.Background()
+
+	t.Run("Plans take priority over rules", func(t *testing.T) {
+		result, err := rateLimiter.Evaluate(ctx, CheckRequest{Identifier: "user-1", IsToken: true, Plan: "pro"})
+		require.NoError(t, err)
+		assert.Equal(t, 1000, result.Limit)
+		assert.Equal(t, "pro", result.Plan)
+	})
+
+	t.Run("Rules are a fallback for plans", func(t *testing.T) {
+		result, err := rateLimiter.Evaluate(ctx, CheckRequest{Identifier: "user-1", IsToken: true, Plan: "strict"})
+		require.NoError(t, err)
+		assert.Equal(t, 2, result.Limit)
+	})
+
+	t.Run("Unknown plan and limit override", func(t *testing.T) {
+		result, err := rateLimiter.Evaluate(ctx, CheckRequest{Identifier: "user-2", IsToken: true, Plan: "gold", Limit: 7})
+		require.NoError(t, err)
+		assert.Equal(t, 7, result.Limit)
+		assert.Empty(t, result.Plan)
+	})
+
+	t.Run("Token plan is reported", func(t *testing.T) {
+		result, err := rateLimiter.Check(ctx, "pro_token", true)
+		require.NoError(t, err)
+		assert.Equal(t, "pro", result.Plan)
+	})
+}
+
+func TestRateLimiterTokenHasher(t *testing.T) {
+	hasher, err := config.NewTokenHasher(config.TokenHashSHA256, "")
+	require.NoError(t, err)
+	digest := hasher("std_secret")
+
+	mockStorage := NewMockStorageStrategy()
+	rateLimiter := NewRateLimiter(mockStorage, &config.RateLimitConfig{
+		IPLimit:              10,
+		WindowSeconds:        1,
+		BlockDurationSeconds: 300,
+	}, config.TokenConfigs{
+		digest: config.TokenConfig{Limit: 100, WindowSeconds: 1, BlockDurationSeconds: 60},
+	})
+	rateLimiter.SetTokenHasher(hasher)
+	ctx := context.Background()
+
+	result, err := rateLimiter.Check(ctx, "std_secret", true)
+	require.NoError(t, err)
+
+	assert.Equal(t, 100, result.Limit)
+	assert.Equal(t, 1, mockStorage.GetCallCount("token:"+digest))
+	assert.Equal(t, 0, mockStorage.GetCallCount("token:std_secret"))
+
+	_, exists := rateLimiter.TokenConfig("std_secret")
+	assert.True(t, exists)
+
+	require.NoError(t, rateLimiter.Reset(ctx, "std_secret", true))
+	assert.Equal(t, 0, mockStorage.GetCallCount("token:"+digest))
+
+	assert.Equal(t, "10.0.0.1", SafeIdentifier("10.0.0.1", false))
+	assert.NotContains(t, SafeIdentifier("std_secret", true), "std_secret")
+}
+
+func TestNewRedisClient(t *testing.T) {
+	standalone, err := NewRedisClient(&config.RedisConfig{
+		Host:         "localhost",
+		Port:         "6379",
+		Username:     "limiter",
+		PoolSize:     20,
+		MinIdleConns: 5,
+		ReadTimeout:  time.Second,
+	})
+	require.NoError(t, err)
+	defer standalone.Close()
+	require.IsType(t, &redis.Client{}, standalone)
+	opts := standalone.(*redis.Client).Options()
+	assert.Equal(t, "localhost:6379", opts.Addr)
+	assert.Equal(t, "limiter", opts.Username)
+	assert.Equal(t, 20, opts.PoolSize)
+	assert.Equal(t, 5, opts.MinIdleConns)
+	assert.Equal(t, time.Second, opts.ReadTimeout)
+	assert.Nil(t, opts.TLSConfig)
+
+	socket, err := NewRedisClient(&config.RedisConfig{
+		Socket: "/var/run/redis/redis.sock",
+		TLS:    config.RedisTLSConfig{Enabled: true, ServerName: "redis.internal"},
+	})
+	require.NoError(t, err)
+	defer socket.Close()
+	opts = socket.(*redis.Client).Options()
+	assert.Equal(t, "unix", opts.Network)
+	assert.Equal(t, "/var/run/redis/redis.sock", opts.Addr)
+	require.NotNil(t, opts.TLSConfig)
+	assert.Equal(t, "redis.internal", opts.TLSConfig.ServerName)
+
+	sentinel, err := NewRedisClient(&config.RedisConfig{
+		Mode:       config.RedisModeSentinel,
+		Addrs:      []string{"sentinel-1:26379", "sentinel-2:26379"},
+		MasterName: "mymaster",
+	})
+	require.NoError(t, err)
+	defer sentinel.Close()
+	assert.IsType(t, &redis.Client{}, sentinel)
+
+	cluster, err := NewRedisClient(&config.RedisConfig{
+		Mode:  config.RedisModeCluster,
+		Addrs: []string{"node-1:6379", "node-2:6379"},
+	})
+	require.NoError(t, err)
+	defer cluster.Close()
+	assert.IsType(t, &redis.ClusterClient{}, cluster)
+
+	shardedConfig := &config.RedisConfig{
+		Mode:     config.RedisModeSharded,
+		Addrs:    []string{"a=redis-1:6379", "redis-2:6379"},
+		Password: "secret",
+	}
+	sharded, err := NewRedisClient(shardedConfig)
+	require.NoError(t, err)
+	defer sharded.Close()
+	assert.Equal(t, "redis-1:6379", sharded.(*redis.Client).Options().Addr)
+
+	shards, err := NewRedisShards(shardedConfig)
+	require.NoError(t, err)
+	require.Len(t, shards, 2)
+	assert.Equal(t, "a", shards[0].Name)
+	assert.Equal(t, "redis-2:6379", shards[1].Name)
+	for _, shard := range shards {
+		opts := shard.Storage.(*RedisStrategy).GetRedisClient().(*redis.Client).Options()
+		assert.Equal(t, shard.Addr, opts.Addr)
+		assert.Equal(t, "secret", opts.Password)
+		shard.Storage.Close()
+	}
+
+	_, err = NewRedisClient(&config.RedisConfig{TLS: config.RedisTLSConfig{Enabled: true, CAFile: "missing-ca.pem"}})
+	assert.Error(t, err)
+}
+
+func TestSlotKey(t *testing.T) {
+	// A hash tag é o trecho entre o primeiro "{" e o primeiro "}" seguinte
+	hashTag := func(key string) string {
+		start := strings.Index(key, "{")
+		end := strings.Index(key[start+1:], "}")
+		return key[start+1 : start+1+end]
+	}
+
+	for _, key := range []string{"ip:10.0.0.1", "rule:login:token:abc", "token:we}ird{"} {
+		counter := slotKey(key)
+		assert.Equal(t, hashTag(counter), hashTag(counter+":block"), key)
+	}
+	assert.Equal(t, "{ip:10.0.0.1}", slotKey("ip:10.0.0.1"))
+}
+
+func TestRateLimiterKeyNamespace(t *testing.T) {
+	mockStorage := NewMockStorageStrategy()
+	ipConfig := &config.RateLimitConfig{
+		IPLimit:              10,
+		WindowSeconds:        1,
+		BlockDurationSeconds: 300,
+		KeyPrefix:            "svc",
+		Tenant:               "acme",
+	}
+
+	rateLimiter := NewRateLimiter(mockStorage, ipConfig, nil)
+	ctx := context.Background()
+
+	_, err := rateLimiter.Check(ctx, "1.2.3.4", false)
+	require.NoError(t, err)
+	assert.Equal(t, 1, mockStorage.GetCallCount("p=svc:t=acme:ip:1.2.3.4"))
+
+	// O tenant da requisição substitui o tenant padrão
+	_, err = rateLimiter.Evaluate(ctx, CheckRequest{Identifier: "1.2.3.4", Tenant: "globex"})
+	require.NoError(t, err)
+	assert.Equal(t, 1, mockStorage.GetCallCount("p=svc:t=globex:ip:1.2.3.4"))
+
+	_, err = rateLimiter.Evaluate(ctx, CheckRequest{Identifier: "1.2.3.4", Tenant: "a*b"})
+	assert.ErrorIs(t, err, ErrInvalidTenant)
+
+	require.NoError(t, rateLimiter.Reset(ctx, "1.2.3.4", false))
+	assert.Equal(t, 0, mockStorage.GetCallCount("p=svc:t=acme:ip:1.2.3.4"))
+	assert.Equal(t, 1, mockStorage.GetCallCount("p=svc:t=globex:ip:1.2.3.4"))
+
+	assert.Equal(t, "", KeyNamespace("", ""))
+	assert.Equal(t, "t=acme:", KeyNamespace("", "acme"))
+	assert.Equal(t, "p=checkout:", KeyNamespace("checkout", ""))
+	assert.Equal(t, "p=svc:t=acme:", KeyNamespace("svc", "acme"))
+	// Só prefixo e só tenant com o mesmo nome não compartilham chaves
+	assert.NotEqual(t, KeyNamespace("checkout", ""), KeyNamespace("", "checkout"))
+}
+
+func TestEscapeGlob(t *testing.T) {
+	assert.Equal(t, "svc:acme:", escapeGlob("svc:acme:"))
+	assert.Equal(t, `a\*b\?c\[d\]e\\f`, escapeGlob(`a*b?c[d]e\f`))
+}
@@ -0,0 +1,117 @@
+// Package limitertest fornece um storage em memória para os testes dos
+// pacotes que usam o limiter.RateLimiter.
+package limitertest
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"fc-pos-golang-rate-limiter/internal/limiter"
+)
+
+var (
+	_ limiter.StorageStrategy = (*Storage)(nil)
+	_ limiter.CostStrategy    = (*Storage)(nil)
+)
+
+// Storage conta o consumo de cada chave e nega quando o limite é excedido,
+// sem janela deslizante nem bloqueio. SetResult força a decisão de uma chave e
+// SetError faz a chave falhar.
+type Storage struct {
+	mu      sync.Mutex
+	used    map[string]int
+	calls   map[string]int
+	results map[string]bool
+	errors  map[string]error
+	closed  bool
+}
+
+func NewStorage() *Storage {
+	return &Storage{
+		used:    make(map[string]int),
+		calls:   make(map[string]int),
+		results: make(map[string]bool),
+		errors:  make(map[string]error),
+	}
+}
+
+func (s *Storage) Allow(ctx context.Context, key string, limit int, window time.Duration, blockDuration time.Duration) (bool, int, time.Time, error) {
+	return s.AllowN(ctx, key, 1, limit, window, blockDuration)
+}
+
+func (s *Storage) AllowN(ctx context.Context, key string, cost int, limit int, window time.Duration, blockDuration time.Duration) (bool, int, time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.calls[key]++
+	if err, exists := s.errors[key]; exists {
+		return false, 0, time.Time{}, err
+	}
+
+	allowed, forced := s.results[key]
+	if forced && !allowed {
+		// Chave bloqueada: sem saldo até o fim do bloqueio
+		return false, 0, time.Now().Add(blockDuration), nil
+	}
+	if !forced && s.used[key]+cost > limit {
+		return false, max(limit-s.used[key], 0), time.Now().Add(blockDuration), nil
+	}
+
+	s.used[key] += cost
+	return true, max(limit-s.used[key], 0), time.Now().Add(window), nil
+}
+
+func (s *Storage) Reset(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.used, key)
+	delete(s.calls, key)
+	delete(s.results, key)
+	delete(s.errors, key)
+	return nil
+}
+
+func (s *Storage) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.closed = true
+	return nil
+}
+
+// Força a decisão da chave, independentemente do consumo
+func (s *Storage) SetResult(key string, allowed bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.results[key] = allowed
+}
+
+// Faz as verificações da chave retornarem err
+func (s *Storage) SetError(key string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.errors[key] = err
+}
+
+// Quantas verificações a chave recebeu, incluindo as negadas e com erro
+func (s *Storage) Calls(key string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.calls[key]
+}
+
+// Unidades consumidas pelas verificações permitidas da chave
+func (s *Storage) Used(key string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.used[key]
+}
+
+// Indica se Close foi chamado
+func (s *Storage) Closed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closed
+}
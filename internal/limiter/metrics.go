@@ -0,0 +1,66 @@
+package limiter
+
+import "expvar"
+
+// Contadores de decisões publicados via expvar (/debug/vars)
+const (
+	MetricAllowed        = "allowed"
+	MetricRejected       = "rejected"
+	MetricShadowAllowed  = "shadow_allowed"
+	MetricShadowRejected = "shadow_rejected"
+	MetricErrors         = "errors"
+	// Requisições rejeitadas por falta de slot de concorrência
+	MetricConcurrencyRejected = "concurrency_rejected"
+)
+
+var decisions = expvar.NewMap("rate_limiter_decisions")
+
+// Registra o resultado de uma verificação
+func recordDecision(result *CheckResult) {
+	switch {
+	case result.DryRun && result.Allowed:
+		decisions.Add(MetricShadowAllowed, 1)
+	case result.DryRun:
+		decisions.Add(MetricShadowRejected, 1)
+	case result.Allowed:
+		decisions.Add(MetricAllowed, 1)
+	default:
+		decisions.Add(MetricRejected, 1)
+	}
+}
+
+func recordError() {
+	decisions.Add(MetricErrors, 1)
+}
+
+func recordConcurrencyRejected() {
+	decisions.Add(MetricConcurrencyRejected, 1)
+}
+
+// Retorna o valor atual de um contador de decisões
+func DecisionCount(name string) int64 {
+	if v, ok := decisions.Get(name).(*expvar.Int); ok {
+		return v.Value()
+	}
+	return 0
+}
+
+// Contadores do cache de bloqueios em memória (BlockCacheStrategy)
+const (
+	MetricBlockCacheHits          = "hits"
+	MetricBlockCacheInvalidations = "invalidations"
+)
+
+var blockCache = expvar.NewMap("rate_limiter_block_cache")
+
+func recordBlockCache(name string) {
+	blockCache.Add(name, 1)
+}
+
+// Retorna o valor atual de um contador do cache de bloqueios
+func BlockCacheCount(name string) int64 {
+	if v, ok := blockCache.Get(name).(*expvar.Int); ok {
+		return v.Value()
+	}
+	return 0
+}
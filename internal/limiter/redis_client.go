@@ -0,0 +1,126 @@
+package limiter
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+
+	"fc-pos-golang-rate-limiter/internal/config"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Cria o cliente Redis do modo configurado: standalone, sentinel (failover) ou
+// cluster, com ACL, TLS, pool e timeouts. No modo sharded retorna o cliente do
+// primeiro nó; contadores, slots e pub/sub devem usar NewRedisShards.
+func NewRedisClient(cfg *config.RedisConfig) (redis.UniversalClient, error) {
+	opts, err := newUniversalOptions(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	switch cfg.Mode {
+	case config.RedisModeSentinel:
+		return redis.NewFailoverClient(opts.Failover()), nil
+	case config.RedisModeCluster:
+		return redis.NewClusterClient(opts.Cluster()), nil
+	case config.RedisModeSharded:
+		shards := cfg.Shards()
+		if len(shards) == 0 {
+			return nil, fmt.Errorf("no shards configured")
+		}
+		simple := opts.Simple()
+		simple.Addr = shards[0].Addr
+		return redis.NewClient(simple), nil
+	default:
+		simple := opts.Simple()
+		simple.Addr = cfg.GetRedisAddr()
+		if cfg.Socket != "" {
+			simple.Network = "unix"
+			simple.Addr = cfg.Socket
+		}
+		return redis.NewClient(simple), nil
+	}
+}
+
+// Cria um RedisStrategy standalone por nó do modo sharded, com as mesmas
+// opções de ACL, TLS, pool e timeouts
+func NewRedisShards(cfg *config.RedisConfig) ([]Shard, error) {
+	opts, err := newUniversalOptions(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	var shards []Shard
+	for _, shard := range cfg.Shards() {
+		simple := opts.Simple()
+		simple.Addr = shard.Addr
+		shards = append(shards, Shard{
+			Name:    shard.Name,
+			Addr:    shard.Addr,
+			Storage: NewRedisStrategy(redis.NewClient(simple)),
+		})
+	}
+	return shards, nil
+}
+
+func newUniversalOptions(cfg *config.RedisConfig) (*redis.UniversalOptions, error) {
+	tlsConfig, err := newRedisTLSConfig(&cfg.TLS)
+	if err != nil {
+		return nil, err
+	}
+
+	return &redis.UniversalOptions{
+		Addrs:            cfg.Addrs,
+		DB:               cfg.DB,
+		Username:         cfg.Username,
+		Password:         cfg.Password,
+		SentinelPassword: cfg.SentinelPassword,
+		MasterName:       cfg.MasterName,
+		MaxRetries:       cfg.MaxRetries,
+		MinRetryBackoff:  cfg.MinRetryBackoff,
+		MaxRetryBackoff:  cfg.MaxRetryBackoff,
+		DialTimeout:      cfg.DialTimeout,
+		ReadTimeout:      cfg.ReadTimeout,
+		WriteTimeout:     cfg.WriteTimeout,
+		PoolSize:         cfg.PoolSize,
+		MinIdleConns:     cfg.MinIdleConns,
+		TLSConfig:        tlsConfig,
+	}, nil
+}
+
+// Monta a configuração TLS; nil quando TLS está desabilitado
+func newRedisTLSConfig(cfg *config.RedisTLSConfig) (*tls.Config, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         cfg.ServerName,
+		InsecureSkipVerify: cfg.InsecureSkipVerify,
+	}
+
+	if cfg.CAFile != "" {
+		pem, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("error reading redis CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("redis CA file %s has no PEM certificates", cfg.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if cfg.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("error loading redis client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
@@ -0,0 +1,63 @@
+package limiter
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Canal padrão do pub/sub das invalidações do cache de bloqueios
+const DefaultBlockCacheChannel = "rate_limiter:block_cache"
+
+// Espera antes de tentar receber de novo após um erro na assinatura
+const resubscribeDelay = time.Second
+
+// Propaga as invalidações do BlockCacheStrategy via pub/sub do Redis
+type RedisBlockInvalidator struct {
+	client  redis.UniversalClient
+	channel string
+}
+
+func NewRedisBlockInvalidator(client redis.UniversalClient, channel string) *RedisBlockInvalidator {
+	if channel == "" {
+		channel = DefaultBlockCacheChannel
+	}
+	return &RedisBlockInvalidator{
+		client:  client,
+		channel: channel,
+	}
+}
+
+func (i *RedisBlockInvalidator) Publish(ctx context.Context, key string) error {
+	return i.client.Publish(ctx, i.channel, key).Err()
+}
+
+// O go-redis reconecta e refaz a assinatura sozinho após um erro; cada
+// confirmação de assinatura limpa o cache, pois mensagens podem ter sido perdidas
+func (i *RedisBlockInvalidator) Subscribe(ctx context.Context, invalidate func(key string)) error {
+	pubsub := i.client.Subscribe(ctx, i.channel)
+	defer pubsub.Close()
+
+	for {
+		msg, err := pubsub.Receive(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(resubscribeDelay):
+			}
+			continue
+		}
+
+		switch msg := msg.(type) {
+		case *redis.Subscription:
+			invalidate("")
+		case *redis.Message:
+			invalidate(msg.Payload)
+		}
+	}
+}
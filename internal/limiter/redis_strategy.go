@@ -3,23 +3,51 @@ package limiter
 import (
 	"context"
 	"fmt"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/go-redis/redis/v8"
 )
 
 type RedisStrategy struct {
-	client *redis.Client
+	client redis.UniversalClient
 }
 
-func NewRedisStrategy(client *redis.Client) *RedisStrategy {
+// Aceita cliente standalone, Sentinel (failover) ou Cluster
+func NewRedisStrategy(client redis.UniversalClient) *RedisStrategy {
 	return &RedisStrategy{
 		client: client,
 	}
 }
 
+// Slots de concorrência no mesmo Redis dos contadores; permite ao
+// ShardedStrategy guardá-los no shard da chave
+func (r *RedisStrategy) Acquire(ctx context.Context, key string, limit int, ttl time.Duration) (string, int, error) {
+	return NewRedisConcurrencyStrategy(r.client).Acquire(ctx, key, limit, ttl)
+}
+
+func (r *RedisStrategy) Refresh(ctx context.Context, key, id string, ttl time.Duration) error {
+	return NewRedisConcurrencyStrategy(r.client).Refresh(ctx, key, id, ttl)
+}
+
+func (r *RedisStrategy) Release(ctx context.Context, key, id string) error {
+	return NewRedisConcurrencyStrategy(r.client).Release(ctx, key, id)
+}
+
+// Pub/sub das invalidações do cache de bloqueios no mesmo Redis
+func (r *RedisStrategy) BlockInvalidator(channel string) BlockInvalidator {
+	return NewRedisBlockInvalidator(r.client, channel)
+}
+
 // Implementa o algoritmo Sliding Window com BlockDuration usando Redis Sorted Sets
 func (r *RedisStrategy) Allow(ctx context.Context, key string, limit int, window time.Duration, blockDuration time.Duration) (bool, int, time.Time, error) {
+	return r.AllowN(ctx, key, 1, limit, window, blockDuration)
+}
+
+// Igual ao Allow, mas consome cost entradas da janela de uma vez
+func (r *RedisStrategy) AllowN(ctx context.Context, key string, cost int, limit int, window time.Duration, blockDuration time.Duration) (bool, int, time.Time, error) {
+	key = slotKey(key)
 	now := time.Now()
 	windowStart := now.Add(-window)
 
@@ -52,12 +80,23 @@ func (r *RedisStrategy) Allow(ctx context.Context, key string, limit int, window
 	}
 
 	// Verifica se estamos dentro do limite (antes de adicionar a requisição atual)
-	allowed := count < int64(limit)
+	allowed := count+int64(cost) <= int64(limit)
 	remaining := limit - int(count)
 	if remaining < 0 {
 		remaining = 0
 	}
 
+	// Custo maior que o saldo da janela apenas nega, sem bloquear a chave
+	if !allowed && count < int64(limit) {
+		return false, remaining, now.Add(window), nil
+	}
+
+	// Sem bloqueio a chave só volta a ter saldo quando a entrada mais antiga
+	// sair da janela; SET com TTL zero criaria um bloqueio permanente
+	if !allowed && blockDuration <= 0 {
+		return false, remaining, r.windowReset(ctx, key, now, window), nil
+	}
+
 	// Se excedeu o limite, bloqueia por blockDuration
 	if !allowed {
 		blockKey := key + ":block"
@@ -69,12 +108,18 @@ func (r *RedisStrategy) Allow(ctx context.Context, key string, limit int, window
 		return false, remaining, resetTime, nil
 	}
 
-	// Se está permitido, adiciona a requisição atual
+	// Se está permitido, adiciona a requisição atual (uma entrada por unidade de custo)
+	members := make([]*redis.Z, cost)
+	for i := range members {
+		member := fmt.Sprintf("%d", now.UnixNano())
+		if i > 0 {
+			member = fmt.Sprintf("%d-%d", now.UnixNano(), i)
+		}
+		members[i] = &redis.Z{Score: float64(now.UnixNano()), Member: member}
+	}
+
 	pipe = r.client.Pipeline()
-	pipe.ZAdd(ctx, key, &redis.Z{
-		Score:  float64(now.UnixNano()),
-		Member: fmt.Sprintf("%d", now.UnixNano()),
-	})
+	pipe.ZAdd(ctx, key, members...)
 	pipe.Expire(ctx, key, window+time.Minute)
 
 	_, err = pipe.Exec(ctx)
@@ -83,28 +128,54 @@ func (r *RedisStrategy) Allow(ctx context.Context, key string, limit int, window
 	}
 
 	// Atualiza o remaining após adicionar a requisição
-	remaining = limit - int(count) - 1
+	remaining = limit - int(count) - cost
 	if remaining < 0 {
 		remaining = 0
 	}
 
 	// Calcula o tempo de reset (quando a entrada mais antiga na janela expirar)
 	resetTime := now.Add(window)
-
-	// Se tivermos entradas, encontramos a mais antiga para calcular o tempo de reset correto
 	if count > 0 {
-		oldestCmd := r.client.ZRangeWithScores(ctx, key, 0, 0)
-		oldest, err := oldestCmd.Result()
-		if err == nil && len(oldest) > 0 {
-			oldestTime := time.Unix(0, int64(oldest[0].Score))
-			resetTime = oldestTime.Add(window)
-		}
+		resetTime = r.windowReset(ctx, key, now, window)
 	}
 
 	return allowed, remaining, resetTime, nil
 }
 
+// Instante em que a entrada mais antiga da janela expira (now + window sem entradas)
+func (r *RedisStrategy) windowReset(ctx context.Context, key string, now time.Time, window time.Duration) time.Time {
+	oldest, err := r.client.ZRangeWithScores(ctx, key, 0, 0).Result()
+	if err != nil || len(oldest) == 0 {
+		return now.Add(window)
+	}
+	return time.Unix(0, int64(oldest[0].Score)).Add(window)
+}
+
+// Soma cost entradas à janela sem verificar o limite (consumo já concedido
+// localmente pelo BatchStrategy) e retorna o total de entradas na janela
+func (r *RedisStrategy) Record(ctx context.Context, key string, cost int, window time.Duration) (int, error) {
+	key = slotKey(key)
+	now := time.Now()
+
+	members := make([]*redis.Z, cost)
+	for i := range members {
+		members[i] = &redis.Z{Score: float64(now.UnixNano()), Member: fmt.Sprintf("%d-b%d", now.UnixNano(), i)}
+	}
+
+	pipe := r.client.Pipeline()
+	pipe.ZRemRangeByScore(ctx, key, "0", fmt.Sprintf("%d", now.Add(-window).UnixNano()))
+	pipe.ZAdd(ctx, key, members...)
+	pipe.Expire(ctx, key, window+time.Minute)
+	countCmd := pipe.ZCard(ctx, key)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, fmt.Errorf("failed to record requests: %w", err)
+	}
+	return int(countCmd.Val()), nil
+}
+
 func (r *RedisStrategy) Reset(ctx context.Context, key string) error {
+	key = slotKey(key)
+
 	// Remove tanto a chave de contagem quanto a de bloqueio
 	pipe := r.client.Pipeline()
 	pipe.Del(ctx, key)
@@ -117,10 +188,98 @@ func (r *RedisStrategy) Close() error {
 	return r.client.Close()
 }
 
-func (r *RedisStrategy) GetRedisClient() *redis.Client {
+// Usado no health check dos shards
+func (r *RedisStrategy) Ping(ctx context.Context) error {
+	return r.client.Ping(ctx).Err()
+}
+
+func (r *RedisStrategy) GetRedisClient() redis.UniversalClient {
 	return r.client
 }
 
+// Quantidade de chaves pedida por iteração do SCAN
+const scanCount = 500
+
+// Percorre com SCAN as chaves do namespace (ex.: KeyNamespace("checkout", "acme")),
+// incluindo as chaves ":block". No modo cluster percorre todos os masters.
+func (r *RedisStrategy) ScanKeys(ctx context.Context, namespace string, fn func(key string) error) error {
+	pattern := "{" + escapeGlob(namespace) + "*"
+
+	var mu sync.Mutex
+	scan := func(ctx context.Context, client *redis.Client) error {
+		iter := client.Scan(ctx, 0, pattern, scanCount).Iterator()
+		for iter.Next(ctx) {
+			mu.Lock()
+			err := fn(iter.Val())
+			mu.Unlock()
+			if err != nil {
+				return err
+			}
+		}
+		return iter.Err()
+	}
+
+	switch client := r.client.(type) {
+	case *redis.ClusterClient:
+		return client.ForEachMaster(ctx, scan)
+	case *redis.Client:
+		return scan(ctx, client)
+	default:
+		return fmt.Errorf("scan not supported for %T", r.client)
+	}
+}
+
+// Remove as chaves do namespace, retornando quantas foram apagadas
+func (r *RedisStrategy) PurgeKeys(ctx context.Context, namespace string) (int, error) {
+	var keys []string
+	if err := r.ScanKeys(ctx, namespace, func(key string) error {
+		keys = append(keys, key)
+		return nil
+	}); err != nil {
+		return 0, err
+	}
+
+	// DEL de uma chave por comando, em lotes: no cluster as chaves ficam em slots diferentes
+	deleted := 0
+	for start := 0; start < len(keys); start += scanCount {
+		end := start + scanCount
+		if end > len(keys) {
+			end = len(keys)
+		}
+
+		pipe := r.client.Pipeline()
+		cmds := make([]*redis.IntCmd, 0, end-start)
+		for _, key := range keys[start:end] {
+			cmds = append(cmds, pipe.Del(ctx, key))
+		}
+		if _, err := pipe.Exec(ctx); err != nil {
+			return deleted, fmt.Errorf("failed to delete keys: %w", err)
+		}
+		for _, cmd := range cmds {
+			deleted += int(cmd.Val())
+		}
+	}
+	return deleted, nil
+}
+
+// Escapa os caracteres especiais do padrão do SCAN
+func escapeGlob(value string) string {
+	var b strings.Builder
+	for _, c := range value {
+		if strings.ContainsRune(`*?[]\`, c) {
+			b.WriteRune('\\')
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
+
+// Envolve a chave em uma hash tag do Redis Cluster: a chave e a sua chave
+// ":block" ficam no mesmo slot
+func slotKey(key string) string {
+	return "{" + key + "}"
+}
+
 // Verifica se a chave está bloqueada e retorna o tempo de reset
 func (r *RedisStrategy) checkBlockStatus(ctx context.Context, key string, now time.Time) (bool, time.Time, error) {
 	blockKey := key + ":block"
@@ -0,0 +1,486 @@
+package limiter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/cespare/xxhash/v2"
+	"github.com/dgryski/go-rendezvous"
+	"github.com/go-redis/redis/v8"
+)
+
+// Intervalo padrão do health check dos shards
+const DefaultShardHealthInterval = time.Second
+
+// Retornado quando nenhum shard está disponível
+var ErrNoShards = errors.New("no healthy shards")
+
+// Operação que o storage do shard não implementa; não tira o shard do hash
+var errShardUnsupported = errors.New("operation not supported by shard storage")
+
+// Storage de um shard: precisa responder ao health check
+type ShardStorage interface {
+	StorageStrategy
+	Ping(ctx context.Context) error
+}
+
+// Nó do ShardedStrategy. Name define a posição no hash; Addr só identifica se
+// o nó mudou ao chamar SetShards.
+type Shard struct {
+	Name    string
+	Addr    string
+	Storage ShardStorage
+}
+
+// Distribui as chaves entre nós independentes por rendezvous hashing. Um shard
+// que falha sai do hash e as suas chaves passam para o próximo nó até o health
+// check vê-lo de volta; os contadores do período ficam no nó substituto.
+type ShardedStrategy struct {
+	healthInterval time.Duration
+
+	mu     sync.RWMutex
+	shards map[string]*shardState
+	ring   *rendezvous.Rendezvous
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+type shardState struct {
+	Shard
+	healthy bool
+}
+
+type ShardedOption func(*ShardedStrategy)
+
+// Define o intervalo do health check que devolve ao hash os shards recuperados
+func WithShardHealthInterval(interval time.Duration) ShardedOption {
+	return func(s *ShardedStrategy) {
+		s.healthInterval = interval
+	}
+}
+
+func NewShardedStrategy(shards []Shard, opts ...ShardedOption) *ShardedStrategy {
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &ShardedStrategy{
+		healthInterval: DefaultShardHealthInterval,
+		shards:         make(map[string]*shardState),
+		cancel:         cancel,
+		done:           make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	s.SetShards(shards)
+	go s.run(ctx)
+
+	return s
+}
+
+func (s *ShardedStrategy) Allow(ctx context.Context, key string, limit int, window time.Duration, blockDuration time.Duration) (bool, int, time.Time, error) {
+	var allowed bool
+	var remaining int
+	var resetTime time.Time
+	err := s.do(ctx, key, func(storage StorageStrategy) (err error) {
+		allowed, remaining, resetTime, err = storage.Allow(ctx, key, limit, window, blockDuration)
+		return err
+	})
+	return allowed, remaining, resetTime, err
+}
+
+func (s *ShardedStrategy) AllowN(ctx context.Context, key string, cost int, limit int, window time.Duration, blockDuration time.Duration) (bool, int, time.Time, error) {
+	var allowed bool
+	var remaining int
+	var resetTime time.Time
+	err := s.do(ctx, key, func(storage StorageStrategy) (err error) {
+		costStorage, ok := storage.(CostStrategy)
+		if !ok {
+			return fmt.Errorf("%w: storage does not support cost %d", ErrInvalidCost, cost)
+		}
+		allowed, remaining, resetTime, err = costStorage.AllowN(ctx, key, cost, limit, window, blockDuration)
+		return err
+	})
+	return allowed, remaining, resetTime, err
+}
+
+// Permite usar o ShardedStrategy sob o BatchStrategy
+func (s *ShardedStrategy) Record(ctx context.Context, key string, cost int, window time.Duration) (int, error) {
+	var count int
+	err := s.do(ctx, key, func(storage StorageStrategy) (err error) {
+		recordStorage, ok := storage.(RecordStrategy)
+		if !ok {
+			return fmt.Errorf("%w: %T does not support record", errShardUnsupported, storage)
+		}
+		count, err = recordStorage.Record(ctx, key, cost, window)
+		return err
+	})
+	return count, err
+}
+
+// Guarda os slots de concorrência no shard da chave, como os contadores. Com o
+// shard fora do ar os slots recomeçam no nó substituto.
+func (s *ShardedStrategy) Acquire(ctx context.Context, key string, limit int, ttl time.Duration) (string, int, error) {
+	var id string
+	var inFlight int
+	err := s.doSlots(ctx, key, func(slots ConcurrencyStrategy) (err error) {
+		id, inFlight, err = slots.Acquire(ctx, key, limit, ttl)
+		return err
+	})
+	return id, inFlight, err
+}
+
+func (s *ShardedStrategy) Refresh(ctx context.Context, key, id string, ttl time.Duration) error {
+	return s.doSlots(ctx, key, func(slots ConcurrencyStrategy) error {
+		return slots.Refresh(ctx, key, id, ttl)
+	})
+}
+
+func (s *ShardedStrategy) Release(ctx context.Context, key, id string) error {
+	return s.doSlots(ctx, key, func(slots ConcurrencyStrategy) error {
+		return slots.Release(ctx, key, id)
+	})
+}
+
+func (s *ShardedStrategy) doSlots(ctx context.Context, key string, fn func(slots ConcurrencyStrategy) error) error {
+	return s.do(ctx, key, func(storage StorageStrategy) error {
+		slots, ok := storage.(ConcurrencyStrategy)
+		if !ok {
+			return fmt.Errorf("%w: %T does not support concurrency slots", errShardUnsupported, storage)
+		}
+		return fn(slots)
+	})
+}
+
+// Reseta a chave em todos os shards saudáveis: após um failover os contadores
+// podem estar no nó substituto
+func (s *ShardedStrategy) Reset(ctx context.Context, key string) error {
+	var errs []error
+	for _, shard := range s.healthyShards() {
+		if err := shard.Storage.Reset(ctx, key); err != nil {
+			errs = append(errs, fmt.Errorf("shard %s: %w", shard.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Encerra o health check e fecha todos os shards
+func (s *ShardedStrategy) Close() error {
+	s.cancel()
+	<-s.done
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var errs []error
+	for name, shard := range s.shards {
+		if err := shard.Storage.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("shard %s: %w", name, err))
+		}
+	}
+	s.shards = make(map[string]*shardState)
+	s.rebuild()
+	return errors.Join(errs...)
+}
+
+// Substitui os nós. Shards com o mesmo nome e endereço são mantidos (e o
+// storage novo é fechado); os removidos são fechados. Apenas as chaves dos
+// nós adicionados ou removidos mudam de lugar.
+func (s *ShardedStrategy) SetShards(shards []Shard) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	next := make(map[string]*shardState, len(shards))
+	for _, shard := range shards {
+		if current, exists := s.shards[shard.Name]; exists && current.Addr == shard.Addr {
+			next[shard.Name] = current
+			if shard.Storage != current.Storage {
+				shard.Storage.Close()
+			}
+			continue
+		}
+		next[shard.Name] = &shardState{Shard: shard, healthy: true}
+	}
+
+	for name, current := range s.shards {
+		if next[name] != current {
+			current.Storage.Close()
+		}
+	}
+
+	s.shards = next
+	s.rebuild()
+}
+
+// Nome do shard que atende a chave; vazio quando nenhum está saudável
+func (s *ShardedStrategy) ShardFor(key string) string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.ring.Lookup(key)
+}
+
+// Nomes dos shards no hash, ordenados
+func (s *ShardedStrategy) Healthy() []string {
+	var names []string
+	for _, shard := range s.healthyShards() {
+		names = append(names, shard.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Todos os shards, inclusive os fora do hash, ordenados pelo nome
+func (s *ShardedStrategy) Shards() []Shard {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	shards := make([]Shard, 0, len(s.shards))
+	for _, shard := range s.shards {
+		shards = append(shards, shard.Shard)
+	}
+	sort.Slice(shards, func(i, j int) bool { return shards[i].Name < shards[j].Name })
+	return shards
+}
+
+// Storages que listam e removem chaves por namespace (ex.: RedisStrategy)
+type keyScanner interface {
+	ScanKeys(ctx context.Context, namespace string, fn func(key string) error) error
+	PurgeKeys(ctx context.Context, namespace string) (int, error)
+}
+
+// Percorre as chaves do namespace em todos os shards, inclusive os fora do hash
+func (s *ShardedStrategy) ScanKeys(ctx context.Context, namespace string, fn func(key string) error) error {
+	for _, shard := range s.Shards() {
+		scanner, ok := shard.Storage.(keyScanner)
+		if !ok {
+			return fmt.Errorf("shard %s: scan not supported for %T", shard.Name, shard.Storage)
+		}
+		if err := scanner.ScanKeys(ctx, namespace, fn); err != nil {
+			return fmt.Errorf("shard %s: %w", shard.Name, err)
+		}
+	}
+	return nil
+}
+
+// Remove as chaves do namespace em todos os shards
+func (s *ShardedStrategy) PurgeKeys(ctx context.Context, namespace string) (int, error) {
+	deleted := 0
+	for _, shard := range s.Shards() {
+		scanner, ok := shard.Storage.(keyScanner)
+		if !ok {
+			return deleted, fmt.Errorf("shard %s: scan not supported for %T", shard.Name, shard.Storage)
+		}
+		n, err := scanner.PurgeKeys(ctx, namespace)
+		deleted += n
+		if err != nil {
+			return deleted, fmt.Errorf("shard %s: %w", shard.Name, err)
+		}
+	}
+	return deleted, nil
+}
+
+// Executa fn no shard da chave. Se o shard estiver fora do ar, ele sai do hash
+// e fn é repetida no próximo nó.
+func (s *ShardedStrategy) do(ctx context.Context, key string, fn func(storage StorageStrategy) error) error {
+	for {
+		s.mu.RLock()
+		shard := s.shards[s.ring.Lookup(key)]
+		s.mu.RUnlock()
+
+		if shard == nil {
+			return ErrNoShards
+		}
+
+		err := fn(shard.Storage)
+		if err == nil || !isShardDown(ctx, err) {
+			return err
+		}
+		s.markDown(shard)
+	}
+}
+
+// Respostas de erro do Redis (ex.: WRONGTYPE) e cancelamentos do chamador não
+// indicam que o nó caiu
+func isShardDown(ctx context.Context, err error) bool {
+	if ctx.Err() != nil || errors.Is(err, ErrInvalidCost) || errors.Is(err, errShardUnsupported) {
+		return false
+	}
+	var redisErr redis.Error
+	return !errors.As(err, &redisErr)
+}
+
+func (s *ShardedStrategy) markDown(shard *shardState) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if shard.healthy {
+		shard.healthy = false
+		s.rebuild()
+	}
+}
+
+// Recria o hash com os shards saudáveis. Deve ser chamado com s.mu adquirido.
+func (s *ShardedStrategy) rebuild() {
+	names := make([]string, 0, len(s.shards))
+	for name, shard := range s.shards {
+		if shard.healthy {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	s.ring = rendezvous.New(names, xxhash.Sum64String)
+}
+
+func (s *ShardedStrategy) healthyShards() []*shardState {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	shards := make([]*shardState, 0, len(s.shards))
+	for _, shard := range s.shards {
+		if shard.healthy {
+			shards = append(shards, shard)
+		}
+	}
+	return shards
+}
+
+// Verifica todos os shards, tirando do hash os que falham e devolvendo os recuperados
+func (s *ShardedStrategy) CheckHealth(ctx context.Context) {
+	s.mu.RLock()
+	shards := make([]*shardState, 0, len(s.shards))
+	for _, shard := range s.shards {
+		shards = append(shards, shard)
+	}
+	s.mu.RUnlock()
+
+	healthy := make([]bool, len(shards))
+	for i, shard := range shards {
+		pingCtx, cancel := context.WithTimeout(ctx, s.healthInterval)
+		healthy[i] = shard.Storage.Ping(pingCtx) == nil
+		cancel()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	changed := false
+	for i, shard := range shards {
+		// Ignora shards removidos por SetShards durante a verificação
+		if s.shards[shard.Name] != shard || shard.healthy == healthy[i] {
+			continue
+		}
+		shard.healthy = healthy[i]
+		changed = true
+	}
+	if changed {
+		s.rebuild()
+	}
+}
+
+func (s *ShardedStrategy) run(ctx context.Context) {
+	defer close(s.done)
+
+	ticker := time.NewTicker(s.healthInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.CheckHealth(ctx)
+		}
+	}
+}
+
+// Storages que propagam as invalidações do cache de bloqueios (ex.: RedisStrategy)
+type blockNotifier interface {
+	BlockInvalidator(channel string) BlockInvalidator
+}
+
+// Propaga as invalidações do BlockCacheStrategy pelo pub/sub de todos os
+// shards: publica em cada shard saudável e assina todos, para que a queda de
+// um nó não isole as instâncias
+type ShardedBlockInvalidator struct {
+	sharded *ShardedStrategy
+	channel string
+}
+
+func NewShardedBlockInvalidator(sharded *ShardedStrategy, channel string) *ShardedBlockInvalidator {
+	return &ShardedBlockInvalidator{sharded: sharded, channel: channel}
+}
+
+// Basta um shard receber a mensagem: as instâncias assinam todos eles
+func (i *ShardedBlockInvalidator) Publish(ctx context.Context, key string) error {
+	var errs []error
+	published := false
+	for _, shard := range i.sharded.healthyShards() {
+		notifier, ok := shard.Storage.(blockNotifier)
+		if !ok {
+			continue
+		}
+		if err := notifier.BlockInvalidator(i.channel).Publish(ctx, key); err != nil {
+			errs = append(errs, fmt.Errorf("shard %s: %w", shard.Name, err))
+			continue
+		}
+		published = true
+	}
+	if published {
+		return nil
+	}
+	if len(errs) == 0 {
+		return ErrNoShards
+	}
+	return errors.Join(errs...)
+}
+
+// Assina o canal em todos os shards, inclusive os fora do hash, e acompanha as
+// mudanças feitas por SetShards a cada intervalo do health check
+func (i *ShardedBlockInvalidator) Subscribe(ctx context.Context, invalidate func(key string)) error {
+	var wg sync.WaitGroup
+	subscriptions := make(map[Shard]context.CancelFunc)
+	defer func() {
+		for _, cancel := range subscriptions {
+			cancel()
+		}
+		wg.Wait()
+	}()
+
+	ticker := time.NewTicker(i.sharded.healthInterval)
+	defer ticker.Stop()
+
+	for {
+		current := make(map[Shard]bool)
+		for _, shard := range i.sharded.Shards() {
+			current[shard] = true
+			notifier, ok := shard.Storage.(blockNotifier)
+			if _, exists := subscriptions[shard]; exists || !ok {
+				continue
+			}
+
+			subscriptionCtx, cancel := context.WithCancel(ctx)
+			subscriptions[shard] = cancel
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				notifier.BlockInvalidator(i.channel).Subscribe(subscriptionCtx, invalidate)
+			}()
+		}
+		for shard, cancel := range subscriptions {
+			if !current[shard] {
+				cancel()
+				delete(subscriptions, shard)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
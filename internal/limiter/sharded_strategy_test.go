@@ -0,0 +1,264 @@
+package limiter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Shard em memória que pode ser derrubado, com slots de concorrência e pub/sub
+type fakeShard struct {
+	*countingStorage
+	slots *MemoryConcurrencyStrategy
+	bus   *memoryInvalidator
+	mu    sync.Mutex
+	down  bool
+}
+
+func newFakeShard() *fakeShard {
+	return &fakeShard{
+		countingStorage: newCountingStorage(),
+		slots:           NewMemoryConcurrencyStrategy(),
+		bus:             newMemoryInvalidator(),
+	}
+}
+
+func (f *fakeShard) setDown(down bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.down = down
+}
+
+func (f *fakeShard) err() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.down {
+		return errors.New("dial tcp: connection refused")
+	}
+	return nil
+}
+
+func (f *fakeShard) Allow(ctx context.Context, key string, limit int, window time.Duration, blockDuration time.Duration) (bool, int, time.Time, error) {
+	if err := f.err(); err != nil {
+		return false, 0, time.Time{}, err
+	}
+	return f.countingStorage.Allow(ctx, key, limit, window, blockDuration)
+}
+
+func (f *fakeShard) Ping(ctx context.Context) error {
+	return f.err()
+}
+
+func (f *fakeShard) Acquire(ctx context.Context, key string, limit int, ttl time.Duration) (string, int, error) {
+	if err := f.err(); err != nil {
+		return "", 0, err
+	}
+	return f.slots.Acquire(ctx, key, limit, ttl)
+}
+
+func (f *fakeShard) Refresh(ctx context.Context, key, id string, ttl time.Duration) error {
+	if err := f.err(); err != nil {
+		return err
+	}
+	return f.slots.Refresh(ctx, key, id, ttl)
+}
+
+func (f *fakeShard) Release(ctx context.Context, key, id string) error {
+	if err := f.err(); err != nil {
+		return err
+	}
+	return f.slots.Release(ctx, key, id)
+}
+
+func (f *fakeShard) BlockInvalidator(channel string) BlockInvalidator {
+	return f.bus
+}
+
+func TestShardedStrategy(t *testing.T) {
+	ctx := context.Background()
+	window := time.Minute
+
+	newShards := func(names ...string) ([]Shard, map[string]*fakeShard) {
+		shards := make([]Shard, 0, len(names))
+		fakes := make(map[string]*fakeShard, len(names))
+		for _, name := range names {
+			fakes[name] = newFakeShard()
+			shards = append(shards, Shard{Name: name, Addr: name + ":6379", Storage: fakes[name]})
+		}
+		return shards, fakes
+	}
+
+	keys := make([]string, 300)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("ip:10.0.%d.%d", i/256, i%256)
+	}
+
+	t.Run("Keys are spread and stay on their shard", func(t *testing.T) {
+		shards, fakes := newShards("a", "b", "c")
+		sharded := NewShardedStrategy(shards, WithShardHealthInterval(time.Hour))
+		defer sharded.Close()
+
+		for _, key := range keys {
+			_, _, _, err := sharded.Allow(ctx, key, 10, window, window)
+			require.NoError(t, err)
+			_, _, _, err = sharded.Allow(ctx, key, 10, window, window)
+			require.NoError(t, err)
+			assert.Equal(t, 2, fakes[sharded.ShardFor(key)].counts[key])
+		}
+		for name, fake := range fakes {
+			assert.Greater(t, len(fake.counts), 50, name)
+		}
+	})
+
+	t.Run("Dead shard fails over to the next node and comes back", func(t *testing.T) {
+		shards, fakes := newShards("a", "b", "c")
+		sharded := NewShardedStrategy(shards, WithShardHealthInterval(time.Hour))
+		defer sharded.Close()
+
+		key := keys[0]
+		owner := sharded.ShardFor(key)
+		fakes[owner].setDown(true)
+
+		allowed, _, _, err := sharded.Allow(ctx, key, 10, window, window)
+		require.NoError(t, err)
+		assert.True(t, allowed)
+		assert.NotContains(t, sharded.Healthy(), owner)
+		assert.NotEqual(t, owner, sharded.ShardFor(key))
+
+		fakes[owner].setDown(false)
+		sharded.CheckHealth(ctx)
+		assert.Equal(t, []string{"a", "b", "c"}, sharded.Healthy())
+		assert.Equal(t, owner, sharded.ShardFor(key))
+
+		for _, fake := range fakes {
+			fake.setDown(true)
+		}
+		_, _, _, err = sharded.Allow(ctx, key, 10, window, window)
+		assert.ErrorIs(t, err, ErrNoShards)
+	})
+
+	t.Run("Redis replies do not fail over", func(t *testing.T) {
+		storage := NewMockStorageStrategy()
+		storage.SetAllowError("ip:1.1.1.1", fmt.Errorf("failed to get count: %w", redis.Nil))
+		sharded := NewShardedStrategy([]Shard{{Name: "a", Storage: pingableMock{storage}}}, WithShardHealthInterval(time.Hour))
+		defer sharded.Close()
+
+		_, _, _, err := sharded.Allow(ctx, "ip:1.1.1.1", 10, window, window)
+		assert.ErrorIs(t, err, redis.Nil)
+		assert.Equal(t, []string{"a"}, sharded.Healthy())
+	})
+
+	t.Run("Concurrency slots live on the key's shard", func(t *testing.T) {
+		shards, fakes := newShards("a", "b", "c")
+		sharded := NewShardedStrategy(shards, WithShardHealthInterval(time.Hour))
+		defer sharded.Close()
+
+		key := "inflight:" + keys[0]
+		owner := sharded.ShardFor(key)
+		id, inFlight, err := sharded.Acquire(ctx, key, 1, time.Minute)
+		require.NoError(t, err)
+		assert.NotEmpty(t, id)
+		assert.Equal(t, 1, inFlight)
+		assert.Len(t, fakes[owner].slots.slots[key], 1)
+
+		// O limite vale para todas as instâncias que usam os mesmos shards
+		other, _, err := sharded.Acquire(ctx, key, 1, time.Minute)
+		require.NoError(t, err)
+		assert.Empty(t, other)
+
+		require.NoError(t, sharded.Refresh(ctx, key, id, time.Minute))
+		require.NoError(t, sharded.Release(ctx, key, id))
+		assert.Empty(t, fakes[owner].slots.slots[key])
+
+		// Com o dono fora do ar, o slot é ocupado no próximo nó
+		fakes[owner].setDown(true)
+		id, _, err = sharded.Acquire(ctx, key, 1, time.Minute)
+		require.NoError(t, err)
+		assert.NotEmpty(t, id)
+		assert.Len(t, fakes[sharded.ShardFor(key)].slots.slots[key], 1)
+	})
+
+	t.Run("Invalidations reach subscribers through every shard", func(t *testing.T) {
+		shards, fakes := newShards("a", "b", "c")
+		sharded := NewShardedStrategy(shards, WithShardHealthInterval(time.Hour))
+		defer sharded.Close()
+		invalidator := NewShardedBlockInvalidator(sharded, "")
+
+		subscribeCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		var mu sync.Mutex
+		received := make(map[string]int)
+		go invalidator.Subscribe(subscribeCtx, func(key string) {
+			mu.Lock()
+			defer mu.Unlock()
+			received[key]++
+		})
+		for _, fake := range fakes {
+			<-fake.bus.subscribed
+		}
+
+		require.NoError(t, invalidator.Publish(ctx, "ip:1.1.1.1"))
+		mu.Lock()
+		assert.Equal(t, 3, received["ip:1.1.1.1"])
+		mu.Unlock()
+
+		// Um shard fora do hash não recebe a mensagem, mas os demais sim
+		fakes["a"].setDown(true)
+		sharded.CheckHealth(ctx)
+		require.NoError(t, invalidator.Publish(ctx, "ip:2.2.2.2"))
+		mu.Lock()
+		assert.Equal(t, 2, received["ip:2.2.2.2"])
+		mu.Unlock()
+
+		for _, fake := range fakes {
+			fake.setDown(true)
+		}
+		sharded.CheckHealth(ctx)
+		assert.ErrorIs(t, invalidator.Publish(ctx, "ip:3.3.3.3"), ErrNoShards)
+	})
+
+	t.Run("Adding a shard only moves keys to it", func(t *testing.T) {
+		shards, _ := newShards("a", "b", "c")
+		sharded := NewShardedStrategy(shards, WithShardHealthInterval(time.Hour))
+		defer sharded.Close()
+
+		before := make(map[string]string, len(keys))
+		for _, key := range keys {
+			before[key] = sharded.ShardFor(key)
+		}
+
+		added, _ := newShards("d")
+		sharded.SetShards(append(shards, added...))
+
+		moved := 0
+		for _, key := range keys {
+			if owner := sharded.ShardFor(key); owner != before[key] {
+				assert.Equal(t, "d", owner)
+				moved++
+			}
+		}
+		assert.Greater(t, moved, 0)
+		assert.Less(t, moved, len(keys)/2)
+
+		// Remover o shard devolve as chaves ao dono anterior
+		sharded.SetShards(shards)
+		for _, key := range keys {
+			assert.Equal(t, before[key], sharded.ShardFor(key))
+		}
+	})
+}
+
+type pingableMock struct {
+	*MockStorageStrategy
+}
+
+func (pingableMock) Ping(ctx context.Context) error {
+	return nil
+}
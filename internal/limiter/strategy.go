@@ -15,6 +15,21 @@ type StorageStrategy interface {
 	Close() error
 }
 
+// Implementada por storages que conseguem consumir mais de uma unidade por
+// requisição (custo) de forma atômica
+type CostStrategy interface {
+	// AllowN verifica e consome cost unidades do limite da chave
+	AllowN(ctx context.Context, key string, cost int, limit int, window time.Duration, blockDuration time.Duration) (allowed bool, remaining int, resetTime time.Time, err error)
+}
+
+// Implementada por storages que aceitam consumo já concedido localmente,
+// usado pelo BatchStrategy para sincronizar as contagens em lote
+type RecordStrategy interface {
+	// Record soma cost unidades à janela da chave sem verificar o limite e
+	// retorna o total consumido na janela
+	Record(ctx context.Context, key string, cost int, window time.Duration) (count int, err error)
+}
+
 type RateLimitResult struct {
 	Allowed   bool
 	Remaining int
@@ -0,0 +1,168 @@
+package limiter
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Menor espera entre duas tentativas, evitando repetir a verificação em
+// sequência quando o reset já passou
+const minWaitDelay = 10 * time.Millisecond
+
+// Resultado de Reserve
+type Reservation struct {
+	Result *CheckResult
+	// Delay é o tempo até a chave voltar a ter saldo; zero quando a requisição
+	// foi permitida (ou em dry-run)
+	Delay time.Duration
+}
+
+// Verifica a requisição como Evaluate, mas sem bloquear a chave ao exceder o
+// limite, e informa quanto esperar até haver saldo. Um bloqueio já aplicado
+// entra na espera. Quem desiste de esperar deve chamar Evaluate para aplicar
+// o bloqueio como no modo normal.
+func (rl *RateLimiter) Reserve(ctx context.Context, req CheckRequest) (*Reservation, error) {
+	reservation, err := rl.reserve(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	recordResult(reservation.Result)
+	return reservation, nil
+}
+
+// Reserve sem registrar a decisão nas métricas
+func (rl *RateLimiter) reserve(ctx context.Context, req CheckRequest) (*Reservation, error) {
+	req = req.withoutBlock()
+	req.noRecord = true
+	result, err := rl.Evaluate(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	reservation := &Reservation{Result: result}
+	if !result.Allowed && !result.DryRun {
+		reservation.Delay = max(time.Until(result.ResetTime), minWaitDelay)
+	}
+	return reservation, nil
+}
+
+// Espera até a requisição ser permitida ou até maxWait. Quando a espera
+// necessária passa de maxWait, verifica com Evaluate (aplicando o bloqueio)
+// e retorna o resultado negado, sem erro; se a fila não anda a tempo, nega
+// sem bloquear. Se o contexto for cancelado, retorna o erro dele.
+// Requisições da mesma chave esperam em fila nesta instância, para que apenas
+// uma por vez tente de novo após o reset. Só a decisão final entra nas métricas.
+func (rl *RateLimiter) Wait(ctx context.Context, req CheckRequest, maxWait time.Duration) (*CheckResult, error) {
+	deadline := time.Now().Add(maxWait)
+
+	reservation, err := rl.reserve(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if reservation.Delay == 0 {
+		recordResult(reservation.Result)
+		return reservation.Result, nil
+	}
+	if time.Now().Add(reservation.Delay).After(deadline) {
+		return rl.Evaluate(ctx, req)
+	}
+
+	release, err := rl.waitQueues.acquire(ctx, waitKey(req), deadline)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		// A fila não andou a tempo: nega sem bloquear quem está à frente
+		recordResult(reservation.Result)
+		return reservation.Result, nil
+	}
+	defer release()
+
+	for {
+		// O primeiro da fila pode ter consumido o saldo: verifica de novo
+		reservation, err = rl.reserve(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		if reservation.Delay == 0 {
+			recordResult(reservation.Result)
+			return reservation.Result, nil
+		}
+		if time.Now().Add(reservation.Delay).After(deadline) {
+			return rl.Evaluate(ctx, req)
+		}
+
+		timer := time.NewTimer(reservation.Delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// Registra a decisão e a da regra em dry-run avaliada junto com ela
+func recordResult(result *CheckResult) {
+	recordDecision(result)
+	if result.Shadow != nil {
+		recordDecision(result.Shadow)
+	}
+}
+
+// Identifica a fila de espera: as mesmas requisições compartilham a chave no storage
+func waitKey(req CheckRequest) string {
+	return fmt.Sprintf("%s|%t|%s|%s|%s", req.Tenant, req.IsToken, req.Rule, req.Plan, req.Identifier)
+}
+
+// Filas de espera por chave; a fila é removida quando não há mais ninguém nela
+type waitQueues struct {
+	mu     sync.Mutex
+	queues map[string]*waitQueue
+}
+
+type waitQueue struct {
+	// Vez de tentar de novo: só um por fila
+	turn chan struct{}
+	refs int
+}
+
+// Entra na fila da chave e aguarda a vez até deadline
+func (w *waitQueues) acquire(ctx context.Context, key string, deadline time.Time) (func(), error) {
+	w.mu.Lock()
+	if w.queues == nil {
+		w.queues = make(map[string]*waitQueue)
+	}
+	queue, exists := w.queues[key]
+	if !exists {
+		queue = &waitQueue{turn: make(chan struct{}, 1)}
+		w.queues[key] = queue
+	}
+	queue.refs++
+	w.mu.Unlock()
+
+	leave := func() {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		queue.refs--
+		if queue.refs == 0 {
+			delete(w.queues, key)
+		}
+	}
+
+	ctx, cancel := context.WithDeadline(ctx, deadline)
+	defer cancel()
+
+	select {
+	case queue.turn <- struct{}{}:
+		return func() {
+			<-queue.turn
+			leave()
+		}, nil
+	case <-ctx.Done():
+		leave()
+		return nil, ctx.Err()
+	}
+}
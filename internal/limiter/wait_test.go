@@ -0,0 +1,97 @@
+package limiter
+
+import (
+	"context"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"fc-pos-golang-rate-limiter/internal/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReserveAndWait(t *testing.T) {
+	ctx := context.Background()
+	storage, err := NewBoltStrategy(filepath.Join(t.TempDir(), "wait.db"))
+	require.NoError(t, err)
+	defer storage.Close()
+
+	rateLimiter := NewRateLimiter(storage, &config.RateLimitConfig{IPLimit: 10, WindowSeconds: 1}, nil)
+	rateLimiter.SetRules(config.RuleConfigs{
+		"batch": config.RuleConfig{Limit: 2, WindowSeconds: 1, BlockDurationSeconds: 300},
+	})
+	req := CheckRequest{Identifier: "10.9.0.1", Rule: "batch"}
+
+	for i := 0; i < 2; i++ {
+		reservation, err := rateLimiter.Reserve(ctx, req)
+		require.NoError(t, err)
+		assert.True(t, reservation.Result.Allowed)
+		assert.Zero(t, reservation.Delay)
+	}
+
+	// Reserve não aplica o bloqueio de 300s: a espera é até a janela liberar
+	reservation, err := rateLimiter.Reserve(ctx, req)
+	require.NoError(t, err)
+	assert.False(t, reservation.Result.Allowed)
+	assert.Greater(t, reservation.Delay, time.Duration(0))
+	assert.LessOrEqual(t, reservation.Delay, time.Second)
+
+	// O cancelamento do contexto interrompe a espera
+	cancelCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	_, err = rateLimiter.Wait(cancelCtx, req, 5*time.Second)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// Só a decisão final entra nas métricas, não cada nova tentativa
+	allowedBefore, rejectedBefore := DecisionCount(MetricAllowed), DecisionCount(MetricRejected)
+	result, err := rateLimiter.Wait(ctx, req, 2*time.Second)
+	require.NoError(t, err)
+	assert.True(t, result.Allowed)
+	assert.Equal(t, allowedBefore+1, DecisionCount(MetricAllowed))
+	assert.Equal(t, rejectedBefore, DecisionCount(MetricRejected))
+
+	t.Run("Giving up applies the block", func(t *testing.T) {
+		req := CheckRequest{Identifier: "10.9.0.3", Rule: "batch"}
+		for i := 0; i < 2; i++ {
+			result, err := rateLimiter.Evaluate(ctx, req)
+			require.NoError(t, err)
+			assert.True(t, result.Allowed)
+		}
+
+		// A espera necessária passa do máximo: nega sem esperar e bloqueia
+		start := time.Now()
+		result, err := rateLimiter.Wait(ctx, req, 10*time.Millisecond)
+		require.NoError(t, err)
+		assert.False(t, result.Allowed)
+		assert.Less(t, time.Since(start), 100*time.Millisecond)
+
+		reservation, err := rateLimiter.Reserve(ctx, req)
+		require.NoError(t, err)
+		assert.Greater(t, reservation.Delay, time.Minute)
+	})
+
+	t.Run("Waiters of the same key are queued", func(t *testing.T) {
+		req := CheckRequest{Identifier: "10.9.0.2", Rule: "batch"}
+
+		var wg sync.WaitGroup
+		var allowed atomic.Int32
+		for i := 0; i < 4; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				result, err := rateLimiter.Wait(ctx, req, 3*time.Second)
+				if assert.NoError(t, err) && result.Allowed {
+					allowed.Add(1)
+				}
+			}()
+		}
+		wg.Wait()
+
+		assert.Equal(t, int32(4), allowed.Load())
+		assert.Empty(t, rateLimiter.waitQueues.queues)
+	})
+}
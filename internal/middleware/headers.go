@@ -0,0 +1,58 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"fc-pos-golang-rate-limiter/internal/config"
+	"fc-pos-golang-rate-limiter/internal/limiter"
+	"fc-pos-golang-rate-limiter/pkg/response"
+)
+
+// Escreve os headers de rate limit de acordo com o modo configurado.
+// No modo legado envia X-RateLimit-* e no modo IETF envia RateLimit-Policy e RateLimit
+// (draft-ietf-httpapi-ratelimit-headers).
+func SetRateLimitHeaders(h http.Header, cfg config.HeaderConfig, result *limiter.CheckResult, now time.Time) {
+	if cfg.SendLegacy() {
+		h.Set(cfg.LimitHeader, strconv.Itoa(result.Limit))
+		h.Set(cfg.RemainingHeader, strconv.Itoa(result.Remaining))
+		h.Set(cfg.ResetHeader, formatReset(cfg.ResetFormat, result.ResetTime, now))
+	}
+
+	if cfg.SendIETF() {
+		policy := PolicyName(result)
+		h.Set(cfg.PolicyHeader, fmt.Sprintf("%q;q=%d;w=%d", policy, result.Limit, int(result.Window.Seconds())))
+		h.Set(cfg.RateLimitHeader, fmt.Sprintf("%q;r=%d;t=%d", policy, result.Remaining, response.RetryAfterSeconds(result.ResetTime, now)))
+	}
+}
+
+// Formata o valor do header de reset legado
+func formatReset(format string, resetTime, now time.Time) string {
+	switch format {
+	case config.ResetFormatDelta:
+		return strconv.Itoa(response.RetryAfterSeconds(resetTime, now))
+	case config.ResetFormatEpoch:
+		return strconv.FormatInt(resetTime.Unix(), 10)
+	default:
+		return resetTime.Format(time.RFC3339)
+	}
+}
+
+// Nome da política usado nos headers IETF e nas respostas de erro
+func PolicyName(result *limiter.CheckResult) string {
+	if result.Concurrency {
+		return "concurrency"
+	}
+	if result.Rule != "" {
+		return result.Rule
+	}
+	if result.Plan != "" {
+		return result.Plan
+	}
+	if result.IsToken {
+		return "token"
+	}
+	return "ip"
+}
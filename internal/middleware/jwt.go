@@ -0,0 +1,58 @@
+package middleware
+
+import (
+	"log"
+	"net/http"
+
+	"fc-pos-golang-rate-limiter/internal/jwtauth"
+)
+
+// Claims do JWT usados para identificar o cliente
+type JWTClaims struct {
+	// Key é o claim que identifica o cliente (ex.: sub, org_id)
+	Key string
+	// Plan é o claim com o nome do plano; Limit o claim com o limite numérico
+	Plan  string
+	Limit string
+}
+
+// Identifica o cliente pelo JWT do header "Authorization: Bearer".
+// Requisições sem bearer token usam o fallback; tokens inválidos ou sem o
+// claim de chave são limitados pelo IP.
+func JWTIdentity(verifier *jwtauth.Verifier, claims JWTClaims, fallback IdentityFunc) IdentityFunc {
+	if fallback == nil {
+		fallback = IdentityFromKey(DefaultKeyFunc)
+	}
+	bearer := KeyFromBearer()
+
+	return func(r *http.Request) Identity {
+		token, _ := bearer(r)
+		if token == "" {
+			return fallback(r)
+		}
+
+		ip := extractIP(r)
+		tokenClaims, err := verifier.Verify(token)
+		if err != nil {
+			log.Printf("Rate limiter: invalid JWT, using IP limit: %v | IP: %s", err, ip)
+			return Identity{Identifier: ip}
+		}
+
+		identifier := tokenClaims.String(claims.Key)
+		if identifier == "" {
+			log.Printf("Rate limiter: JWT without claim %q, using IP limit | IP: %s", claims.Key, ip)
+			return Identity{Identifier: ip}
+		}
+
+		identity := Identity{Identifier: identifier, JWT: true}
+		if claims.Plan != "" {
+			identity.Plan = tokenClaims.String(claims.Plan)
+		}
+		if claims.Limit != "" {
+			if limit, ok := tokenClaims.Int(claims.Limit); ok && limit > 0 {
+				identity.Limit = limit
+			}
+		}
+		return identity
+	}
+}
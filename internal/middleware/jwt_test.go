@@ -0,0 +1,121 @@
+package middleware
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"fc-pos-golang-rate-limiter/internal/config"
+	"fc-pos-golang-rate-limiter/internal/jwtauth"
+	"fc-pos-golang-rate-limiter/internal/limiter"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func signTestJWT(t *testing.T, secret string, claims map[string]interface{}) string {
+	encode := func(v interface{}) string {
+		data, err := json.Marshal(v)
+		require.NoError(t, err)
+		return base64.RawURLEncoding.EncodeToString(data)
+	}
+
+	signed := encode(map[string]string{"alg": "HS256", "typ": "JWT"}) + "." + encode(claims)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(signed))
+	return signed + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func TestJWTIdentity(t *testing.T) {
+	mockStorage := NewMockStorageStrategy()
+	rateLimiter := limiter.NewRateLimiter(mockStorage, &config.RateLimitConfig{
+		IPLimit:              5,
+		WindowSeconds:        1,
+		BlockDurationSeconds: 300,
+	}, config.TokenConfigs{
+		"vip_token": config.TokenConfig{Limit: 10000, WindowSeconds: 1},
+	})
+	rateLimiter.SetRules(config.RuleConfigs{
+		"pro": config.RuleConfig{Limit: 1000, WindowSeconds: 1, BlockDurationSeconds: 60},
+	})
+
+	identity := JWTIdentity(jwtauth.NewHS256Verifier([]byte("secret")), JWTClaims{
+		Key:   "org_id",
+		Plan:  "plan",
+		Limit: "rate_limit",
+	}, nil)
+	handler := RateLimitMiddleware(rateLimiter, WithIdentityFunc(identity))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	serve := func(authorization string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = "10.6.0.1:12345"
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	t.Run("Plan from claim", func(t *testing.T) {
+		rr := serve("Bearer " + signTestJWT(t, "secret", map[string]interface{}{"org_id": "acme", "plan": "pro"}))
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, "1000", rr.Header().Get("X-RateLimit-Limit"))
+		assert.Equal(t, 1, mockStorage.callCounts["jwt:acme"])
+	})
+
+	t.Run("Limit from claim", func(t *testing.T) {
+		rr := serve("Bearer " + signTestJWT(t, "secret", map[string]interface{}{"org_id": "globex", "rate_limit": 42}))
+
+		assert.Equal(t, "42", rr.Header().Get("X-RateLimit-Limit"))
+		assert.Equal(t, 1, mockStorage.callCounts["jwt:globex"])
+	})
+
+	t.Run("Unknown plan uses default limits", func(t *testing.T) {
+		rr := serve("Bearer " + signTestJWT(t, "secret", map[string]interface{}{"org_id": "initech", "plan": "enterprise"}))
+
+		assert.Equal(t, "5", rr.Header().Get("X-RateLimit-Limit"))
+	})
+
+	t.Run("Invalid token falls back to IP", func(t *testing.T) {
+		rr := serve("Bearer " + signTestJWT(t, "wrong", map[string]interface{}{"org_id": "acme", "plan": "pro"}))
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, "5", rr.Header().Get("X-RateLimit-Limit"))
+		assert.Equal(t, 1, mockStorage.callCounts["ip:10.6.0.1"])
+		assert.Equal(t, 1, mockStorage.callCounts["jwt:acme"])
+	})
+
+	t.Run("Claims do not share keys or plans with API_KEY tokens", func(t *testing.T) {
+		// Um API_KEY igual ao claim não consome o contador do usuário do JWT
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = "10.6.0.3:12345"
+		req.Header.Set("API_KEY", "acme")
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+		assert.Equal(t, 1, mockStorage.callCounts["token:acme"])
+		assert.Equal(t, 1, mockStorage.callCounts["jwt:acme"])
+
+		// Um claim igual ao nome de um token não herda os limites do tokens.json
+		rr := serve("Bearer " + signTestJWT(t, "secret", map[string]interface{}{"org_id": "vip_token"}))
+		assert.Equal(t, "5", rr.Header().Get("X-RateLimit-Limit"))
+		assert.Equal(t, 1, mockStorage.callCounts["jwt:vip_token"])
+		assert.Zero(t, mockStorage.callCounts["token:vip_token"])
+	})
+
+	t.Run("Without bearer token uses fallback", func(t *testing.T) {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = "10.6.0.2:12345"
+		req.Header.Set("API_KEY", "static_token")
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		assert.Equal(t, 1, mockStorage.callCounts["token:static_token"])
+	})
+}
@@ -0,0 +1,181 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Separador das partes de uma chave composta
+const compositeKeySeparator = "|"
+
+// Identifica o cliente pelo IP (X-Forwarded-For, X-Real-IP ou RemoteAddr)
+func KeyFromIP() KeyFunc {
+	return func(r *http.Request) (string, bool) {
+		return extractIP(r), false
+	}
+}
+
+// Identifica o cliente pelo token do header "Authorization: Bearer <token>"
+func KeyFromBearer() KeyFunc {
+	return func(r *http.Request) (string, bool) {
+		scheme, token, found := strings.Cut(r.Header.Get("Authorization"), " ")
+		if !found || !strings.EqualFold(scheme, "Bearer") {
+			return "", false
+		}
+		token = strings.TrimSpace(token)
+		return token, token != ""
+	}
+}
+
+// Identifica o cliente pelo valor de um header (ex.: X-Tenant-ID)
+func KeyFromHeader(name string) KeyFunc {
+	return func(r *http.Request) (string, bool) {
+		return r.Header.Get(name), false
+	}
+}
+
+// Identifica o cliente por um parâmetro da query string
+func KeyFromQuery(param string) KeyFunc {
+	return func(r *http.Request) (string, bool) {
+		return r.URL.Query().Get(param), false
+	}
+}
+
+// Identifica o cliente pelo valor de um cookie
+func KeyFromCookie(name string) KeyFunc {
+	return func(r *http.Request) (string, bool) {
+		cookie, err := r.Cookie(name)
+		if err != nil {
+			return "", false
+		}
+		return cookie.Value, false
+	}
+}
+
+// Identifica o cliente por um parâmetro de path do chi (ex.: {tenant}).
+// O parâmetro só existe quando o middleware roda depois do roteamento,
+// isto é, registrado com router.With(...) ou no próprio handler.
+func KeyFromURLParam(name string) KeyFunc {
+	return func(r *http.Request) (string, bool) {
+		return chi.URLParam(r, name), false
+	}
+}
+
+// Identifica a rota pelo método e pelo padrão do chi (ex.: "GET /orders/{id}"),
+// ou pelo path quando o padrão ainda não é conhecido
+func KeyFromRoute() KeyFunc {
+	return func(r *http.Request) (string, bool) {
+		if rctx := chi.RouteContext(r.Context()); rctx != nil {
+			if pattern := rctx.RoutePattern(); pattern != "" && !strings.HasSuffix(pattern, "/*") {
+				return r.Method + " " + pattern, false
+			}
+		}
+		return r.Method + " " + r.URL.Path, false
+	}
+}
+
+// Identifica o cliente pelo subject do certificado TLS verificado.
+// Certificados não verificados pelo servidor são ignorados.
+func KeyFromClientCert() KeyFunc {
+	return func(r *http.Request) (string, bool) {
+		if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+			return "", false
+		}
+		return r.TLS.VerifiedChains[0][0].Subject.String(), true
+	}
+}
+
+// Marca o identificador extraído como token, aplicando os limites de tokens.json
+func AsToken(fn KeyFunc) KeyFunc {
+	return func(r *http.Request) (string, bool) {
+		identifier, _ := fn(r)
+		return identifier, identifier != ""
+	}
+}
+
+// Combina extractors em uma chave composta (ex.: IP+rota ou tenant+token).
+// Se alguma parte estiver vazia a chave inteira é vazia. A chave é de token
+// se alguma das partes for: os contadores usam a chave composta, enquanto a
+// parte de token (Identity.Token) é usada na busca no tokens.json e na
+// validação do token.
+func CompositeKey(fns ...KeyFunc) IdentityFunc {
+	return func(r *http.Request) Identity {
+		parts := make([]string, 0, len(fns))
+		var identity Identity
+		for _, fn := range fns {
+			part, partIsToken := fn(r)
+			if part == "" {
+				return Identity{}
+			}
+			parts = append(parts, part)
+			if partIsToken && !identity.IsToken {
+				identity.IsToken = true
+				identity.Token = part
+			}
+		}
+		identity.Identifier = strings.Join(parts, compositeKeySeparator)
+		return identity
+	}
+}
+
+// Usa o primeiro extractor que retornar um identificador
+func FirstKey(fns ...KeyFunc) KeyFunc {
+	return func(r *http.Request) (string, bool) {
+		for _, fn := range fns {
+			if identifier, isToken := fn(r); identifier != "" {
+				return identifier, isToken
+			}
+		}
+		return "", false
+	}
+}
+
+// Monta a identificação a partir de uma especificação textual, com partes
+// separadas por "+": ip, route, api_key, bearer, cert, header:<nome>,
+// query:<nome>, cookie:<nome> e param:<nome>. Ex.: "header:X-Tenant-ID+bearer".
+func ParseKeyFunc(spec string) (IdentityFunc, error) {
+	var fns []KeyFunc
+	for _, part := range strings.Split(spec, "+") {
+		kind, arg, _ := strings.Cut(strings.TrimSpace(part), ":")
+
+		switch kind {
+		case "header", "query", "cookie", "param":
+			if arg == "" {
+				return nil, fmt.Errorf("key extractor %q requires a name", kind)
+			}
+		}
+
+		var fn KeyFunc
+		switch kind {
+		case "ip":
+			fn = KeyFromIP()
+		case "route":
+			fn = KeyFromRoute()
+		case "api_key":
+			fn = AsToken(KeyFromHeader("API_KEY"))
+		case "bearer":
+			fn = KeyFromBearer()
+		case "cert":
+			fn = KeyFromClientCert()
+		case "header":
+			fn = KeyFromHeader(arg)
+		case "query":
+			fn = KeyFromQuery(arg)
+		case "cookie":
+			fn = KeyFromCookie(arg)
+		case "param":
+			fn = KeyFromURLParam(arg)
+		default:
+			return nil, fmt.Errorf("unknown key extractor %q", part)
+		}
+		fns = append(fns, fn)
+	}
+
+	if len(fns) == 1 {
+		return IdentityFromKey(fns[0]), nil
+	}
+	return CompositeKey(fns...), nil
+}
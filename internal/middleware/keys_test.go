@@ -0,0 +1,191 @@
+package middleware
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"fc-pos-golang-rate-limiter/internal/config"
+	"fc-pos-golang-rate-limiter/internal/limiter"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeyExtractors(t *testing.T) {
+	req := httptest.NewRequest("GET", "/orders/42?client=mobile", nil)
+	req.RemoteAddr = "10.5.0.1:12345"
+	req.Header.Set("Authorization", "Bearer abc123")
+	req.Header.Set("X-Tenant-ID", "tenant-a")
+	req.AddCookie(&http.Cookie{Name: "session", Value: "s1"})
+
+	tests := []struct {
+		name     string
+		keyFunc  KeyFunc
+		expected string
+		isToken  bool
+	}{
+		{"IP", KeyFromIP(), "10.5.0.1", false},
+		{"Bearer", KeyFromBearer(), "abc123", true},
+		{"Header", KeyFromHeader("X-Tenant-ID"), "tenant-a", false},
+		{"Query", KeyFromQuery("client"), "mobile", false},
+		{"Cookie", KeyFromCookie("session"), "s1", false},
+		{"Missing cookie", KeyFromCookie("other"), "", false},
+		{"Route without chi", KeyFromRoute(), "GET /orders/42", false},
+		{"Client cert without TLS", KeyFromClientCert(), "", false},
+		{"As token", AsToken(KeyFromHeader("X-Tenant-ID")), "tenant-a", true},
+		{"First key", FirstKey(KeyFromHeader("X-Missing"), KeyFromIP()), "10.5.0.1", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			identifier, isToken := tt.keyFunc(req)
+			assert.Equal(t, tt.expected, identifier)
+			assert.Equal(t, tt.isToken, isToken)
+		})
+	}
+
+	t.Run("Composite keeps the token part", func(t *testing.T) {
+		identity := CompositeKey(KeyFromHeader("X-Tenant-ID"), KeyFromBearer())(req)
+		assert.Equal(t, Identity{Identifier: "tenant-a|abc123", IsToken: true, Token: "abc123"}, identity)
+
+		identity = CompositeKey(KeyFromHeader("X-Missing"), KeyFromIP())(req)
+		assert.Equal(t, Identity{}, identity)
+	})
+
+	t.Run("Bearer requires scheme", func(t *testing.T) {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set("Authorization", "Basic dXNlcjpwYXNz")
+		identifier, _ := KeyFromBearer()(r)
+		assert.Empty(t, identifier)
+	})
+
+	t.Run("Verified client cert subject", func(t *testing.T) {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.TLS = &tls.ConnectionState{
+			VerifiedChains: [][]*x509.Certificate{{{Subject: pkix.Name{CommonName: "billing-service", Organization: []string{"acme"}}}}},
+		}
+		identifier, isToken := KeyFromClientCert()(r)
+		assert.Equal(t, "CN=billing-service,O=acme", identifier)
+		assert.True(t, isToken)
+
+		// Certificado apresentado mas não verificado é ignorado
+		r.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{{Subject: pkix.Name{CommonName: "forged"}}}}
+		identifier, _ = KeyFromClientCert()(r)
+		assert.Empty(t, identifier)
+	})
+}
+
+func TestParseKeyFunc(t *testing.T) {
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "10.5.0.2:12345"
+	req.Header.Set("X-Tenant-ID", "tenant-b")
+	req.Header.Set("API_KEY", "test_token")
+
+	identityFunc, err := ParseKeyFunc("header:X-Tenant-ID+api_key")
+	require.NoError(t, err)
+	identity := identityFunc(req)
+	assert.Equal(t, "tenant-b|test_token", identity.Identifier)
+	assert.True(t, identity.IsToken)
+	assert.Equal(t, "test_token", identity.Token)
+
+	identityFunc, err = ParseKeyFunc("ip")
+	require.NoError(t, err)
+	assert.Equal(t, "10.5.0.2", identityFunc(req).Identifier)
+
+	_, err = ParseKeyFunc("header")
+	assert.Error(t, err)
+
+	_, err = ParseKeyFunc("ip+unknown")
+	assert.Error(t, err)
+}
+
+func TestRateLimitMiddlewareKeyFunc(t *testing.T) {
+	mockStorage := NewMockStorageStrategy()
+	rateLimiter := limiter.NewRateLimiter(mockStorage, &config.RateLimitConfig{
+		IPLimit:              5,
+		WindowSeconds:        1,
+		BlockDurationSeconds: 300,
+	}, nil)
+
+	router := chi.NewRouter()
+	router.With(RateLimitMiddleware(rateLimiter, WithIdentityFunc(CompositeKey(KeyFromURLParam("tenant"), KeyFromRoute())))).
+		Get("/tenants/{tenant}/orders", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		})
+
+	req := httptest.NewRequest("GET", "/tenants/acme/orders", nil)
+	req.RemoteAddr = "10.5.0.3:12345"
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, 1, mockStorage.callCounts["ip:acme|GET /tenants/{tenant}/orders"])
+}
+
+func TestRateLimitMiddlewareCompositeToken(t *testing.T) {
+	mockStorage := NewMockStorageStrategy()
+	rateLimiter := limiter.NewRateLimiter(mockStorage, &config.RateLimitConfig{
+		IPLimit:              5,
+		WindowSeconds:        1,
+		BlockDurationSeconds: 300,
+	}, config.TokenConfigs{
+		"gold_token":         config.TokenConfig{Limit: 100, WindowSeconds: 1, BlockDurationSeconds: 60},
+		"disabled_token":     config.TokenConfig{Limit: 100, WindowSeconds: 1, Disabled: true},
+		"scoped_token":       config.TokenConfig{Limit: 100, WindowSeconds: 1, AllowedRoutes: []string{"/admin/*"}},
+		"CN=billing-service": config.TokenConfig{Limit: 50, WindowSeconds: 1, BlockDurationSeconds: 60},
+	})
+
+	identity, err := ParseKeyFunc("header:X-Tenant-ID+api_key")
+	require.NoError(t, err)
+	handler := RateLimitMiddleware(rateLimiter, WithIdentityFunc(identity))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	serve := func(token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/api/v1/resource", nil)
+		req.RemoteAddr = "10.5.0.4:12345"
+		req.Header.Set("X-Tenant-ID", "acme")
+		req.Header.Set("API_KEY", token)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	t.Run("Token limit applies to the composite key", func(t *testing.T) {
+		rr := serve("gold_token")
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, "100", rr.Header().Get("X-RateLimit-Limit"))
+		assert.Equal(t, 1, mockStorage.GetCallCount("token:acme|gold_token"))
+	})
+
+	t.Run("Disabled token is rejected", func(t *testing.T) {
+		assert.Equal(t, http.StatusUnauthorized, serve("disabled_token").Code)
+		assert.Equal(t, 0, mockStorage.GetCallCount("token:acme|disabled_token"))
+	})
+
+	t.Run("Out of scope token is rejected", func(t *testing.T) {
+		assert.Equal(t, http.StatusForbidden, serve("scoped_token").Code)
+	})
+
+	t.Run("Client cert subject uses its token limit", func(t *testing.T) {
+		certHandler := RateLimitMiddleware(rateLimiter, WithKeyFunc(KeyFromClientCert()))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}))
+
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = "10.5.0.5:12345"
+		req.TLS = &tls.ConnectionState{
+			VerifiedChains: [][]*x509.Certificate{{{Subject: pkix.Name{CommonName: "billing-service"}}}},
+		}
+		rr := httptest.NewRecorder()
+		certHandler.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, "50", rr.Header().Get("X-RateLimit-Limit"))
+	})
+}
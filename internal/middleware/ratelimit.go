@@ -5,10 +5,11 @@ import (
 	"log"
 	"net"
 	"net/http"
-	"strconv"
+	"net/netip"
 	"strings"
 	"time"
 
+	"fc-pos-golang-rate-limiter/internal/config"
 	"fc-pos-golang-rate-limiter/internal/limiter"
 	"fc-pos-golang-rate-limiter/pkg/response"
 )
@@ -20,8 +21,168 @@ const (
 	rateLimitInfoKey contextKey = "rate_limit_info"
 )
 
+// Configura o comportamento opcional do middleware
+type Option func(*options)
+
+type options struct {
+	headers       config.HeaderConfig
+	errorResponse response.RateLimitErrorOptions
+	rule          string
+	identity      IdentityFunc
+	onLimited     OnLimitedFunc
+	routeRules    config.RouteRules
+	allow         []netip.Prefix
+	deny          []netip.Prefix
+	trusted       []netip.Prefix
+	concurrency   int
+	maxWait       time.Duration
+	maxWaiters    int
+	// Vagas de espera ocupadas pelas requisições em WithWait
+	waiters chan struct{}
+}
+
+// Quantidade padrão de requisições esperando ao mesmo tempo em WithWait
+const DefaultMaxWaiters = 1000
+
+// Extrai o identificador do cliente da requisição; isToken indica se os limites
+// de token devem ser aplicados. Um identificador vazio faz o middleware usar o IP.
+type KeyFunc func(r *http.Request) (identifier string, isToken bool)
+
+// Identidade do cliente usada na verificação
+type Identity struct {
+	Identifier string
+	IsToken    bool
+	// Token é a parte de token de um Identifier composto (ex.: tenant|token),
+	// usada na busca no tokens.json e na validação; vazio usa o Identifier
+	Token string
+	// Plan e Limit ajustam os limites do cliente (ex.: a partir de claims do JWT)
+	Plan  string
+	Limit int
+	// JWT indica que Identifier veio de um claim de JWT verificado: tem chaves
+	// próprias e não é buscado no tokens.json
+	JWT bool
+}
+
+// Extrai a identidade completa do cliente. Um identificador vazio faz o
+// middleware usar o IP.
+type IdentityFunc func(r *http.Request) Identity
+
+// Trata uma requisição bloqueada no lugar da resposta 429 padrão. Os headers de
+// rate limit já foram escritos quando ela é chamada.
+type OnLimitedFunc func(w http.ResponseWriter, r *http.Request, result *limiter.CheckResult)
+
+// Define o modo e os nomes dos headers de rate limit
+func WithHeaders(cfg config.HeaderConfig) Option {
+	return func(o *options) {
+		o.headers = cfg
+	}
+}
+
+// Define o formato do corpo da resposta 429 (JSON, problem+json e templates por rota)
+func WithErrorResponse(opts response.RateLimitErrorOptions) Option {
+	return func(o *options) {
+		o.errorResponse = opts
+	}
+}
+
+// Aplica uma regra nomeada no lugar dos limites padrão de IP/token
+func WithRule(name string) Option {
+	return func(o *options) {
+		o.rule = name
+	}
+}
+
+// Define como o cliente é identificado (default: header API_KEY, senão IP)
+func WithKeyFunc(fn KeyFunc) Option {
+	return func(o *options) {
+		o.identity = IdentityFromKey(fn)
+	}
+}
+
+// Define como o cliente é identificado, incluindo plano e limite próprios
+func WithIdentityFunc(fn IdentityFunc) Option {
+	return func(o *options) {
+		o.identity = fn
+	}
+}
+
+// Substitui a resposta 429 padrão
+func WithOnLimited(fn OnLimitedFunc) Option {
+	return func(o *options) {
+		o.onLimited = fn
+	}
+}
+
+// Aplica a regra da primeira rota que casar com a requisição. WithRule tem prioridade.
+func WithRouteRules(routes config.RouteRules) Option {
+	return func(o *options) {
+		o.routeRules = routes
+	}
+}
+
+// IPs em Allow não passam pelo rate limit; IPs em Deny recebem 403
+func WithAccessList(list config.AccessList) Option {
+	return func(o *options) {
+		o.allow, o.deny = list.Prefixes()
+	}
+}
+
+// Define os proxies (IPs ou CIDRs) cujo X-Forwarded-For é aceito nas decisões
+// de segurança (allowed_cidrs dos tokens e listas de acesso). Sem proxies
+// confiáveis essas decisões usam apenas o endereço da conexão. Entradas
+// inválidas são ignoradas.
+func WithTrustedProxies(cidrs ...string) Option {
+	return func(o *options) {
+		o.trusted = nil
+		for _, cidr := range cidrs {
+			if prefixes, err := config.ParseCIDRs([]string{cidr}); err == nil {
+				o.trusted = append(o.trusted, prefixes...)
+			}
+		}
+	}
+}
+
+// Limita as requisições simultâneas por cliente (ou regra) a limit. O slot é
+// ocupado antes do handler e liberado ao final, inclusive em pânico. Exige
+// RateLimiter.SetConcurrency.
+func WithConcurrencyLimit(limit int) Option {
+	return func(o *options) {
+		o.concurrency = limit
+	}
+}
+
+// Em vez de responder 429 de imediato, segura a requisição até maxWait
+// esperando o reset do limite (respeitando o cancelamento do cliente).
+// maxWaiters limita as requisições esperando ao mesmo tempo; as excedentes
+// recebem 429. Com maxWaiters <= 0 usa DefaultMaxWaiters.
+func WithWait(maxWait time.Duration, maxWaiters int) Option {
+	return func(o *options) {
+		o.maxWait = maxWait
+		o.maxWaiters = maxWaiters
+	}
+}
+
+// Identificação padrão: token do header API_KEY tem prioridade sobre o IP
+func DefaultKeyFunc(r *http.Request) (string, bool) {
+	if apiKey := r.Header.Get("API_KEY"); apiKey != "" {
+		return apiKey, true
+	}
+	return extractIP(r), false
+}
+
 // Cria um middleware de rate limiting
-func RateLimitMiddleware(rateLimiter *limiter.RateLimiter) func(http.Handler) http.Handler {
+func RateLimitMiddleware(rateLimiter *limiter.RateLimiter, opts ...Option) func(http.Handler) http.Handler {
+	o := options{headers: config.DefaultHeaderConfig(), identity: IdentityFromKey(DefaultKeyFunc)}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.maxWait > 0 {
+		if o.maxWaiters <= 0 {
+			o.maxWaiters = DefaultMaxWaiters
+		}
+		o.waiters = make(chan struct{}, o.maxWaiters)
+	}
+
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			ctx := r.Context()
@@ -29,51 +190,250 @@ func RateLimitMiddleware(rateLimiter *limiter.RateLimiter) func(http.Handler) ht
 			// Extrai o endereço IP da requisição
 			ip := extractIP(r)
 
-			// Extrai a chave API do header da requisição
-			apiKey := r.Header.Get("API_KEY")
+			// Listas de acesso: deny tem prioridade sobre allow. Assim como no
+			// escopo dos tokens, o IP não pode vir de headers do próprio cliente.
+			allowListed := false
+			if len(o.deny) > 0 || len(o.allow) > 0 {
+				client := clientIP(r, o.trusted)
+				addr, err := netip.ParseAddr(client)
+				if err == nil && containsAddr(o.deny, addr) {
+					log.Printf("Request denied by access list | IP: %s | Path: %s", client, r.URL.Path)
+					response.WriteError(w, http.StatusForbidden, "IP not allowed")
+					return
+				}
+				allowListed = err == nil && containsAddr(o.allow, addr)
+			}
 
-			var identifier string
-			var isToken bool
+			rule := o.rule
+			if rule == "" {
+				rule, _ = o.routeRules.Match(r.Method, r.URL.Path)
+			}
 
-			// Prioridade: Token > IP (Token tem prioridade sobre IP)
-			if apiKey != "" {
-				identifier = apiKey
-				isToken = true
-			} else {
+			identity := o.identity(r)
+			identifier, isToken, token := identity.Identifier, identity.IsToken, identity.Token
+			if identifier == "" {
 				identifier = ip
 				isToken = false
+				token = ""
+				identity.JWT = false
+			}
+			if identity.JWT {
+				// O claim não é um token do tokens.json nem divide as chaves do API_KEY
+				isToken = false
+				token = ""
+			}
+			if isToken && token == "" {
+				token = identifier
 			}
 
-			// Verifica o limite de requisições
-			result, err := rateLimiter.Check(ctx, identifier, isToken)
-			if err != nil {
-				// Loga o erro mas permite que a requisição continue
-				log.Printf("Rate limiter error: %v | IP: %s | Identifier: %s | IsToken: %v",
-					err, ip, identifier, isToken)
-				next.ServeHTTP(w, r)
-				return
+			// Tokens conhecidos precisam estar válidos e dentro do escopo, mesmo
+			// vindos da allow list. O IP do escopo não pode vir de headers
+			// enviados pelo próprio cliente.
+			if isToken {
+				if status, err := authorizeToken(rateLimiter, token, r.URL.Path, clientIP(r, o.trusted), time.Now()); err != nil {
+					log.Printf("Token rejected: %v | IP: %s | Path: %s", err, ip, r.URL.Path)
+					response.WriteError(w, status, err.Error())
+					return
+				}
 			}
 
-			// Adiciona headers de rate limit
-			w.Header().Set("X-RateLimit-Limit", strconv.Itoa(result.Limit))
-			w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(result.Remaining))
-			w.Header().Set("X-RateLimit-Reset", result.ResetTime.Format(time.RFC3339))
+			// IPs da allow list não passam pelo rate limit, mas continuam
+			// sujeitos ao limite de concorrência
+			var result *limiter.CheckResult
+			if !allowListed {
+				var ok bool
+				if result, ok = checkRateLimit(w, r, rateLimiter, o, rateLimitRequest{
+					ip: ip, identifier: identifier, isToken: isToken, token: token, rule: rule, identity: identity,
+				}); !ok {
+					return
+				}
+			}
 
-			// Verifica se a requisição é permitida
-			if !result.Allowed {
-				response.WriteRateLimitError(w, result.Remaining, result.ResetTime)
-				return
+			// O limite de concorrência vale mesmo em dry-run e com falha do rate limit
+			if o.concurrency > 0 {
+				slot, err := rateLimiter.AcquireSlot(ctx, limiter.CheckRequest{
+					Identifier: identifier,
+					IsToken:    isToken,
+					Rule:       rule,
+					JWT:        identity.JWT,
+				}, o.concurrency)
+				switch {
+				case err != nil:
+					// Assim como no rate limit, falhas do storage não bloqueiam
+					log.Printf("Concurrency limiter error: %v | IP: %s | Identifier: %s | IsToken: %v",
+						err, ip, limiter.SafeIdentifier(identifier, isToken), isToken)
+				case !slot.Acquired:
+					rejected := &limiter.CheckResult{
+						Limit:       o.concurrency,
+						Window:      time.Second,
+						ResetTime:   time.Now().Add(time.Second),
+						Identifier:  identifier,
+						IsToken:     isToken,
+						Rule:        rule,
+						Concurrency: true,
+					}
+					if result != nil {
+						rejected.Plan = result.Plan
+					}
+					// Substitui os headers do rate limit, que indicariam saldo disponível
+					SetRateLimitHeaders(w.Header(), o.headers, rejected, time.Now())
+					reject(w, r, o, rejected)
+					return
+				default:
+					// O defer libera o slot mesmo se next entrar em pânico; o
+					// contexto da requisição pode já estar cancelado
+					defer slot.Release(context.WithoutCancel(ctx))
+				}
 			}
 
 			// Adiciona informações de rate limit ao contexto para potencial uso por handlers
-			ctx = context.WithValue(ctx, rateLimitInfoKey, result)
-			r = r.WithContext(ctx)
+			if result != nil {
+				r = r.WithContext(context.WithValue(ctx, rateLimitInfoKey, result))
+			}
 
 			next.ServeHTTP(w, r)
 		})
 	}
 }
 
+// Cliente identificado da requisição
+type rateLimitRequest struct {
+	ip         string
+	identifier string
+	isToken    bool
+	token      string
+	rule       string
+	identity   Identity
+}
+
+// Aplica o rate limit ao cliente já autorizado. Retorna false quando a
+// requisição já foi respondida; o resultado é nil se o limiter falhou (fail open).
+func checkRateLimit(w http.ResponseWriter, r *http.Request, rateLimiter *limiter.RateLimiter, o options, req rateLimitRequest) (*limiter.CheckResult, bool) {
+	ctx := r.Context()
+	ip, identifier, isToken := req.ip, req.identifier, req.isToken
+
+	// Verifica o limite de requisições
+	checkReq := limiter.CheckRequest{
+		Identifier: identifier,
+		IsToken:    isToken,
+		Token:      req.token,
+		Rule:       req.rule,
+		Plan:       req.identity.Plan,
+		Limit:      req.identity.Limit,
+		JWT:        req.identity.JWT,
+	}
+	var result *limiter.CheckResult
+	var err error
+	if o.maxWait > 0 {
+		result, err = waitForReset(ctx, rateLimiter, checkReq, o)
+		if err != nil && ctx.Err() != nil {
+			// O cliente desistiu durante a espera: não há a quem responder
+			log.Printf("Request canceled while waiting for rate limit | IP: %s | Identifier: %s",
+				ip, limiter.SafeIdentifier(identifier, isToken))
+			return nil, false
+		}
+	} else {
+		result, err = rateLimiter.Evaluate(ctx, checkReq)
+	}
+	if err != nil {
+		// Loga o erro mas permite que a requisição continue
+		log.Printf("Rate limiter error: %v | IP: %s | Identifier: %s | IsToken: %v",
+			err, ip, limiter.SafeIdentifier(identifier, isToken), isToken)
+		return nil, true
+	}
+
+	// Regra em dry-run avaliada junto com o limite aplicado
+	if shadow := result.Shadow; shadow != nil && !shadow.Allowed {
+		log.Printf("Rate limit dry-run: would reject | IP: %s | Identifier: %s | IsToken: %v | Rule: %s | Limit: %d",
+			ip, limiter.SafeIdentifier(identifier, isToken), isToken, shadow.Rule, shadow.Limit)
+	}
+
+	// Em dry-run apenas registra a rejeição que teria acontecido e segue
+	if result.DryRun {
+		if !result.Allowed {
+			log.Printf("Rate limit dry-run: would reject | IP: %s | Identifier: %s | IsToken: %v | Rule: %s | Limit: %d",
+				ip, limiter.SafeIdentifier(identifier, isToken), isToken, result.Rule, result.Limit)
+		}
+		return result, true
+	}
+
+	// Adiciona headers de rate limit
+	SetRateLimitHeaders(w.Header(), o.headers, result, time.Now())
+
+	// Verifica se a requisição é permitida
+	if !result.Allowed {
+		reject(w, r, o, result)
+		return nil, false
+	}
+	return result, true
+}
+
+// Ocupa uma vaga de espera e aguarda o reset com Wait. Sem vaga, verifica sem
+// aplicar o bloqueio e nega de imediato, para não punir quem já espera pela
+// mesma chave.
+func waitForReset(ctx context.Context, rateLimiter *limiter.RateLimiter, req limiter.CheckRequest, o options) (*limiter.CheckResult, error) {
+	select {
+	case o.waiters <- struct{}{}:
+		defer func() { <-o.waiters }()
+		return rateLimiter.Wait(ctx, req, o.maxWait)
+	default:
+		reservation, err := rateLimiter.Reserve(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		return reservation.Result, nil
+	}
+}
+
+// Responde a requisição rejeitada com o OnLimited ou a resposta 429 padrão
+func reject(w http.ResponseWriter, r *http.Request, o options, result *limiter.CheckResult) {
+	if o.onLimited != nil {
+		o.onLimited(w, r, result)
+		return
+	}
+	response.WriteRateLimitResponse(w, r, response.RateLimitDetails{
+		Limit:     result.Limit,
+		Remaining: result.Remaining,
+		ResetTime: result.ResetTime,
+		Policy:    PolicyName(result),
+	}, o.errorResponse)
+}
+
+// Valida o token contra os metadados do tokens.json. Tokens desconhecidos são
+// aceitos (e limitados como IP); inválidos retornam 401 e fora do escopo 403.
+func authorizeToken(rateLimiter *limiter.RateLimiter, token, requestPath, ip string, now time.Time) (int, error) {
+	tokenConfig, exists := rateLimiter.TokenConfig(token)
+	if !exists {
+		return 0, nil
+	}
+
+	if err := tokenConfig.CheckValidity(now); err != nil {
+		return http.StatusUnauthorized, err
+	}
+	if err := tokenConfig.CheckScope(requestPath, ip); err != nil {
+		return http.StatusForbidden, err
+	}
+	return 0, nil
+}
+
+func containsAddr(prefixes []netip.Prefix, addr netip.Addr) bool {
+	addr = addr.Unmap()
+	for _, prefix := range prefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// Converte uma KeyFunc em IdentityFunc
+func IdentityFromKey(fn KeyFunc) IdentityFunc {
+	return func(r *http.Request) Identity {
+		identifier, isToken := fn(r)
+		return Identity{Identifier: identifier, IsToken: isToken}
+	}
+}
+
 // Extrai o endereço IP real da requisição, priorizando headers de proxy
 func extractIP(r *http.Request) string {
 	// Verifica o header X-Forwarded-For primeiro (para balanceadores de carga/proxies)
@@ -102,6 +462,45 @@ func extractIP(r *http.Request) string {
 	return ip
 }
 
+// Endereço do cliente para decisões de segurança. Usa o endereço da conexão
+// e, apenas quando ela vem de um proxy confiável, percorre o X-Forwarded-For
+// da direita para a esquerda até o primeiro endereço não confiável.
+func clientIP(r *http.Request, trusted []netip.Prefix) string {
+	remote, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		remote = r.RemoteAddr
+	}
+
+	addr, err := netip.ParseAddr(remote)
+	if err != nil || !containsAddr(trusted, addr) {
+		return remote
+	}
+
+	xff := r.Header.Get("X-Forwarded-For")
+	if xff == "" {
+		if xri := strings.TrimSpace(r.Header.Get("X-Real-IP")); xri != "" {
+			return xri
+		}
+		return remote
+	}
+
+	hops := strings.Split(xff, ",")
+	client := remote
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		hopAddr, err := netip.ParseAddr(hop)
+		if err != nil {
+			// Entrada inválida: fica com o último endereço conhecido
+			return client
+		}
+		client = hop
+		if !containsAddr(trusted, hopAddr) {
+			return client
+		}
+	}
+	return client
+}
+
 func GetRateLimitInfo(ctx context.Context) *limiter.CheckResult {
 	if info, ok := ctx.Value(rateLimitInfoKey).(*limiter.CheckResult); ok {
 		return info
@@ -2,16 +2,21 @@ package middleware
 
 import (
 	"context"
+	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"fc-pos-golang-rate-limiter/internal/config"
 	"fc-pos-golang-rate-limiter/internal/limiter"
+	"fc-pos-golang-rate-limiter/pkg/response"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 type MockStorageStrategy struct {
@@ -130,6 +135,7 @@ func TestRateLimitMiddleware(t *testing.T) {
 		assert.Contains(t, rr.Body.String(), "you have reached the maximum number of requests")
 		assert.Equal(t, "10", rr.Header().Get("X-RateLimit-Limit"))
 		assert.Equal(t, "0", rr.Header().Get("X-RateLimit-Remaining"))
+		assert.Equal(t, "1", rr.Header().Get("Retry-After"))
 	})
 
 	t.Run("Request allowed - Token", func(t *testing.T) {
@@ -212,6 +218,460 @@ func TestRateLimitMiddleware(t *testing.T) {
 	})
 }
 
+func TestRateLimitHeaderModes(t *testing.T) {
+	mockStorage := NewMockStorageStrategy()
+	ipConfig := &config.RateLimitConfig{
+		IPLimit:              10,
+		WindowSeconds:        60,
+		BlockDurationSeconds: 300,
+	}
+	rateLimiter := limiter.NewRateLimiter(mockStorage, ipConfig, nil)
+
+	testHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	serve := func(headers config.HeaderConfig, ip string) *httptest.ResponseRecorder {
+		router := chi.NewRouter()
+		router.Use(RateLimitMiddleware(rateLimiter, WithHeaders(headers)))
+		router.Get("/test", testHandler)
+
+		req := httptest.NewRequest("GET", "/test", nil)
+		req.RemoteAddr = ip + ":12345"
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	t.Run("Legacy headers with delta seconds", func(t *testing.T) {
+		mockStorage.SetAllowResult("ip:10.0.0.1", true, 4)
+		headers := config.DefaultHeaderConfig()
+		headers.ResetFormat = config.ResetFormatDelta
+
+		rr := serve(headers, "10.0.0.1")
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, "10", rr.Header().Get("X-RateLimit-Limit"))
+		assert.Equal(t, "6", rr.Header().Get("X-RateLimit-Remaining"))
+		assert.Equal(t, "60", rr.Header().Get("X-RateLimit-Reset"))
+		assert.Empty(t, rr.Header().Get("RateLimit"))
+	})
+
+	t.Run("Legacy headers with epoch and custom names", func(t *testing.T) {
+		mockStorage.SetAllowResult("ip:10.0.0.2", true, 0)
+		headers := config.DefaultHeaderConfig()
+		headers.ResetFormat = config.ResetFormatEpoch
+		headers.LimitHeader = "X-Gateway-Limit"
+		headers.RemainingHeader = "X-Gateway-Remaining"
+		headers.ResetHeader = "X-Gateway-Reset"
+
+		before := time.Now().Add(time.Minute).Unix()
+		rr := serve(headers, "10.0.0.2")
+
+		assert.Equal(t, "10", rr.Header().Get("X-Gateway-Limit"))
+		assert.Equal(t, "10", rr.Header().Get("X-Gateway-Remaining"))
+		reset, err := strconv.ParseInt(rr.Header().Get("X-Gateway-Reset"), 10, 64)
+		assert.NoError(t, err)
+		assert.GreaterOrEqual(t, reset, before)
+		assert.Empty(t, rr.Header().Get("X-RateLimit-Limit"))
+	})
+
+	t.Run("IETF headers", func(t *testing.T) {
+		mockStorage.SetAllowResult("ip:10.0.0.3", true, 3)
+		headers := config.DefaultHeaderConfig()
+		headers.Mode = config.HeaderModeIETF
+
+		rr := serve(headers, "10.0.0.3")
+
+		assert.Equal(t, `"ip";q=10;w=60`, rr.Header().Get("RateLimit-Policy"))
+		assert.Equal(t, `"ip";r=7;t=60`, rr.Header().Get("RateLimit"))
+		assert.Empty(t, rr.Header().Get("X-RateLimit-Limit"))
+	})
+
+	t.Run("Both modes and Retry-After on 429", func(t *testing.T) {
+		mockStorage.SetAllowResult("ip:10.0.0.4", false, 10)
+		headers := config.DefaultHeaderConfig()
+		headers.Mode = config.HeaderModeBoth
+
+		rr := serve(headers, "10.0.0.4")
+
+		assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+		assert.Equal(t, "60", rr.Header().Get("Retry-After"))
+		assert.Equal(t, "0", rr.Header().Get("X-RateLimit-Remaining"))
+		assert.Equal(t, `"ip";r=0;t=60`, rr.Header().Get("RateLimit"))
+	})
+}
+
+func TestRateLimitErrorResponse(t *testing.T) {
+	mockStorage := NewMockStorageStrategy()
+	ipConfig := &config.RateLimitConfig{
+		IPLimit:              10,
+		WindowSeconds:        30,
+		BlockDurationSeconds: 300,
+	}
+	rateLimiter := limiter.NewRateLimiter(mockStorage, ipConfig, nil)
+
+	templates, err := response.NewTemplates(map[string]response.TemplateSet{
+		"/custom/*": {
+			JSON: `{"code":"RATE_LIMITED","limit":{{.Limit}}}`,
+			Text: `slow down, retry in {{.RetryAfter}}s`,
+		},
+		response.DefaultTemplateRoute: {
+			HTML: `<p>retry in {{.RetryAfter}}s</p>`,
+		},
+	})
+	require.NoError(t, err)
+
+	serve := func(opts response.RateLimitErrorOptions, path, accept string) *httptest.ResponseRecorder {
+		router := chi.NewRouter()
+		router.Use(RateLimitMiddleware(rateLimiter, WithErrorResponse(opts)))
+		router.Get("/*", func(w http.ResponseWriter, r *http.Request) {})
+
+		req := httptest.NewRequest("GET", path, nil)
+		req.RemoteAddr = "10.1.0.1:12345"
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	mockStorage.SetAllowResult("ip:10.1.0.1", false, 10)
+
+	t.Run("Default JSON body", func(t *testing.T) {
+		rr := serve(response.RateLimitErrorOptions{}, "/test", "")
+
+		assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+		assert.Contains(t, rr.Header().Get("Content-Type"), "application/json")
+		assert.Contains(t, rr.Body.String(), "you have reached the maximum number of requests")
+	})
+
+	t.Run("Problem JSON", func(t *testing.T) {
+		opts := response.RateLimitErrorOptions{ProblemJSON: true, ProblemType: "https://example.com/probs/rate-limit"}
+		rr := serve(opts, "/test", "application/json")
+
+		assert.Contains(t, rr.Header().Get("Content-Type"), "application/problem+json")
+
+		var problem response.ProblemDetails
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &problem))
+		assert.Equal(t, "https://example.com/probs/rate-limit", problem.Type)
+		assert.Equal(t, "Too Many Requests", problem.Title)
+		assert.Equal(t, http.StatusTooManyRequests, problem.Status)
+		assert.Equal(t, 30, problem.RetryAfter)
+		assert.Equal(t, 10, problem.Limit)
+		assert.Equal(t, "ip", problem.Policy)
+		assert.Equal(t, "/test", problem.Instance)
+	})
+
+	t.Run("Explicit problem JSON accept", func(t *testing.T) {
+		rr := serve(response.RateLimitErrorOptions{}, "/test", "application/problem+json")
+
+		assert.Contains(t, rr.Header().Get("Content-Type"), "application/problem+json")
+		assert.Contains(t, rr.Body.String(), `"instance":"/test"`)
+	})
+
+	t.Run("Plain text", func(t *testing.T) {
+		rr := serve(response.RateLimitErrorOptions{}, "/test", "text/plain")
+
+		assert.Contains(t, rr.Header().Get("Content-Type"), "text/plain")
+		assert.Contains(t, rr.Body.String(), "Retry after 30 seconds")
+	})
+
+	t.Run("HTML for browsers", func(t *testing.T) {
+		rr := serve(response.RateLimitErrorOptions{}, "/test", "text/html,application/xhtml+xml,*/*;q=0.8")
+
+		assert.Contains(t, rr.Header().Get("Content-Type"), "text/html")
+		assert.Contains(t, rr.Body.String(), "<h1>Too Many Requests</h1>")
+	})
+
+	t.Run("Route template", func(t *testing.T) {
+		opts := response.RateLimitErrorOptions{Templates: templates}
+
+		rr := serve(opts, "/custom/resource", "application/json")
+		assert.Equal(t, `{"code":"RATE_LIMITED","limit":10}`, rr.Body.String())
+
+		rr = serve(opts, "/custom/resource", "text/plain")
+		assert.Equal(t, "slow down, retry in 30s", rr.Body.String())
+
+		rr = serve(opts, "/other", "application/json")
+		assert.Contains(t, rr.Body.String(), "you have reached the maximum number of requests")
+	})
+
+	t.Run("Falls back to default route template for missing format", func(t *testing.T) {
+		opts := response.RateLimitErrorOptions{Templates: templates}
+
+		rr := serve(opts, "/custom/resource", "text/html")
+		assert.Equal(t, "<p>retry in 30s</p>", rr.Body.String())
+
+		rr = serve(opts, "/other", "text/html")
+		assert.Equal(t, "<p>retry in 30s</p>", rr.Body.String())
+	})
+
+	t.Run("JSON templates escape values", func(t *testing.T) {
+		escaped, err := response.NewTemplates(map[string]response.TemplateSet{
+			"/quoted/*": {JSON: `{"instance":{{json .Instance}},"policy":{{json .Policy}}}`},
+			"/raw/*":    {JSON: `{"instance":"{{.Instance}}"}`},
+		})
+		require.NoError(t, err)
+		opts := response.RateLimitErrorOptions{Templates: escaped}
+
+		rr := serve(opts, `/quoted/a",%22admin%22:true,%22x`, "application/json")
+		var body map[string]interface{}
+		require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &body))
+		assert.Equal(t, `/quoted/a","admin":true,"x`, body["instance"])
+		assert.NotContains(t, body, "admin")
+
+		// Sem o helper o template geraria JSON inválido: usa o corpo padrão
+		rr = serve(opts, `/raw/a%22b`, "application/json")
+		assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+		assert.Contains(t, rr.Body.String(), "you have reached the maximum number of requests")
+	})
+}
+
+func TestRateLimitDryRun(t *testing.T) {
+	mockStorage := NewMockStorageStrategy()
+	ipConfig := &config.RateLimitConfig{
+		IPLimit:              10,
+		WindowSeconds:        1,
+		BlockDurationSeconds: 300,
+	}
+	rateLimiter := limiter.NewRateLimiter(mockStorage, ipConfig, nil)
+	rateLimiter.SetRules(config.RuleConfigs{
+		"tighter": config.RuleConfig{Limit: 1, WindowSeconds: 1, BlockDurationSeconds: 60, DryRun: true},
+	})
+
+	var info *limiter.CheckResult
+	router := chi.NewRouter()
+	router.Use(RateLimitMiddleware(rateLimiter, WithRule("tighter")))
+	router.Get("/test", func(w http.ResponseWriter, r *http.Request) {
+		info = GetRateLimitInfo(r.Context())
+		w.WriteHeader(http.StatusOK)
+	})
+
+	mockStorage.SetAllowResult("shadow:rule:tighter:ip:10.2.0.1", false, 1)
+
+	req := httptest.NewRequest("GET", "/test", nil)
+	req.RemoteAddr = "10.2.0.1:12345"
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+
+	// A regra em dry-run é só observada; o limite de IP continua aplicado
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "10", rr.Header().Get("X-RateLimit-Limit"))
+	require.NotNil(t, info)
+	assert.False(t, info.DryRun)
+	require.NotNil(t, info.Shadow)
+	assert.True(t, info.Shadow.DryRun)
+	assert.False(t, info.Shadow.Allowed)
+	assert.Equal(t, 1, mockStorage.GetCallCount("ip:10.2.0.1"))
+
+	mockStorage.SetAllowResult("ip:10.2.0.1", false, 10)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+}
+
+func TestRateLimitTokenMetadata(t *testing.T) {
+	past := time.Now().Add(-time.Hour)
+	future := time.Now().Add(time.Hour)
+
+	mockStorage := NewMockStorageStrategy()
+	rateLimiter := limiter.NewRateLimiter(mockStorage, &config.RateLimitConfig{
+		IPLimit:              10,
+		WindowSeconds:        1,
+		BlockDurationSeconds: 300,
+	}, config.TokenConfigs{
+		"active_token":   config.TokenConfig{Limit: 100, WindowSeconds: 1, ExpiresAt: &future},
+		"expired_token":  config.TokenConfig{Limit: 100, WindowSeconds: 1, ExpiresAt: &past},
+		"future_token":   config.TokenConfig{Limit: 100, WindowSeconds: 1, NotBefore: &future},
+		"disabled_token": config.TokenConfig{Limit: 100, WindowSeconds: 1, Disabled: true},
+		"scoped_token": config.TokenConfig{
+			Limit:         100,
+			WindowSeconds: 1,
+			AllowedRoutes: []string{"/api/v1/*"},
+			AllowedCIDRs:  []string{"10.7.0.0/16"},
+		},
+	})
+
+	handler := RateLimitMiddleware(rateLimiter)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	tests := []struct {
+		name           string
+		token          string
+		path           string
+		ip             string
+		expectedStatus int
+		expectedError  string
+	}{
+		{"Active token", "active_token", "/api/v1/resource", "10.7.0.1", http.StatusOK, ""},
+		{"Unknown token is limited as usual", "unknown_token", "/api/v1/resource", "10.7.0.1", http.StatusOK, ""},
+		{"Expired token", "expired_token", "/api/v1/resource", "10.7.0.1", http.StatusUnauthorized, "token expired"},
+		{"Token not yet valid", "future_token", "/api/v1/resource", "10.7.0.1", http.StatusUnauthorized, "token not yet valid"},
+		{"Disabled token", "disabled_token", "/api/v1/resource", "10.7.0.1", http.StatusUnauthorized, "token disabled"},
+		{"Scoped token within scope", "scoped_token", "/api/v1/resource", "10.7.3.4", http.StatusOK, ""},
+		{"Route outside scope", "scoped_token", "/admin/users", "10.7.3.4", http.StatusForbidden, "route not allowed for token"},
+		{"IP outside scope", "scoped_token", "/api/v1/resource", "192.168.0.1", http.StatusForbidden, "IP not allowed for token"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", tt.path, nil)
+			req.RemoteAddr = tt.ip + ":12345"
+			req.Header.Set("API_KEY", tt.token)
+			rr := httptest.NewRecorder()
+			callsBefore := mockStorage.GetCallCount("token:" + tt.token)
+
+			handler.ServeHTTP(rr, req)
+
+			assert.Equal(t, tt.expectedStatus, rr.Code)
+			if tt.expectedError != "" {
+				var body response.ErrorResponse
+				require.NoError(t, json.NewDecoder(rr.Body).Decode(&body))
+				assert.Equal(t, tt.expectedError, body.Message)
+				// Tokens rejeitados não consomem o limite
+				assert.Equal(t, callsBefore, mockStorage.GetCallCount("token:"+tt.token))
+			}
+		})
+	}
+
+	t.Run("Allow list does not skip token authorization", func(t *testing.T) {
+		handler := RateLimitMiddleware(rateLimiter, WithAccessList(config.AccessList{Allow: []string{"10.7.0.0/16"}}))(
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}))
+
+		req := httptest.NewRequest("GET", "/api/v1/resource", nil)
+		req.RemoteAddr = "10.7.0.1:12345"
+		req.Header.Set("API_KEY", "disabled_token")
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusUnauthorized, rr.Code)
+
+		// Tokens válidos da allow list continuam sem passar pelo rate limit
+		callsBefore := mockStorage.GetCallCount("token:active_token")
+		req.Header.Set("API_KEY", "active_token")
+		rr = httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, callsBefore, mockStorage.GetCallCount("token:active_token"))
+	})
+}
+
+func TestRateLimitTokenScopeClientIP(t *testing.T) {
+	rateLimiter := limiter.NewRateLimiter(NewMockStorageStrategy(), &config.RateLimitConfig{
+		IPLimit:              10,
+		WindowSeconds:        1,
+		BlockDurationSeconds: 300,
+	}, config.TokenConfigs{
+		"scoped_token": config.TokenConfig{Limit: 100, WindowSeconds: 1, AllowedCIDRs: []string{"10.0.0.0/8"}},
+	})
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	serve := func(handler http.Handler, remoteAddr, xff string) int {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = remoteAddr
+		req.Header.Set("API_KEY", "scoped_token")
+		req.Header.Set("X-Forwarded-For", xff)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr.Code
+	}
+
+	t.Run("Spoofed X-Forwarded-For is ignored", func(t *testing.T) {
+		handler := RateLimitMiddleware(rateLimiter)(ok)
+		assert.Equal(t, http.StatusForbidden, serve(handler, "198.51.100.7:12345", "10.0.0.1"))
+	})
+
+	t.Run("X-Forwarded-For from a trusted proxy", func(t *testing.T) {
+		handler := RateLimitMiddleware(rateLimiter, WithTrustedProxies("192.0.2.0/24"))(ok)
+
+		assert.Equal(t, http.StatusOK, serve(handler, "192.0.2.10:12345", "10.0.0.1"))
+		// O cliente pode prefixar o header; vale o endereço anotado pelo proxy
+		assert.Equal(t, http.StatusForbidden, serve(handler, "192.0.2.10:12345", "10.0.0.1, 198.51.100.7"))
+		// Conexões diretas continuam sem confiar no header
+		assert.Equal(t, http.StatusForbidden, serve(handler, "198.51.100.7:12345", "10.0.0.1"))
+	})
+}
+
+func TestRateLimitRouteRulesAndAccessList(t *testing.T) {
+	mockStorage := NewMockStorageStrategy()
+	rateLimiter := limiter.NewRateLimiter(mockStorage, &config.RateLimitConfig{
+		IPLimit:              10,
+		WindowSeconds:        1,
+		BlockDurationSeconds: 300,
+	}, nil)
+	rateLimiter.SetRules(config.RuleConfigs{
+		"login": config.RuleConfig{Limit: 5, WindowSeconds: 60, BlockDurationSeconds: 600},
+	})
+
+	handler := RateLimitMiddleware(rateLimiter,
+		WithRouteRules(config.RouteRules{{Path: "/login", Method: "POST", Rule: "login"}}),
+		WithAccessList(config.AccessList{
+			Allow: []string{"10.0.0.0/8"},
+			Deny:  []string{"10.9.0.0/16", "203.0.113.0/24"},
+		}),
+	)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	serve := func(method, path, remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.RemoteAddr = remoteAddr
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	t.Run("Route rule", func(t *testing.T) {
+		rr := serve("POST", "/login", "192.168.7.1:12345")
+		assert.Equal(t, "5", rr.Header().Get("X-RateLimit-Limit"))
+		assert.Equal(t, 1, mockStorage.GetCallCount("rule:login:ip:192.168.7.1"))
+
+		rr = serve("GET", "/login", "192.168.7.1:12345")
+		assert.Equal(t, "10", rr.Header().Get("X-RateLimit-Limit"))
+	})
+
+	t.Run("Allowed IP skips rate limit", func(t *testing.T) {
+		rr := serve("GET", "/", "10.1.0.1:12345")
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Empty(t, rr.Header().Get("X-RateLimit-Limit"))
+		assert.Equal(t, 0, mockStorage.GetCallCount("ip:10.1.0.1"))
+	})
+
+	t.Run("Deny has priority over allow", func(t *testing.T) {
+		rr := serve("GET", "/", "10.9.0.1:12345")
+		assert.Equal(t, http.StatusForbidden, rr.Code)
+
+		rr = serve("GET", "/", "203.0.113.5:12345")
+		assert.Equal(t, http.StatusForbidden, rr.Code)
+		assert.Equal(t, 0, mockStorage.GetCallCount("ip:203.0.113.5"))
+	})
+
+	t.Run("Spoofed X-Forwarded-For does not change the decision", func(t *testing.T) {
+		// Cliente bloqueado se passando por um IP da allow list
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = "203.0.113.5:12345"
+		req.Header.Set("X-Forwarded-For", "10.1.0.1")
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusForbidden, rr.Code)
+
+		// Cliente comum tentando pular o rate limit
+		req = httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = "192.168.7.2:12345"
+		req.Header.Set("X-Forwarded-For", "10.1.0.1")
+		rr = httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, "10", rr.Header().Get("X-RateLimit-Limit"))
+	})
+}
+
 func TestExtractIP(t *testing.T) {
 	tests := []struct {
 		name       string
@@ -279,3 +739,193 @@ func TestExtractIP(t *testing.T) {
 	}
 }
 
+
+func TestRateLimitConcurrency(t *testing.T) {
+	ipConfig := &config.RateLimitConfig{
+		IPLimit:              100,
+		WindowSeconds:        1,
+		BlockDurationSeconds: 300,
+	}
+	rateLimiter := limiter.NewRateLimiter(NewMockStorageStrategy(), ipConfig, nil)
+	slots := limiter.NewMemoryConcurrencyStrategy()
+	rateLimiter.SetConcurrency(slots, time.Minute)
+
+	entered := make(chan struct{})
+	release := make(chan struct{})
+	router := chi.NewRouter()
+	router.Use(RateLimitMiddleware(rateLimiter, WithConcurrencyLimit(1)))
+	router.Get("/slow", func(w http.ResponseWriter, r *http.Request) {
+		entered <- struct{}{}
+		<-release
+		w.WriteHeader(http.StatusOK)
+	})
+	router.Get("/fast", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	router.Get("/panic", func(w http.ResponseWriter, r *http.Request) {
+		panic("handler failure")
+	})
+
+	request := func(path, ip string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		req.RemoteAddr = ip + ":12345"
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	done := make(chan int)
+	go func() {
+		done <- request("/slow", "10.3.0.1").Code
+	}()
+	<-entered
+
+	// O único slot do IP está ocupado; outros IPs não são afetados
+	rr := request("/fast", "10.3.0.1")
+	assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+	assert.Equal(t, "1", rr.Header().Get("Retry-After"))
+	// Os headers descrevem o limite de concorrência, não o saldo do rate limit
+	assert.Equal(t, "1", rr.Header().Get("X-RateLimit-Limit"))
+	assert.Equal(t, "0", rr.Header().Get("X-RateLimit-Remaining"))
+	assert.Equal(t, http.StatusOK, request("/fast", "10.3.0.2").Code)
+
+	close(release)
+	assert.Equal(t, http.StatusOK, <-done)
+	assert.Equal(t, 0, slots.InFlight("inflight:ip:10.3.0.1"))
+	assert.Equal(t, http.StatusOK, request("/fast", "10.3.0.1").Code)
+
+	// O slot é liberado mesmo quando o handler entra em pânico
+	assert.Panics(t, func() { request("/panic", "10.3.0.1") })
+	assert.Equal(t, 0, slots.InFlight("inflight:ip:10.3.0.1"))
+	assert.Equal(t, http.StatusOK, request("/fast", "10.3.0.1").Code)
+
+	t.Run("OnLimited receives the concurrency result", func(t *testing.T) {
+		var limited *limiter.CheckResult
+		handler := RateLimitMiddleware(rateLimiter, WithConcurrencyLimit(1), WithOnLimited(func(w http.ResponseWriter, r *http.Request, result *limiter.CheckResult) {
+			limited = result
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+		slot, err := rateLimiter.AcquireSlot(context.Background(), limiter.CheckRequest{Identifier: "10.3.0.9"}, 1)
+		require.NoError(t, err)
+		defer slot.Release(context.Background())
+
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = "10.3.0.9:12345"
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusServiceUnavailable, rr.Code)
+		require.NotNil(t, limited)
+		assert.True(t, limited.Concurrency)
+		assert.Equal(t, 1, limited.Limit)
+		assert.Equal(t, "concurrency", PolicyName(limited))
+	})
+
+	t.Run("Applies in dry-run and to allow-listed IPs", func(t *testing.T) {
+		dryRunLimiter := limiter.NewRateLimiter(NewMockStorageStrategy(), &config.RateLimitConfig{
+			IPLimit:       100,
+			WindowSeconds: 1,
+			DryRun:        true,
+		}, nil)
+		dryRunLimiter.SetConcurrency(limiter.NewMemoryConcurrencyStrategy(), time.Minute)
+
+		for name, rl := range map[string]*limiter.RateLimiter{"dry-run": dryRunLimiter, "allow list": rateLimiter} {
+			handler := RateLimitMiddleware(rl, WithConcurrencyLimit(1), WithAccessList(config.AccessList{Allow: []string{"10.4.0.0/16"}}))(
+				http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) }))
+
+			slot, err := rl.AcquireSlot(context.Background(), limiter.CheckRequest{Identifier: "10.4.0.1"}, 1)
+			require.NoError(t, err)
+
+			req := httptest.NewRequest("GET", "/", nil)
+			req.RemoteAddr = "10.4.0.1:12345"
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+			assert.Equal(t, http.StatusTooManyRequests, rr.Code, name)
+			assert.Equal(t, "0", rr.Header().Get("X-RateLimit-Remaining"), name)
+
+			require.NoError(t, slot.Release(context.Background()))
+			rr = httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+			assert.Equal(t, http.StatusOK, rr.Code, name)
+		}
+	})
+
+	t.Run("Without strategy fails open", func(t *testing.T) {
+		handler := RateLimitMiddleware(limiter.NewRateLimiter(NewMockStorageStrategy(), ipConfig, nil), WithConcurrencyLimit(1))(
+			http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) }))
+
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest("GET", "/", nil))
+		assert.Equal(t, http.StatusOK, rr.Code)
+	})
+}
+
+func TestRateLimitWait(t *testing.T) {
+	storage, err := limiter.NewBoltStrategy(t.TempDir() + "/wait.db")
+	require.NoError(t, err)
+	defer storage.Close()
+
+	// Com o bloqueio padrão a espera ainda acontece: só quem desiste é bloqueado
+	rateLimiter := limiter.NewRateLimiter(storage, &config.RateLimitConfig{IPLimit: 1, WindowSeconds: 1, BlockDurationSeconds: 300}, nil)
+
+	var served atomic.Int32
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		served.Add(1)
+		w.WriteHeader(http.StatusOK)
+	})
+	waiting := RateLimitMiddleware(rateLimiter, WithWait(2*time.Second, 1))(handler)
+	impatient := RateLimitMiddleware(rateLimiter, WithWait(100*time.Millisecond, 1))(handler)
+
+	requestFrom := func(h http.Handler, ctx context.Context, ip string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/batch", nil).WithContext(ctx)
+		req.RemoteAddr = ip + ":12345"
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		return rr
+	}
+	request := func(h http.Handler, ctx context.Context) *httptest.ResponseRecorder {
+		return requestFrom(h, ctx, "10.4.0.1")
+	}
+
+	assert.Equal(t, http.StatusOK, request(waiting, context.Background()).Code)
+
+	done := make(chan *httptest.ResponseRecorder)
+	start := time.Now()
+	go func() {
+		done <- request(waiting, context.Background())
+	}()
+
+	// A única vaga de espera está ocupada
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, http.StatusTooManyRequests, request(waiting, context.Background()).Code)
+
+	rr := <-done
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.GreaterOrEqual(t, time.Since(start), 500*time.Millisecond)
+	assert.Equal(t, int32(2), served.Load())
+
+	t.Run("Wait beyond the maximum rejects and blocks", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, requestFrom(impatient, context.Background(), "10.4.0.2").Code)
+
+		start := time.Now()
+		rr := requestFrom(impatient, context.Background(), "10.4.0.2")
+		assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+		assert.Less(t, time.Since(start), 50*time.Millisecond)
+
+		// O bloqueio aplicado passa do máximo de qualquer espera
+		start = time.Now()
+		assert.Equal(t, http.StatusTooManyRequests, requestFrom(waiting, context.Background(), "10.4.0.2").Code)
+		assert.Less(t, time.Since(start), 50*time.Millisecond)
+		assert.Equal(t, int32(3), served.Load())
+	})
+
+	t.Run("Client cancellation stops the wait", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		rr := request(waiting, ctx)
+		assert.Empty(t, rr.Body.String())
+		assert.Equal(t, int32(3), served.Load())
+	})
+}
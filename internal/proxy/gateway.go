@@ -0,0 +1,207 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"fc-pos-golang-rate-limiter/internal/config"
+	"fc-pos-golang-rate-limiter/internal/middleware"
+	"fc-pos-golang-rate-limiter/pkg/response"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// Upstream de uma rota do gateway, com estado de saúde
+type Upstream struct {
+	target     *url.URL
+	healthPath string
+	healthy    atomic.Bool
+	proxy      *httputil.ReverseProxy
+}
+
+type route struct {
+	cfg      config.RouteConfig
+	upstream *Upstream
+}
+
+// Cria o middleware de rate limit que aplica a regra nomeada (ex.: RuleMiddleware do Limiter)
+type RateLimitFunc func(rule string) func(http.Handler) http.Handler
+
+// Encaminha requisições permitidas pelo rate limiter para upstreams configurados
+type Gateway struct {
+	routes    []route
+	rateLimit RateLimitFunc
+	headers   config.HeaderConfig
+	client    *http.Client
+}
+
+// Cria o gateway a partir das rotas. rateLimit cria o middleware de cada rota;
+// headers define os headers de rate limit repassados aos upstreams.
+func NewGateway(routes []config.RouteConfig, rateLimit RateLimitFunc, headers config.HeaderConfig) (*Gateway, error) {
+	g := &Gateway{
+		rateLimit: rateLimit,
+		headers:   headers,
+		client:    &http.Client{Timeout: 5 * time.Second},
+	}
+
+	for _, cfg := range routes {
+		if cfg.Path == "" {
+			return nil, fmt.Errorf("route without path")
+		}
+		target, err := url.Parse(cfg.Upstream)
+		if err != nil || target.Scheme == "" || target.Host == "" {
+			return nil, fmt.Errorf("route %q: invalid upstream %q", cfg.Path, cfg.Upstream)
+		}
+
+		g.routes = append(g.routes, route{cfg: cfg, upstream: g.newUpstream(target, cfg)})
+	}
+
+	return g, nil
+}
+
+// Registra as rotas no router, cada uma com seu RateLimitMiddleware
+func (g *Gateway) Mount(router chi.Router) {
+	for _, rt := range g.routes {
+		router.With(g.rateLimit(rt.cfg.Rule)).Handle(rt.cfg.Path, rt.upstream)
+	}
+}
+
+// Executa health checks ativos nos upstreams com HealthPath até o contexto ser cancelado
+func (g *Gateway) StartHealthChecks(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			g.CheckHealth(ctx)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Verifica uma vez a saúde de todos os upstreams com HealthPath
+func (g *Gateway) CheckHealth(ctx context.Context) {
+	for _, rt := range g.routes {
+		if rt.upstream.healthPath == "" {
+			continue
+		}
+
+		healthy := g.probe(ctx, rt.upstream)
+		if previous := rt.upstream.healthy.Swap(healthy); previous != healthy {
+			log.Printf("Upstream %s healthy: %v", rt.upstream.target, healthy)
+		}
+	}
+}
+
+func (g *Gateway) probe(ctx context.Context, upstream *Upstream) bool {
+	healthURL := upstream.target.ResolveReference(&url.URL{Path: upstream.healthPath})
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, healthURL.String(), nil)
+	if err != nil {
+		return false
+	}
+
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return false
+	}
+	_ = resp.Body.Close()
+
+	return resp.StatusCode < http.StatusInternalServerError
+}
+
+func (g *Gateway) newUpstream(target *url.URL, cfg config.RouteConfig) *Upstream {
+	upstream := &Upstream{target: target, healthPath: cfg.HealthPath}
+	upstream.healthy.Store(true)
+
+	upstream.proxy = &httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			if cfg.StripPrefix != "" {
+				pr.Out.URL.Path = stripPrefix(pr.In.URL.Path, cfg.StripPrefix)
+				pr.Out.URL.RawPath = ""
+			}
+			pr.SetURL(target)
+			pr.SetXForwarded()
+
+			// Repassa ao upstream a decisão de rate limit, ignorando valores enviados pelo cliente
+			for _, name := range g.rateLimitHeaderNames() {
+				pr.Out.Header.Del(name)
+			}
+			if info := middleware.GetRateLimitInfo(pr.In.Context()); info != nil && !info.DryRun {
+				middleware.SetRateLimitHeaders(pr.Out.Header, g.headers, info, time.Now())
+			}
+		},
+		ModifyResponse: func(resp *http.Response) error {
+			// Os headers de rate limit do gateway prevalecem sobre os do upstream
+			for _, name := range g.rateLimitHeaderNames() {
+				resp.Header.Del(name)
+			}
+			return nil
+		},
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			log.Printf("Upstream %s error: %v", target, err)
+			if upstream.healthPath != "" && upstreamDown(r, err) {
+				// Marca como indisponível até o próximo health check bem-sucedido
+				upstream.healthy.Store(false)
+			}
+			response.WriteError(w, http.StatusBadGateway, "upstream request failed")
+		},
+	}
+
+	return upstream
+}
+
+// Remove o prefixo apenas em um limite de segmento: "/api" remove de "/api" e
+// "/api/x", mas não de "/apiary"
+func stripPrefix(requestPath, prefix string) string {
+	rest, ok := strings.CutPrefix(requestPath, prefix)
+	if !ok || (rest != "" && !strings.HasPrefix(rest, "/") && !strings.HasSuffix(prefix, "/")) {
+		return requestPath
+	}
+	return "/" + strings.TrimLeft(rest, "/")
+}
+
+// Apenas falhas de conexão ou transporte indicam upstream fora do ar; um
+// cliente que desiste da requisição não derruba a rota
+func upstreamDown(r *http.Request, err error) bool {
+	if errors.Is(err, context.Canceled) || r.Context().Err() != nil {
+		return false
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+func (g *Gateway) rateLimitHeaderNames() []string {
+	return []string{
+		g.headers.LimitHeader,
+		g.headers.RemainingHeader,
+		g.headers.ResetHeader,
+		g.headers.PolicyHeader,
+		g.headers.RateLimitHeader,
+	}
+}
+
+// Encaminha a requisição se o upstream estiver saudável
+func (u *Upstream) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !u.healthy.Load() {
+		response.WriteError(w, http.StatusServiceUnavailable, "upstream unavailable")
+		return
+	}
+	u.proxy.ServeHTTP(w, r)
+}
+
+// Indica se o upstream está saudável
+func (u *Upstream) Healthy() bool {
+	return u.healthy.Load()
+}
@@ -0,0 +1,192 @@
+package proxy
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"fc-pos-golang-rate-limiter/internal/config"
+	"fc-pos-golang-rate-limiter/internal/limiter"
+	"fc-pos-golang-rate-limiter/internal/limiter/limitertest"
+	"fc-pos-golang-rate-limiter/internal/middleware"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGateway(t *testing.T) {
+	var upstreamHealthy atomic.Bool
+	upstreamHealthy.Store(true)
+
+	var lastPath, lastLimitHeader string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/healthz" {
+			if !upstreamHealthy.Load() {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		lastPath = r.URL.Path
+		lastLimitHeader = r.Header.Get("X-RateLimit-Limit")
+		// O header do upstream não deve sobrescrever o do gateway
+		w.Header().Set("X-RateLimit-Limit", "999")
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte("upstream"))
+	}))
+	defer upstream.Close()
+
+	mockStorage := limitertest.NewStorage()
+	rateLimiter := limiter.NewRateLimiter(mockStorage, &config.RateLimitConfig{
+		IPLimit:              10,
+		WindowSeconds:        1,
+		BlockDurationSeconds: 300,
+	}, nil)
+	rateLimiter.SetRules(config.RuleConfigs{
+		"orders": config.RuleConfig{Limit: 2, WindowSeconds: 1, BlockDurationSeconds: 60},
+	})
+
+	gateway, err := NewGateway([]config.RouteConfig{
+		{Path: "/orders/*", Upstream: upstream.URL + "/v2", StripPrefix: "/orders", Rule: "orders", HealthPath: "/healthz"},
+		{Path: "/users/*", Upstream: upstream.URL},
+	}, ruleMiddleware(rateLimiter), config.DefaultHeaderConfig())
+	require.NoError(t, err)
+
+	router := chi.NewRouter()
+	gateway.Mount(router)
+
+	serve := func(path, ip string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		req.RemoteAddr = ip + ":12345"
+		req.Header.Set("X-RateLimit-Limit", "forged")
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	t.Run("Forwards with stripped prefix and rate limit headers", func(t *testing.T) {
+		rr := serve("/orders/123", "10.3.0.1")
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, "upstream", rr.Body.String())
+		assert.Equal(t, "/v2/123", lastPath)
+		assert.Equal(t, "2", lastLimitHeader)
+		assert.Equal(t, []string{"2"}, rr.Header().Values("X-RateLimit-Limit"))
+		assert.Equal(t, 1, mockStorage.Calls("rule:orders:ip:10.3.0.1"))
+	})
+
+	t.Run("Route without rule uses IP limit", func(t *testing.T) {
+		rr := serve("/users/42", "10.3.0.2")
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, "/users/42", lastPath)
+		assert.Equal(t, "10", rr.Header().Get("X-RateLimit-Limit"))
+	})
+
+	t.Run("Rate limited requests are not forwarded", func(t *testing.T) {
+		mockStorage.SetResult("rule:orders:ip:10.3.0.3", false)
+		lastPath = ""
+
+		rr := serve("/orders/1", "10.3.0.3")
+
+		assert.Equal(t, http.StatusTooManyRequests, rr.Code)
+		assert.Empty(t, lastPath)
+	})
+
+	t.Run("Unhealthy upstream returns 503", func(t *testing.T) {
+		upstreamHealthy.Store(false)
+		gateway.CheckHealth(context.Background())
+
+		rr := serve("/orders/1", "10.3.0.4")
+		assert.Equal(t, http.StatusServiceUnavailable, rr.Code)
+
+		upstreamHealthy.Store(true)
+		gateway.CheckHealth(context.Background())
+
+		rr = serve("/orders/1", "10.3.0.4")
+		assert.Equal(t, http.StatusOK, rr.Code)
+	})
+
+	t.Run("Upstream connection error returns 502", func(t *testing.T) {
+		down := httptest.NewServer(http.NotFoundHandler())
+		downURL := down.URL
+		down.Close()
+
+		gw, err := NewGateway([]config.RouteConfig{{Path: "/down/*", Upstream: downURL}}, ruleMiddleware(rateLimiter), config.DefaultHeaderConfig())
+		require.NoError(t, err)
+		r := chi.NewRouter()
+		gw.Mount(r)
+
+		req := httptest.NewRequest("GET", "/down/x", nil)
+		req.RemoteAddr = "10.3.0.5:12345"
+		rr := httptest.NewRecorder()
+		r.ServeHTTP(rr, req)
+
+		assert.Equal(t, http.StatusBadGateway, rr.Code)
+	})
+
+	t.Run("Client cancellation keeps the upstream healthy", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		req := httptest.NewRequest("GET", "/orders/1", nil).WithContext(ctx)
+		req.RemoteAddr = "10.3.0.6:12345"
+		router.ServeHTTP(httptest.NewRecorder(), req)
+
+		rr := serve("/orders/1", "10.3.0.6")
+		assert.Equal(t, http.StatusOK, rr.Code)
+	})
+
+	t.Run("Connection error marks the upstream down", func(t *testing.T) {
+		down := httptest.NewServer(http.NotFoundHandler())
+		downURL := down.URL
+		down.Close()
+
+		gw, err := NewGateway([]config.RouteConfig{{Path: "/down/*", Upstream: downURL, HealthPath: "/healthz"}}, ruleMiddleware(rateLimiter), config.DefaultHeaderConfig())
+		require.NoError(t, err)
+		r := chi.NewRouter()
+		gw.Mount(r)
+
+		for _, expected := range []int{http.StatusBadGateway, http.StatusServiceUnavailable} {
+			req := httptest.NewRequest("GET", "/down/x", nil)
+			req.RemoteAddr = "10.3.0.7:12345"
+			rr := httptest.NewRecorder()
+			r.ServeHTTP(rr, req)
+			assert.Equal(t, expected, rr.Code)
+		}
+	})
+
+	t.Run("Invalid upstream", func(t *testing.T) {
+		_, err := NewGateway([]config.RouteConfig{{Path: "/x/*", Upstream: "not a url"}}, ruleMiddleware(rateLimiter), config.DefaultHeaderConfig())
+		assert.Error(t, err)
+	})
+}
+
+func TestStripPrefix(t *testing.T) {
+	tests := []struct {
+		path, prefix, expected string
+	}{
+		{"/api", "/api", "/"},
+		{"/api/orders", "/api", "/orders"},
+		{"/api/orders", "/api/", "/orders"},
+		{"/apiary", "/api", "/apiary"},
+		{"/other", "/api", "/other"},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, stripPrefix(tt.path, tt.prefix), tt.path)
+	}
+}
+
+func ruleMiddleware(rateLimiter *limiter.RateLimiter) RateLimitFunc {
+	return func(rule string) func(http.Handler) http.Handler {
+		return middleware.RateLimitMiddleware(rateLimiter,
+			middleware.WithHeaders(config.DefaultHeaderConfig()),
+			middleware.WithRule(rule),
+		)
+	}
+}
@@ -0,0 +1,210 @@
+package rls
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"fc-pos-golang-rate-limiter/internal/config"
+	"fc-pos-golang-rate-limiter/internal/limiter"
+	"fc-pos-golang-rate-limiter/internal/middleware"
+	"fc-pos-golang-rate-limiter/pkg/response"
+
+	corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	ratelimitv3 "github.com/envoyproxy/go-control-plane/envoy/extensions/common/ratelimit/v3"
+	rlsv3 "github.com/envoyproxy/go-control-plane/envoy/service/ratelimit/v3"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// Implementa o envoy.service.ratelimit.v3.RateLimitService sobre o RateLimiter.
+//
+// Cada descriptor vira uma verificação: a entrada cfg.TokenKey (ou cfg.IPKey)
+// identifica o cliente, a entrada cfg.RuleKey seleciona a regra nomeada e
+// hits_addend é usado como custo. Descriptors sem entrada de identificação
+// usam o próprio descriptor como identificador.
+type Server struct {
+	rlsv3.UnimplementedRateLimitServiceServer
+
+	rateLimiter *limiter.RateLimiter
+	cfg         config.EnvoyConfig
+	headers     config.HeaderConfig
+}
+
+func NewServer(rateLimiter *limiter.RateLimiter, cfg config.EnvoyConfig, headers config.HeaderConfig) *Server {
+	return &Server{
+		rateLimiter: rateLimiter,
+		cfg:         cfg,
+		headers:     headers,
+	}
+}
+
+// Registra o serviço no servidor gRPC
+func (s *Server) Register(grpcServer *grpc.Server) {
+	rlsv3.RegisterRateLimitServiceServer(grpcServer, s)
+}
+
+func (s *Server) ShouldRateLimit(ctx context.Context, req *rlsv3.RateLimitRequest) (*rlsv3.RateLimitResponse, error) {
+	resp := &rlsv3.RateLimitResponse{OverallCode: rlsv3.RateLimitResponse_OK}
+
+	// Domínios de outros serviços não são limitados aqui
+	if s.cfg.Domain != "" && req.GetDomain() != s.cfg.Domain {
+		for range req.GetDescriptors() {
+			resp.Statuses = append(resp.Statuses, &rlsv3.RateLimitResponse_DescriptorStatus{Code: rlsv3.RateLimitResponse_OK})
+		}
+		return resp, nil
+	}
+
+	var mostRestrictive *limiter.CheckResult
+
+	for _, descriptor := range req.GetDescriptors() {
+		checkReq, err := s.toCheckRequest(descriptor)
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		checkReq.Cost = int(req.GetHitsAddend())
+
+		result, err := s.rateLimiter.Evaluate(ctx, checkReq)
+		if err != nil {
+			return nil, status.Errorf(codes.Unavailable, "rate limit check failed: %v", err)
+		}
+
+		descriptorStatus := &rlsv3.RateLimitResponse_DescriptorStatus{
+			Code:               rlsv3.RateLimitResponse_OK,
+			CurrentLimit:       toRateLimit(result),
+			LimitRemaining:     uint32(result.Remaining),
+			DurationUntilReset: durationpb.New(durationUntil(result.ResetTime, time.Now())),
+		}
+
+		// Em dry-run a rejeição é apenas registrada
+		if !result.Allowed && !result.DryRun {
+			descriptorStatus.Code = rlsv3.RateLimitResponse_OVER_LIMIT
+			resp.OverallCode = rlsv3.RateLimitResponse_OVER_LIMIT
+		}
+		resp.Statuses = append(resp.Statuses, descriptorStatus)
+
+		if !result.DryRun && (mostRestrictive == nil || isMoreRestrictive(result, mostRestrictive)) {
+			mostRestrictive = result
+		}
+	}
+
+	if mostRestrictive != nil {
+		resp.ResponseHeadersToAdd = s.responseHeaders(mostRestrictive, resp.OverallCode, time.Now())
+	}
+
+	return resp, nil
+}
+
+// Converte um descriptor do Envoy em uma verificação do RateLimiter
+func (s *Server) toCheckRequest(descriptor *ratelimitv3.RateLimitDescriptor) (limiter.CheckRequest, error) {
+	entries := descriptor.GetEntries()
+	if len(entries) == 0 {
+		return limiter.CheckRequest{}, fmt.Errorf("descriptor without entries")
+	}
+
+	var req limiter.CheckRequest
+	var token, ip string
+	parts := make([]string, 0, len(entries))
+
+	for _, entry := range entries {
+		switch entry.GetKey() {
+		case s.cfg.RuleKey:
+			req.Rule = entry.GetValue()
+			continue
+		case s.cfg.TokenKey:
+			token = entry.GetValue()
+		case s.cfg.IPKey:
+			ip = entry.GetValue()
+		}
+		parts = append(parts, entry.GetKey()+"="+entry.GetValue())
+	}
+
+	switch {
+	case token != "":
+		req.Identifier = token
+		req.IsToken = true
+	case ip != "":
+		req.Identifier = ip
+	default:
+		// Sem identificação explícita, o descriptor inteiro é a chave, fora
+		// do namespace de IPs
+		sort.Strings(parts)
+		req.Identifier = strings.Join(parts, "|")
+		req.Descriptor = true
+	}
+
+	if req.Identifier == "" {
+		return limiter.CheckRequest{}, fmt.Errorf("descriptor without identifying entries")
+	}
+
+	// Limite definido no próprio descriptor (override do Envoy), aceito apenas
+	// com AllowLimitOverride; a janela e o bloqueio continuam os configurados
+	if override := descriptor.GetLimit(); s.cfg.AllowLimitOverride && override != nil && override.GetRequestsPerUnit() > 0 {
+		req.Limit = int(override.GetRequestsPerUnit())
+	}
+
+	return req, nil
+}
+
+// Headers de rate limit que o Envoy deve adicionar à resposta
+func (s *Server) responseHeaders(result *limiter.CheckResult, code rlsv3.RateLimitResponse_Code, now time.Time) []*corev3.HeaderValue {
+	h := http.Header{}
+	middleware.SetRateLimitHeaders(h, s.headers, result, now)
+	if code == rlsv3.RateLimitResponse_OVER_LIMIT {
+		h.Set("Retry-After", strconv.Itoa(response.RetryAfterSeconds(result.ResetTime, now)))
+	}
+
+	names := make([]string, 0, len(h))
+	for name := range h {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	headers := make([]*corev3.HeaderValue, 0, len(names))
+	for _, name := range names {
+		headers = append(headers, &corev3.HeaderValue{Key: name, Value: h.Get(name)})
+	}
+	return headers
+}
+
+// Representa o limite no formato do Envoy, usando a unidade que corresponde à janela
+func toRateLimit(result *limiter.CheckResult) *rlsv3.RateLimitResponse_RateLimit {
+	unit := rlsv3.RateLimitResponse_RateLimit_UNKNOWN
+	switch result.Window {
+	case time.Second:
+		unit = rlsv3.RateLimitResponse_RateLimit_SECOND
+	case time.Minute:
+		unit = rlsv3.RateLimitResponse_RateLimit_MINUTE
+	case time.Hour:
+		unit = rlsv3.RateLimitResponse_RateLimit_HOUR
+	case 24 * time.Hour:
+		unit = rlsv3.RateLimitResponse_RateLimit_DAY
+	}
+
+	return &rlsv3.RateLimitResponse_RateLimit{
+		Name:            middleware.PolicyName(result),
+		RequestsPerUnit: uint32(result.Limit),
+		Unit:            unit,
+	}
+}
+
+// Bloqueios vencem a comparação; entre permitidos vence o menor saldo
+func isMoreRestrictive(candidate, current *limiter.CheckResult) bool {
+	if candidate.Allowed != current.Allowed {
+		return !candidate.Allowed
+	}
+	return candidate.Remaining < current.Remaining
+}
+
+func durationUntil(resetTime, now time.Time) time.Duration {
+	if d := resetTime.Sub(now); d > 0 {
+		return d
+	}
+	return 0
+}
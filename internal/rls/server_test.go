@@ -0,0 +1,222 @@
+package rls
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"fc-pos-golang-rate-limiter/internal/config"
+	"fc-pos-golang-rate-limiter/internal/limiter"
+	"fc-pos-golang-rate-limiter/internal/limiter/limitertest"
+
+	ratelimitv3 "github.com/envoyproxy/go-control-plane/envoy/extensions/common/ratelimit/v3"
+	rlsv3 "github.com/envoyproxy/go-control-plane/envoy/service/ratelimit/v3"
+	typev3 "github.com/envoyproxy/go-control-plane/envoy/type/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// Storage em memória que conta o custo consumido por chave
+func newTestClient(t *testing.T, storage limiter.StorageStrategy, envoyConfig config.EnvoyConfig) rlsv3.RateLimitServiceClient {
+	rateLimiter := limiter.NewRateLimiter(storage, &config.RateLimitConfig{
+		IPLimit:              3,
+		WindowSeconds:        1,
+		BlockDurationSeconds: 60,
+	}, config.TokenConfigs{
+		"test_token": config.TokenConfig{Limit: 10, WindowSeconds: 60, BlockDurationSeconds: 60},
+	})
+	rateLimiter.SetRules(config.RuleConfigs{
+		"login": config.RuleConfig{Limit: 1, WindowSeconds: 60, BlockDurationSeconds: 60},
+	})
+
+	listener := bufconn.Listen(1024 * 1024)
+	grpcServer := grpc.NewServer()
+	NewServer(rateLimiter, envoyConfig, config.DefaultHeaderConfig()).Register(grpcServer)
+	go func() { _ = grpcServer.Serve(listener) }()
+	t.Cleanup(grpcServer.Stop)
+
+	conn, err := grpc.DialContext(context.Background(), "bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+
+	return rlsv3.NewRateLimitServiceClient(conn)
+}
+
+func descriptor(entries ...string) *ratelimitv3.RateLimitDescriptor {
+	d := &ratelimitv3.RateLimitDescriptor{}
+	for i := 0; i+1 < len(entries); i += 2 {
+		d.Entries = append(d.Entries, &ratelimitv3.RateLimitDescriptor_Entry{Key: entries[i], Value: entries[i+1]})
+	}
+	return d
+}
+
+func defaultEnvoyConfig() config.EnvoyConfig {
+	return config.EnvoyConfig{
+		Domain:   "edge",
+		RuleKey:  "rule",
+		IPKey:    "remote_address",
+		TokenKey: "api_key",
+	}
+}
+
+func TestShouldRateLimit(t *testing.T) {
+	storage := limitertest.NewStorage()
+	client := newTestClient(t, storage, defaultEnvoyConfig())
+	ctx := context.Background()
+
+	t.Run("IP descriptor within limit", func(t *testing.T) {
+		resp, err := client.ShouldRateLimit(ctx, &rlsv3.RateLimitRequest{
+			Domain:      "edge",
+			Descriptors: []*ratelimitv3.RateLimitDescriptor{descriptor("remote_address", "10.0.0.1")},
+		})
+		require.NoError(t, err)
+
+		assert.Equal(t, rlsv3.RateLimitResponse_OK, resp.OverallCode)
+		require.Len(t, resp.Statuses, 1)
+		assert.Equal(t, uint32(2), resp.Statuses[0].LimitRemaining)
+		assert.Equal(t, uint32(3), resp.Statuses[0].CurrentLimit.RequestsPerUnit)
+		assert.Equal(t, rlsv3.RateLimitResponse_RateLimit_SECOND, resp.Statuses[0].CurrentLimit.Unit)
+		assert.Equal(t, 1, storage.Used("ip:10.0.0.1"))
+	})
+
+	t.Run("Token and rule descriptors", func(t *testing.T) {
+		resp, err := client.ShouldRateLimit(ctx, &rlsv3.RateLimitRequest{
+			Domain: "edge",
+			Descriptors: []*ratelimitv3.RateLimitDescriptor{
+				descriptor("api_key", "test_token"),
+				descriptor("rule", "login", "remote_address", "10.0.0.2"),
+			},
+			HitsAddend: 1,
+		})
+		require.NoError(t, err)
+
+		assert.Equal(t, rlsv3.RateLimitResponse_OK, resp.OverallCode)
+		require.Len(t, resp.Statuses, 2)
+		assert.Equal(t, uint32(9), resp.Statuses[0].LimitRemaining)
+		assert.Equal(t, rlsv3.RateLimitResponse_RateLimit_MINUTE, resp.Statuses[0].CurrentLimit.Unit)
+		assert.Equal(t, "login", resp.Statuses[1].CurrentLimit.Name)
+		assert.Equal(t, 1, storage.Used("rule:login:ip:10.0.0.2"))
+
+		headers := map[string]string{}
+		for _, h := range resp.ResponseHeadersToAdd {
+			headers[h.Key] = h.Value
+		}
+		assert.Equal(t, "1", headers["X-Ratelimit-Limit"])
+		assert.Equal(t, "0", headers["X-Ratelimit-Remaining"])
+	})
+
+	t.Run("Over limit", func(t *testing.T) {
+		resp, err := client.ShouldRateLimit(ctx, &rlsv3.RateLimitRequest{
+			Domain: "edge",
+			Descriptors: []*ratelimitv3.RateLimitDescriptor{
+				descriptor("remote_address", "10.0.0.3"),
+				descriptor("rule", "login", "remote_address", "10.0.0.2"),
+			},
+		})
+		require.NoError(t, err)
+
+		assert.Equal(t, rlsv3.RateLimitResponse_OVER_LIMIT, resp.OverallCode)
+		assert.Equal(t, rlsv3.RateLimitResponse_OK, resp.Statuses[0].Code)
+		assert.Equal(t, rlsv3.RateLimitResponse_OVER_LIMIT, resp.Statuses[1].Code)
+
+		var retryAfter string
+		for _, h := range resp.ResponseHeadersToAdd {
+			if h.Key == "Retry-After" {
+				retryAfter = h.Value
+			}
+		}
+		assert.Equal(t, "60", retryAfter)
+	})
+
+	t.Run("Hits addend as cost", func(t *testing.T) {
+		resp, err := client.ShouldRateLimit(ctx, &rlsv3.RateLimitRequest{
+			Domain:      "edge",
+			Descriptors: []*ratelimitv3.RateLimitDescriptor{descriptor("api_key", "test_token")},
+			HitsAddend:  5,
+		})
+		require.NoError(t, err)
+
+		assert.Equal(t, uint32(4), resp.Statuses[0].LimitRemaining)
+	})
+
+	t.Run("Descriptor without identity uses entries as key", func(t *testing.T) {
+		_, err := client.ShouldRateLimit(ctx, &rlsv3.RateLimitRequest{
+			Domain:      "edge",
+			Descriptors: []*ratelimitv3.RateLimitDescriptor{descriptor("path", "/login", "method", "POST")},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, 1, storage.Used("descriptor:method=POST|path=/login"))
+		assert.Zero(t, storage.Used("ip:method=POST|path=/login"))
+	})
+
+	t.Run("Descriptor limit override", func(t *testing.T) {
+		limited := descriptor("remote_address", "10.0.0.4")
+		limited.Limit = &ratelimitv3.RateLimitDescriptor_RateLimitOverride{
+			RequestsPerUnit: 1,
+			Unit:            typev3.RateLimitUnit_SECOND,
+		}
+
+		// Sem AllowLimitOverride o limit do descriptor é ignorado
+		resp, err := client.ShouldRateLimit(ctx, &rlsv3.RateLimitRequest{
+			Domain:      "edge",
+			Descriptors: []*ratelimitv3.RateLimitDescriptor{limited},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, uint32(3), resp.Statuses[0].CurrentLimit.RequestsPerUnit)
+
+		overrideConfig := defaultEnvoyConfig()
+		overrideConfig.AllowLimitOverride = true
+		client := newTestClient(t, limitertest.NewStorage(), overrideConfig)
+
+		resp, err = client.ShouldRateLimit(ctx, &rlsv3.RateLimitRequest{
+			Domain:      "edge",
+			Descriptors: []*ratelimitv3.RateLimitDescriptor{limited},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, rlsv3.RateLimitResponse_OK, resp.OverallCode)
+		assert.Equal(t, uint32(1), resp.Statuses[0].CurrentLimit.RequestsPerUnit)
+
+		resp, err = client.ShouldRateLimit(ctx, &rlsv3.RateLimitRequest{
+			Domain:      "edge",
+			Descriptors: []*ratelimitv3.RateLimitDescriptor{limited},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, rlsv3.RateLimitResponse_OVER_LIMIT, resp.OverallCode)
+	})
+
+	t.Run("Other domain is not limited", func(t *testing.T) {
+		resp, err := client.ShouldRateLimit(ctx, &rlsv3.RateLimitRequest{
+			Domain:      "internal",
+			Descriptors: []*ratelimitv3.RateLimitDescriptor{descriptor("remote_address", "10.0.0.9")},
+		})
+		require.NoError(t, err)
+
+		assert.Equal(t, rlsv3.RateLimitResponse_OK, resp.OverallCode)
+		assert.Equal(t, 0, storage.Used("ip:10.0.0.9"))
+	})
+
+	t.Run("Invalid descriptor and storage error", func(t *testing.T) {
+		_, err := client.ShouldRateLimit(ctx, &rlsv3.RateLimitRequest{
+			Domain:      "edge",
+			Descriptors: []*ratelimitv3.RateLimitDescriptor{{}},
+		})
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+		storage.SetError("ip:10.0.0.10", assert.AnError)
+		_, err = client.ShouldRateLimit(ctx, &rlsv3.RateLimitRequest{
+			Domain:      "edge",
+			Descriptors: []*ratelimitv3.RateLimitDescriptor{descriptor("remote_address", "10.0.0.10")},
+		})
+		assert.Equal(t, codes.Unavailable, status.Code(err))
+	})
+}
@@ -0,0 +1,490 @@
+// Package ratelimit é a API pública do rate limiter para outros serviços Go.
+//
+// Uso típico:
+//
+//	rl, err := ratelimit.New(
+//		ratelimit.WithStorage(ratelimit.NewRedisStrategy(redisClient)),
+//		ratelimit.WithLimits(ratelimit.Config{IPLimit: 10, WindowSeconds: 1, BlockDurationSeconds: 300}),
+//		ratelimit.WithRules(ratelimit.Rules{"login": {Limit: 5, WindowSeconds: 60, BlockDurationSeconds: 300}}),
+//	)
+//	http.Handle("/api/", rl.Middleware(apiHandler))
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"fc-pos-golang-rate-limiter/internal/config"
+	"fc-pos-golang-rate-limiter/internal/jwtauth"
+	"fc-pos-golang-rate-limiter/internal/limiter"
+	"fc-pos-golang-rate-limiter/internal/middleware"
+	"fc-pos-golang-rate-limiter/pkg/response"
+
+	"github.com/go-redis/redis/v8"
+)
+
+type (
+	// Armazenamento dos contadores (Redis ou implementação própria)
+	StorageStrategy = limiter.StorageStrategy
+	// Storage que suporta custo maior que 1 por requisição
+	CostStrategy  = limiter.CostStrategy
+	RedisStrategy = limiter.RedisStrategy
+	// Cache em memória dos bloqueios na frente de outro storage
+	BlockCacheStrategy    = limiter.BlockCacheStrategy
+	BlockCacheOption      = limiter.BlockCacheOption
+	BlockInvalidator      = limiter.BlockInvalidator
+	RedisBlockInvalidator = limiter.RedisBlockInvalidator
+	// Nós Redis independentes com rendezvous hashing
+	ShardedStrategy         = limiter.ShardedStrategy
+	ShardedBlockInvalidator = limiter.ShardedBlockInvalidator
+	ShardedOption           = limiter.ShardedOption
+	Shard                   = limiter.Shard
+	ShardStorage            = limiter.ShardStorage
+	// Modo aproximado com saldo local e envio em lote
+	BatchStrategy = limiter.BatchStrategy
+	BatchStorage  = limiter.BatchStorage
+	BatchOption   = limiter.BatchOption
+	// Contadores em memória trocados entre as instâncias, sem Redis
+	GossipStrategy = limiter.GossipStrategy
+	GossipOption   = limiter.GossipOption
+	GossipMessage  = limiter.GossipMessage
+	// Storage persistente em arquivo bbolt, de um único nó
+	BoltStrategy = limiter.BoltStrategy
+	BoltOption   = limiter.BoltOption
+	// Limite de requisições simultâneas (slots com lease)
+	ConcurrencyStrategy       = limiter.ConcurrencyStrategy
+	RedisConcurrencyStrategy  = limiter.RedisConcurrencyStrategy
+	MemoryConcurrencyStrategy = limiter.MemoryConcurrencyStrategy
+	Slot                      = limiter.Slot
+	// Resultado de RateLimiter.Reserve, com a espera até o reset
+	Reservation = limiter.Reservation
+
+	RateLimiter  = limiter.RateLimiter
+	CheckRequest = limiter.CheckRequest
+	CheckResult  = limiter.CheckResult
+
+	TokenHasher = config.TokenHasher
+
+	KeyFunc       = middleware.KeyFunc
+	Identity      = middleware.Identity
+	IdentityFunc  = middleware.IdentityFunc
+	OnLimitedFunc = middleware.OnLimitedFunc
+
+	// Identificação por JWT (HS256/RS256)
+	JWTVerifier = jwtauth.Verifier
+
+	ErrorResponseOptions = response.RateLimitErrorOptions
+)
+
+var (
+	// Retornado por New quando nenhum storage é informado
+	ErrNoStorage   = errors.New("ratelimit: storage is required")
+	ErrUnknownRule = limiter.ErrUnknownRule
+	ErrInvalidCost = limiter.ErrInvalidCost
+)
+
+// Identificação padrão: header API_KEY, senão IP
+var DefaultKeyFunc KeyFunc = middleware.DefaultKeyFunc
+
+// Extractors de chave para WithKeyFunc
+var (
+	KeyFromIP         = middleware.KeyFromIP
+	KeyFromBearer     = middleware.KeyFromBearer
+	KeyFromHeader     = middleware.KeyFromHeader
+	KeyFromQuery      = middleware.KeyFromQuery
+	KeyFromCookie     = middleware.KeyFromCookie
+	KeyFromURLParam   = middleware.KeyFromURLParam
+	KeyFromRoute      = middleware.KeyFromRoute
+	KeyFromClientCert = middleware.KeyFromClientCert
+	AsToken           = middleware.AsToken
+	CompositeKey      = middleware.CompositeKey
+	FirstKey          = middleware.FirstKey
+	ParseKeyFunc      = middleware.ParseKeyFunc
+	IdentityFromKey   = middleware.IdentityFromKey
+)
+
+// Verificadores e identidade por JWT para WithIdentityFunc
+var (
+	NewHS256Verifier = jwtauth.NewHS256Verifier
+	NewRS256Verifier = jwtauth.NewRS256Verifier
+	NewJWKSVerifier  = jwtauth.NewJWKSVerifier
+	LoadRSAPublicKey = jwtauth.LoadRSAPublicKey
+	LoadJWKS         = jwtauth.LoadJWKS
+)
+
+// Identifica o cliente pelo JWT do header "Authorization: Bearer".
+// Requisições sem bearer token usam o fallback; tokens inválidos ou sem o
+// claim de chave são limitados pelo IP.
+func JWTIdentity(verifier *JWTVerifier, claims JWTClaims, fallback IdentityFunc) IdentityFunc {
+	return middleware.JWTIdentity(verifier, claims.internal(), fallback)
+}
+
+// Modos de hash dos tokens para NewTokenHasher
+const (
+	TokenHashNone   = config.TokenHashNone
+	TokenHashSHA256 = config.TokenHashSHA256
+	TokenHashHMAC   = config.TokenHashHMAC
+)
+
+// Carrega e valida um arquivo de política (.yaml, .yml ou .json)
+func LoadPolicy(path string) (*Policy, error) {
+	policy, err := config.LoadPolicy(path)
+	if err != nil {
+		return nil, err
+	}
+	return policyFrom(policy), nil
+}
+
+// Cria o hasher de tokens (none, sha256 ou hmac-sha256)
+func NewTokenHasher(mode, secret string) (TokenHasher, error) {
+	return config.NewTokenHasher(mode, secret)
+}
+
+// Limites padrão: 10 requisições por segundo por IP, bloqueio de 5 minutos
+func DefaultConfig() Config {
+	return Config{IPLimit: 10, WindowSeconds: 1, BlockDurationSeconds: 300}
+}
+
+// Configuração padrão dos headers de rate limit (X-RateLimit-*)
+func DefaultHeaderConfig() HeaderConfig {
+	return HeaderConfig(config.DefaultHeaderConfig())
+}
+
+// Cria o storage Redis com janela deslizante. Aceita *redis.Client,
+// *redis.ClusterClient ou o cliente de failover do Sentinel.
+func NewRedisStrategy(client redis.UniversalClient) *RedisStrategy {
+	return limiter.NewRedisStrategy(client)
+}
+
+// Envolve o storage com um cache em memória dos bloqueios: clientes bloqueados
+// são rejeitados sem acessar o storage até o reset do bloqueio
+func NewBlockCacheStrategy(storage StorageStrategy, opts ...BlockCacheOption) *BlockCacheStrategy {
+	return limiter.NewBlockCacheStrategy(storage, opts...)
+}
+
+// Propaga os resets do cache de bloqueios entre instâncias via pub/sub do Redis
+func NewRedisBlockInvalidator(client redis.UniversalClient, channel string) *RedisBlockInvalidator {
+	return limiter.NewRedisBlockInvalidator(client, channel)
+}
+
+// Opções de NewBlockCacheStrategy
+var (
+	WithBlockCacheMaxEntries = limiter.WithBlockCacheMaxEntries
+	WithBlockInvalidator     = limiter.WithBlockInvalidator
+)
+
+// Distribui as chaves entre os shards por rendezvous hashing, com failover
+// para o próximo nó quando um shard cai
+func NewShardedStrategy(shards []Shard, opts ...ShardedOption) *ShardedStrategy {
+	return limiter.NewShardedStrategy(shards, opts...)
+}
+
+// Propaga as invalidações do cache de bloqueios pelo pub/sub de todos os shards
+func NewShardedBlockInvalidator(sharded *ShardedStrategy, channel string) *ShardedBlockInvalidator {
+	return limiter.NewShardedBlockInvalidator(sharded, channel)
+}
+
+// Cria um shard por nó de cfg.Addrs ([nome=]host:porta), com as opções de
+// conexão de cfg
+func NewRedisShards(cfg RedisConfig) ([]Shard, error) {
+	return limiter.NewRedisShards(cfg.internal())
+}
+
+// Opções de NewShardedStrategy
+var WithShardHealthInterval = limiter.WithShardHealthInterval
+
+// Modo aproximado: concede localmente até o lease size por chave e envia o
+// consumo ao storage em lote, trocando um excesso limitado por menos operações
+func NewBatchStrategy(storage BatchStorage, opts ...BatchOption) *BatchStrategy {
+	return limiter.NewBatchStrategy(storage, opts...)
+}
+
+// Opções de NewBatchStrategy
+var (
+	WithBatchSyncInterval = limiter.WithBatchSyncInterval
+	WithBatchLeaseSize    = limiter.WithBatchLeaseSize
+)
+
+// Storage em memória que envia os deltas de cada chave aos peers via HTTP. O
+// próprio storage é o http.Handler que recebe os deltas em GossipPath.
+func NewGossipStrategy(opts ...GossipOption) *GossipStrategy {
+	return limiter.NewGossipStrategy(opts...)
+}
+
+// Opções de NewGossipStrategy
+var (
+	WithGossipNodeID   = limiter.WithGossipNodeID
+	WithGossipPeers    = limiter.WithGossipPeers
+	WithGossipInterval = limiter.WithGossipInterval
+	WithGossipSecret   = limiter.WithGossipSecret
+	WithGossipMaxBlock = limiter.WithGossipMaxBlock
+	WithGossipMaxKeys  = limiter.WithGossipMaxKeys
+)
+
+// Path do endpoint que recebe as mensagens dos peers
+const GossipPath = limiter.GossipPath
+
+// Abre (ou cria) o arquivo bbolt em path; os bloqueios sobrevivem a reinícios
+func NewBoltStrategy(path string, opts ...BoltOption) (*BoltStrategy, error) {
+	return limiter.NewBoltStrategy(path, opts...)
+}
+
+// Opções de NewBoltStrategy
+var (
+	WithBoltCompactInterval = limiter.WithBoltCompactInterval
+	WithBoltNoSync          = limiter.WithBoltNoSync
+)
+
+// Slots de concorrência no Redis, compartilhados entre as instâncias
+func NewRedisConcurrencyStrategy(client redis.UniversalClient) *RedisConcurrencyStrategy {
+	return limiter.NewRedisConcurrencyStrategy(client)
+}
+
+// Slots de concorrência em memória, para uma única instância
+func NewMemoryConcurrencyStrategy() *MemoryConcurrencyStrategy {
+	return limiter.NewMemoryConcurrencyStrategy()
+}
+
+// Cria o cliente Redis a partir da configuração (standalone, sentinel ou
+// cluster, com ACL, TLS e pool)
+func NewRedisClient(cfg RedisConfig) (redis.UniversalClient, error) {
+	return limiter.NewRedisClient(cfg.internal())
+}
+
+// Configura o Limiter criado por New
+type Option func(*settings)
+
+type settings struct {
+	storage    StorageStrategy
+	limits     Config
+	tokens     TokenConfigs
+	plans      Plans
+	rules      Rules
+	hasher     TokenHasher
+	middleware []middleware.Option
+
+	concurrency ConcurrencyStrategy
+	leaseTTL    time.Duration
+}
+
+// Define o storage dos contadores (obrigatório)
+func WithStorage(storage StorageStrategy) Option {
+	return func(s *settings) {
+		s.storage = storage
+	}
+}
+
+// Define os limites padrão por IP
+func WithLimits(limits Config) Option {
+	return func(s *settings) {
+		s.limits = limits
+	}
+}
+
+// Define os limites por token
+func WithTokens(tokens TokenConfigs) Option {
+	return func(s *settings) {
+		s.tokens = tokens
+	}
+}
+
+// Define os planos referenciados por CheckRequest.Plan (ex.: claim do JWT)
+func WithPlans(plans Plans) Option {
+	return func(s *settings) {
+		s.plans = plans
+	}
+}
+
+// Define as regras nomeadas disponíveis
+func WithRules(rules Rules) Option {
+	return func(s *settings) {
+		s.rules = rules
+	}
+}
+
+// Armazena os tokens pelo digest: as chaves de WithTokens e do storage passam a
+// ser o resultado do hasher
+func WithTokenHasher(hasher TokenHasher) Option {
+	return func(s *settings) {
+		s.hasher = hasher
+	}
+}
+
+// Define onde ficam os slots de concorrência e a validade de cada um
+// (0 usa 30s). Necessário para WithConcurrencyLimit.
+func WithConcurrency(strategy ConcurrencyStrategy, leaseTTL time.Duration) Option {
+	return func(s *settings) {
+		s.concurrency = strategy
+		s.leaseTTL = leaseTTL
+	}
+}
+
+// Limita as requisições simultâneas por cliente no middleware
+func WithConcurrencyLimit(limit int) Option {
+	return func(s *settings) {
+		s.middleware = append(s.middleware, middleware.WithConcurrencyLimit(limit))
+	}
+}
+
+// Segura as requisições limitadas até maxWait esperando o reset, com no
+// máximo maxWaiters esperando ao mesmo tempo (0 usa 1000)
+func WithWait(maxWait time.Duration, maxWaiters int) Option {
+	return func(s *settings) {
+		s.middleware = append(s.middleware, middleware.WithWait(maxWait, maxWaiters))
+	}
+}
+
+// Aplica a regra da primeira rota que casar com a requisição
+func WithRouteRules(routes RouteRules) Option {
+	return func(s *settings) {
+		s.middleware = append(s.middleware, middleware.WithRouteRules(routes.internal()))
+	}
+}
+
+// Define os proxies cujo X-Forwarded-For é aceito no escopo dos tokens e nas listas de acesso
+func WithTrustedProxies(cidrs ...string) Option {
+	return func(s *settings) {
+		s.middleware = append(s.middleware, middleware.WithTrustedProxies(cidrs...))
+	}
+}
+
+// Define os IPs isentos do rate limit e os bloqueados (403)
+func WithAccessList(list AccessList) Option {
+	return func(s *settings) {
+		s.middleware = append(s.middleware, middleware.WithAccessList(list.internal()))
+	}
+}
+
+// Aplica as seções definidas na política: limites padrão, headers, regras,
+// rotas, planos, tokens e listas de acesso. Opções posteriores sobrescrevem.
+func WithPolicy(policy *Policy) Option {
+	return func(s *settings) {
+		if d := policy.Defaults; d != nil {
+			if d.Limit != 0 {
+				s.limits.IPLimit = d.Limit
+			}
+			if d.WindowSeconds != 0 {
+				s.limits.WindowSeconds = d.WindowSeconds
+			}
+			if d.BlockDurationSeconds != 0 {
+				s.limits.BlockDurationSeconds = d.BlockDurationSeconds
+			}
+			s.limits.DryRun = s.limits.DryRun || d.DryRun
+		}
+		if h := policy.Headers; h != nil {
+			headers := DefaultHeaderConfig()
+			if h.Mode != "" {
+				headers.Mode = h.Mode
+			}
+			if h.ResetFormat != "" {
+				headers.ResetFormat = h.ResetFormat
+			}
+			WithHeaders(headers)(s)
+		}
+		if policy.Rules != nil {
+			s.rules = policy.Rules
+		}
+		if policy.Tokens != nil || policy.Plans != nil {
+			s.tokens = policy.Tokens
+			s.plans = policy.Plans
+		}
+		if len(policy.Routes) > 0 {
+			WithRouteRules(policy.Routes)(s)
+		}
+		if policy.Access != nil {
+			WithAccessList(*policy.Access)(s)
+		}
+	}
+}
+
+// Define como o middleware identifica o cliente
+func WithKeyFunc(fn KeyFunc) Option {
+	return func(s *settings) {
+		s.middleware = append(s.middleware, middleware.WithKeyFunc(fn))
+	}
+}
+
+// Define como o middleware identifica o cliente, incluindo plano e limite próprios
+func WithIdentityFunc(fn IdentityFunc) Option {
+	return func(s *settings) {
+		s.middleware = append(s.middleware, middleware.WithIdentityFunc(fn))
+	}
+}
+
+// Substitui a resposta 429 padrão do middleware
+func WithOnLimited(fn OnLimitedFunc) Option {
+	return func(s *settings) {
+		s.middleware = append(s.middleware, middleware.WithOnLimited(fn))
+	}
+}
+
+// Define o modo e os nomes dos headers de rate limit do middleware
+func WithHeaders(cfg HeaderConfig) Option {
+	return func(s *settings) {
+		s.middleware = append(s.middleware, middleware.WithHeaders(cfg.internal()))
+	}
+}
+
+// Define o formato da resposta 429 padrão do middleware
+func WithErrorResponse(opts ErrorResponseOptions) Option {
+	return func(s *settings) {
+		s.middleware = append(s.middleware, middleware.WithErrorResponse(opts))
+	}
+}
+
+// Rate limiter com o middleware HTTP já configurado
+type Limiter struct {
+	*RateLimiter
+
+	storage    StorageStrategy
+	middleware []middleware.Option
+}
+
+// Cria um Limiter. WithStorage é obrigatório; sem WithLimits usa DefaultConfig.
+func New(opts ...Option) (*Limiter, error) {
+	s := settings{limits: DefaultConfig()}
+	for _, opt := range opts {
+		opt(&s)
+	}
+
+	if s.storage == nil {
+		return nil, ErrNoStorage
+	}
+
+	rateLimiter := limiter.NewRateLimiter(s.storage, s.limits.internal(), s.tokens.internal())
+	rateLimiter.SetRules(s.rules.internal())
+	rateLimiter.SetPlans(s.plans.internal())
+	rateLimiter.SetTokenHasher(s.hasher)
+	if s.concurrency != nil {
+		rateLimiter.SetConcurrency(s.concurrency, s.leaseTTL)
+	}
+
+	return &Limiter{
+		RateLimiter: rateLimiter,
+		storage:     s.storage,
+		middleware:  s.middleware,
+	}, nil
+}
+
+// Middleware net/http que aplica os limites padrão de IP/token
+func (l *Limiter) Middleware(next http.Handler) http.Handler {
+	return middleware.RateLimitMiddleware(l.RateLimiter, l.middleware...)(next)
+}
+
+// Middleware net/http que aplica a regra nomeada
+func (l *Limiter) RuleMiddleware(rule string) func(http.Handler) http.Handler {
+	opts := append([]middleware.Option{middleware.WithRule(rule)}, l.middleware...)
+	return middleware.RateLimitMiddleware(l.RateLimiter, opts...)
+}
+
+// Fecha o storage
+func (l *Limiter) Close() error {
+	return l.storage.Close()
+}
+
+// Resultado do rate limit armazenado no contexto pelo middleware
+func GetRateLimitInfo(ctx context.Context) *CheckResult {
+	return middleware.GetRateLimitInfo(ctx)
+}
@@ -0,0 +1,129 @@
+package ratelimit
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"fc-pos-golang-rate-limiter/internal/limiter/limitertest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNew(t *testing.T) {
+	t.Run("Storage is required", func(t *testing.T) {
+		_, err := New()
+		assert.ErrorIs(t, err, ErrNoStorage)
+	})
+
+	t.Run("Defaults and promoted methods", func(t *testing.T) {
+		storage := limitertest.NewStorage()
+		rl, err := New(WithStorage(storage))
+		require.NoError(t, err)
+
+		result, err := rl.Check(context.Background(), "192.168.1.1", false)
+		require.NoError(t, err)
+		assert.Equal(t, DefaultConfig().IPLimit, result.Limit)
+
+		_, err = rl.Evaluate(context.Background(), CheckRequest{Identifier: "x", Rule: "missing"})
+		assert.ErrorIs(t, err, ErrUnknownRule)
+
+		require.NoError(t, rl.Close())
+		assert.True(t, storage.Closed())
+	})
+}
+
+func TestMiddleware(t *testing.T) {
+	storage := limitertest.NewStorage()
+	var limited *CheckResult
+
+	rl, err := New(
+		WithStorage(storage),
+		WithLimits(Config{IPLimit: 5, WindowSeconds: 1, BlockDurationSeconds: 60}),
+		WithTokens(TokenConfigs{"test_token": TokenConfig{Limit: 50, WindowSeconds: 1, BlockDurationSeconds: 60}}),
+		WithRules(Rules{"login": RuleConfig{Limit: 2, WindowSeconds: 60, BlockDurationSeconds: 60}}),
+		WithKeyFunc(func(r *http.Request) (string, bool) {
+			return r.Header.Get("X-Client"), r.Header.Get("X-Client") == "test_token"
+		}),
+		WithOnLimited(func(w http.ResponseWriter, r *http.Request, result *CheckResult) {
+			limited = result
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}),
+	)
+	require.NoError(t, err)
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		info := GetRateLimitInfo(r.Context())
+		require.NotNil(t, info)
+		w.WriteHeader(http.StatusOK)
+	})
+
+	serve := func(h http.Handler, client string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = "10.4.0.1:12345"
+		if client != "" {
+			req.Header.Set("X-Client", client)
+		}
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+		return rr
+	}
+
+	t.Run("Custom key function", func(t *testing.T) {
+		rr := serve(rl.Middleware(handler), "test_token")
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, "50", rr.Header().Get("X-RateLimit-Limit"))
+		assert.Equal(t, 1, storage.Calls("token:test_token"))
+	})
+
+	t.Run("Empty key falls back to IP", func(t *testing.T) {
+		rr := serve(rl.Middleware(handler), "")
+
+		assert.Equal(t, http.StatusOK, rr.Code)
+		assert.Equal(t, 1, storage.Calls("ip:10.4.0.1"))
+	})
+
+	t.Run("Rule middleware and custom limited handler", func(t *testing.T) {
+		storage.SetResult("rule:login:ip:tenant-a", false)
+
+		rr := serve(rl.RuleMiddleware("login")(handler), "tenant-a")
+
+		assert.Equal(t, http.StatusServiceUnavailable, rr.Code)
+		require.NotNil(t, limited)
+		assert.Equal(t, "login", limited.Rule)
+		assert.Equal(t, "2", rr.Header().Get("X-RateLimit-Limit"))
+	})
+}
+
+func TestWithPolicy(t *testing.T) {
+	storage := limitertest.NewStorage()
+	rl, err := New(
+		WithStorage(storage),
+		WithPolicy(&Policy{
+			Version:  1,
+			Defaults: &PolicyDefaults{Limit: 3, WindowSeconds: 1},
+			Rules:    Rules{"login": {Limit: 2, WindowSeconds: 60, BlockDurationSeconds: 60}},
+			Routes:   RouteRules{{Path: "/login", Method: http.MethodPost, Rule: "login"}},
+			Access:   &AccessList{Deny: []string{"10.9.0.0/16"}},
+		}),
+	)
+	require.NoError(t, err)
+
+	handler := rl.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	serve := func(method, path, ip string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.RemoteAddr = ip + ":12345"
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	assert.Equal(t, "3", serve(http.MethodGet, "/", "10.4.0.1").Header().Get("X-RateLimit-Limit"))
+	assert.Equal(t, "2", serve(http.MethodPost, "/login", "10.4.0.1").Header().Get("X-RateLimit-Limit"))
+	assert.Equal(t, http.StatusForbidden, serve(http.MethodGet, "/", "10.9.0.1").Code)
+}
@@ -0,0 +1,285 @@
+package ratelimit
+
+import (
+	"time"
+
+	"fc-pos-golang-rate-limiter/internal/config"
+	"fc-pos-golang-rate-limiter/internal/middleware"
+)
+
+// Limites padrão por IP, modo dry-run global e namespace das chaves
+type Config struct {
+	IPLimit              int
+	WindowSeconds        int
+	BlockDurationSeconds int
+	DryRun               bool
+	// Key define como o cliente é identificado (ex.: "ip+route"); vazio usa API_KEY ou IP
+	Key string
+	// KeyPrefix e Tenant formam o namespace das chaves no storage
+	KeyPrefix string
+	Tenant    string
+	// Storage é redis, gossip ou bolt; informativo fora do servidor
+	Storage string
+	// TrustedProxies são os IPs/CIDRs dos proxies cujo X-Forwarded-For é aceito
+	TrustedProxies []string
+}
+
+// Limites e restrições de um token de API
+type TokenConfig struct {
+	// Plan referencia um plano de WithPlans; os campos diferentes de zero o sobrescrevem
+	Plan                 string
+	Limit                int
+	WindowSeconds        int
+	BlockDurationSeconds int
+
+	// Validade do token; nil significa sem restrição
+	ExpiresAt *time.Time
+	NotBefore *time.Time
+	Disabled  bool
+	// AllowedRoutes (globs de path.Match) e AllowedCIDRs restringem o uso do token
+	AllowedRoutes []string
+	AllowedCIDRs  []string
+	Owner         string
+	Description   string
+}
+
+type TokenConfigs map[string]TokenConfig
+
+// Conjunto nomeado de limites referenciado pelos tokens e pelo claim do JWT
+type PlanConfig struct {
+	Limit                int
+	WindowSeconds        int
+	BlockDurationSeconds int
+}
+
+type Plans map[string]PlanConfig
+
+// Regra nomeada aplicada com RuleMiddleware, WithRouteRules ou CheckRequest.Rule
+type RuleConfig struct {
+	Limit                int
+	WindowSeconds        int
+	BlockDurationSeconds int
+	// DryRun avalia a regra ao lado do limite aplicado, sem rejeitar
+	DryRun bool
+}
+
+type Rules map[string]RuleConfig
+
+// Modo e nomes dos headers de rate limit
+type HeaderConfig struct {
+	// Mode é legacy, ietf ou both; ResetFormat é rfc3339, delta ou epoch
+	Mode            string
+	ResetFormat     string
+	LimitHeader     string
+	RemainingHeader string
+	ResetHeader     string
+	PolicyHeader    string
+	RateLimitHeader string
+}
+
+// Conexão com o Redis (standalone, sentinel, cluster ou sharded)
+type RedisConfig struct {
+	Host     string
+	Port     string
+	Password string
+	DB       int
+	// Mode é standalone (Host/Port), sentinel, cluster ou sharded
+	Mode string
+	// Addrs são os sentinels, os nós semente do cluster ou os nós do modo
+	// sharded no formato [nome=]host:porta
+	Addrs            []string
+	MasterName       string
+	SentinelPassword string
+
+	// Username do ACL (Redis 6+); vazio usa o usuário default
+	Username string
+	// Socket conecta por unix socket no lugar de Host/Port
+	Socket string
+	TLS    RedisTLSConfig
+
+	// Pool e timeouts; zero usa o default do go-redis
+	PoolSize     int
+	MinIdleConns int
+	DialTimeout  time.Duration
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+	// MaxRetries -1 desabilita as novas tentativas
+	MaxRetries      int
+	MinRetryBackoff time.Duration
+	MaxRetryBackoff time.Duration
+
+	// Intervalo do health check dos nós no modo sharded
+	ShardHealthInterval time.Duration
+}
+
+// TLS da conexão com o Redis
+type RedisTLSConfig struct {
+	Enabled bool
+	CAFile  string
+	// CertFile e KeyFile habilitam o certificado de cliente (mTLS)
+	CertFile   string
+	KeyFile    string
+	ServerName string
+	// InsecureSkipVerify desabilita a verificação do certificado (apenas desenvolvimento)
+	InsecureSkipVerify bool
+}
+
+// Regra nomeada aplicada às requisições cujo path (glob de path.Match) e
+// método casam; Method vazio casa com qualquer método
+type RouteRule struct {
+	Path   string
+	Method string
+	Rule   string
+}
+
+type RouteRules []RouteRule
+
+// IPs/CIDRs isentos da contagem (Allow) e rejeitados com 403 (Deny)
+type AccessList struct {
+	Allow []string
+	Deny  []string
+}
+
+// Arquivo de política (YAML/JSON) e suas seções; seções nil não são aplicadas
+type Policy struct {
+	Version   int
+	Algorithm string
+	Defaults  *PolicyDefaults
+	Headers   *PolicyHeaders
+	Rules     Rules
+	Routes    RouteRules
+	Plans     Plans
+	Tokens    TokenConfigs
+	Access    *AccessList
+}
+
+// Limites padrão por IP da política
+type PolicyDefaults struct {
+	Limit                int
+	WindowSeconds        int
+	BlockDurationSeconds int
+	DryRun               bool
+	Key                  string
+}
+
+// Headers de rate limit da política
+type PolicyHeaders struct {
+	Mode        string
+	ResetFormat string
+}
+
+// Claims do JWT que identificam o cliente (Key) e definem plano e limite
+type JWTClaims struct {
+	Key   string
+	Plan  string
+	Limit string
+}
+
+// Conversões para os tipos internos. As conversões diretas entre structs
+// deixam de compilar se os campos divergirem.
+
+func (c Config) internal() *config.RateLimitConfig {
+	cfg := config.RateLimitConfig(c)
+	return &cfg
+}
+
+func (t TokenConfigs) internal() config.TokenConfigs {
+	return convertMap(t, func(token TokenConfig) config.TokenConfig { return config.TokenConfig(token) })
+}
+
+func (p Plans) internal() config.PlanConfigs {
+	return convertMap(p, func(plan PlanConfig) config.PlanConfig { return config.PlanConfig(plan) })
+}
+
+func (r Rules) internal() config.RuleConfigs {
+	return convertMap(r, func(rule RuleConfig) config.RuleConfig { return config.RuleConfig(rule) })
+}
+
+func (h HeaderConfig) internal() config.HeaderConfig {
+	return config.HeaderConfig(h)
+}
+
+func (c RedisConfig) internal() *config.RedisConfig {
+	return &config.RedisConfig{
+		Host:                c.Host,
+		Port:                c.Port,
+		Password:            c.Password,
+		DB:                  c.DB,
+		Mode:                c.Mode,
+		Addrs:               c.Addrs,
+		MasterName:          c.MasterName,
+		SentinelPassword:    c.SentinelPassword,
+		Username:            c.Username,
+		Socket:              c.Socket,
+		TLS:                 config.RedisTLSConfig(c.TLS),
+		PoolSize:            c.PoolSize,
+		MinIdleConns:        c.MinIdleConns,
+		DialTimeout:         c.DialTimeout,
+		ReadTimeout:         c.ReadTimeout,
+		WriteTimeout:        c.WriteTimeout,
+		MaxRetries:          c.MaxRetries,
+		MinRetryBackoff:     c.MinRetryBackoff,
+		MaxRetryBackoff:     c.MaxRetryBackoff,
+		ShardHealthInterval: c.ShardHealthInterval,
+	}
+}
+
+func (r RouteRules) internal() config.RouteRules {
+	if r == nil {
+		return nil
+	}
+	routes := make(config.RouteRules, len(r))
+	for i, route := range r {
+		routes[i] = config.RouteRule(route)
+	}
+	return routes
+}
+
+func (a AccessList) internal() config.AccessList {
+	return config.AccessList(a)
+}
+
+func (c JWTClaims) internal() middleware.JWTClaims {
+	return middleware.JWTClaims(c)
+}
+
+// Converte a política carregada pelo pacote config
+func policyFrom(p *config.Policy) *Policy {
+	policy := &Policy{
+		Version:   p.Version,
+		Algorithm: p.Algorithm,
+		Rules:     convertMap(p.Rules, func(rule config.RuleConfig) RuleConfig { return RuleConfig(rule) }),
+		Plans:     convertMap(p.Plans, func(plan config.PlanConfig) PlanConfig { return PlanConfig(plan) }),
+		Tokens:    convertMap(p.Tokens, func(token config.TokenConfig) TokenConfig { return TokenConfig(token) }),
+	}
+	if p.Defaults != nil {
+		defaults := PolicyDefaults(*p.Defaults)
+		policy.Defaults = &defaults
+	}
+	if p.Headers != nil {
+		headers := PolicyHeaders(*p.Headers)
+		policy.Headers = &headers
+	}
+	if p.Routes != nil {
+		policy.Routes = make(RouteRules, len(p.Routes))
+		for i, route := range p.Routes {
+			policy.Routes[i] = RouteRule(route)
+		}
+	}
+	if p.Access != nil {
+		access := AccessList(*p.Access)
+		policy.Access = &access
+	}
+	return policy
+}
+
+func convertMap[From, To any](m map[string]From, convert func(From) To) map[string]To {
+	if m == nil {
+		return nil
+	}
+	converted := make(map[string]To, len(m))
+	for key, value := range m {
+		converted[key] = convert(value)
+	}
+	return converted
+}
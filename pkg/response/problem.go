@@ -0,0 +1,232 @@
+package response
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Tipos de conteúdo suportados na negociação da resposta 429
+const (
+	ContentTypeJSON    = "application/json"
+	ContentTypeProblem = "application/problem+json"
+	ContentTypeText    = "text/plain"
+	ContentTypeHTML    = "text/html"
+)
+
+const rateLimitDetail = "you have reached the maximum number of requests or actions allowed within a certain time frame"
+
+// Corpo de erro no formato RFC 9457 (application/problem+json)
+type ProblemDetails struct {
+	Type       string `json:"type"`
+	Title      string `json:"title"`
+	Status     int    `json:"status"`
+	Detail     string `json:"detail"`
+	RetryAfter int    `json:"retry_after"`
+	Limit      int    `json:"limit"`
+	Policy     string `json:"policy"`
+	Instance   string `json:"instance"`
+}
+
+// Informações do limite excedido usadas para montar a resposta 429
+type RateLimitDetails struct {
+	Limit     int
+	Remaining int
+	ResetTime time.Time
+	Policy    string
+}
+
+// Controla o formato da resposta 429
+type RateLimitErrorOptions struct {
+	// ProblemJSON usa application/problem+json quando o cliente aceita JSON
+	ProblemJSON bool
+	// ProblemType é o URI do campo "type" (default "about:blank")
+	ProblemType string
+	// Templates personalizados por rota (opcional)
+	Templates *Templates
+}
+
+// Dados disponíveis para os templates personalizados
+type TemplateData struct {
+	Type       string
+	Title      string
+	Status     int
+	Detail     string
+	RetryAfter int
+	Limit      int
+	Remaining  int
+	Policy     string
+	Instance   string
+	ResetTime  time.Time
+	Timestamp  time.Time
+}
+
+var htmlRateLimitTemplate = template.Must(template.New("429").Parse(`<!DOCTYPE html>
+<html>
+<head><title>{{.Status}} {{.Title}}</title></head>
+<body>
+<h1>{{.Title}}</h1>
+<p>{{.Detail}}</p>
+<p>Retry after {{.RetryAfter}} seconds.</p>
+</body>
+</html>
+`))
+
+// Escreve a resposta 429 negociando o formato pelo header Accept (JSON,
+// problem+json, texto ou HTML) e aplicando o template da rota, se houver
+func WriteRateLimitResponse(w http.ResponseWriter, r *http.Request, details RateLimitDetails, opts RateLimitErrorOptions) {
+	now := time.Now()
+	contentType := NegotiateContentType(r.Header.Get("Accept"), opts.ProblemJSON)
+
+	problemType := opts.ProblemType
+	if problemType == "" {
+		problemType = "about:blank"
+	}
+
+	data := TemplateData{
+		Type:       problemType,
+		Title:      http.StatusText(http.StatusTooManyRequests),
+		Status:     http.StatusTooManyRequests,
+		Detail:     rateLimitDetail,
+		RetryAfter: RetryAfterSeconds(details.ResetTime, now),
+		Limit:      details.Limit,
+		Remaining:  details.Remaining,
+		Policy:     details.Policy,
+		Instance:   r.URL.Path,
+		ResetTime:  details.ResetTime,
+		Timestamp:  now,
+	}
+
+	w.Header().Set("Content-Type", contentType+"; charset=utf-8")
+	w.Header().Set("Retry-After", strconv.Itoa(data.RetryAfter))
+
+	if opts.Templates != nil {
+		body, ok, err := opts.Templates.Render(r.URL.Path, formatOf(contentType), data)
+		switch {
+		case err != nil:
+			// Usa o corpo padrão, mas não esconde o template quebrado
+			log.Printf("Rate limit response template error: %v | Path: %s", err, r.URL.Path)
+		case ok:
+			w.WriteHeader(http.StatusTooManyRequests)
+			_, _ = w.Write(body)
+			return
+		}
+	}
+
+	w.WriteHeader(http.StatusTooManyRequests)
+
+	switch contentType {
+	case ContentTypeProblem:
+		_ = json.NewEncoder(w).Encode(ProblemDetails{
+			Type:       data.Type,
+			Title:      data.Title,
+			Status:     data.Status,
+			Detail:     data.Detail,
+			RetryAfter: data.RetryAfter,
+			Limit:      data.Limit,
+			Policy:     data.Policy,
+			Instance:   data.Instance,
+		})
+	case ContentTypeText:
+		_, _ = fmt.Fprintf(w, "%s: %s. Retry after %d seconds.\n", data.Title, data.Detail, data.RetryAfter)
+	case ContentTypeHTML:
+		_ = htmlRateLimitTemplate.Execute(w, data)
+	default:
+		_ = json.NewEncoder(w).Encode(RateLimitResponse{
+			Error:     data.Title,
+			Message:   data.Detail,
+			Timestamp: now,
+		})
+	}
+}
+
+// Escolhe o tipo de conteúdo da resposta a partir do header Accept.
+// Em empate de qualidade vale a ordem de preferência do servidor; se nada
+// for aceitável, responde JSON (não faz sentido trocar um 429 por um 406).
+func NegotiateContentType(accept string, preferProblem bool) string {
+	jsonType := ContentTypeJSON
+	if preferProblem {
+		jsonType = ContentTypeProblem
+	}
+
+	if strings.TrimSpace(accept) == "" {
+		return jsonType
+	}
+
+	offers := []string{ContentTypeJSON, ContentTypeProblem, ContentTypeText, ContentTypeHTML}
+	best := ""
+	bestQ := 0.0
+	bestSpecificity := -1
+
+	for _, offer := range offers {
+		q, specificity := acceptQuality(accept, offer)
+		if q > bestQ || (q == bestQ && q > 0 && specificity > bestSpecificity) {
+			best, bestQ, bestSpecificity = offer, q, specificity
+		}
+	}
+
+	switch best {
+	case "":
+		return jsonType
+	case ContentTypeJSON:
+		return jsonType
+	default:
+		return best
+	}
+}
+
+// Retorna a qualidade (q) com que o Accept aceita o tipo e quão específica
+// foi a regra que casou (0 para */*, 1 para type/*, 2 para o tipo exato)
+func acceptQuality(accept, offer string) (float64, int) {
+	bestQ := 0.0
+	bestSpecificity := -1
+
+	for _, part := range strings.Split(accept, ",") {
+		fields := strings.Split(part, ";")
+		mediaType := strings.ToLower(strings.TrimSpace(fields[0]))
+
+		specificity := -1
+		switch {
+		case mediaType == offer:
+			specificity = 2
+		case strings.HasSuffix(mediaType, "/*") && strings.HasPrefix(offer, strings.TrimSuffix(mediaType, "*")):
+			specificity = 1
+		case mediaType == "*/*":
+			specificity = 0
+		}
+		if specificity < 0 || specificity < bestSpecificity {
+			continue
+		}
+
+		q := 1.0
+		for _, param := range fields[1:] {
+			key, value, found := strings.Cut(strings.TrimSpace(param), "=")
+			if found && strings.TrimSpace(key) == "q" {
+				if parsed, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil {
+					q = parsed
+				}
+			}
+		}
+
+		bestQ, bestSpecificity = q, specificity
+	}
+
+	return bestQ, bestSpecificity
+}
+
+// Mapeia o tipo de conteúdo para a chave de template correspondente
+func formatOf(contentType string) string {
+	switch contentType {
+	case ContentTypeText:
+		return TemplateFormatText
+	case ContentTypeHTML:
+		return TemplateFormatHTML
+	default:
+		return TemplateFormatJSON
+	}
+}
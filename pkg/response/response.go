@@ -2,6 +2,7 @@ package response
 
 import (
 	"encoding/json"
+	"math"
 	"net/http"
 	"strconv"
 	"time"
@@ -51,10 +52,21 @@ func WriteSuccess(w http.ResponseWriter, statusCode int, message string, data in
 	_ = json.NewEncoder(w).Encode(response)
 }
 
+// Escreve a resposta 429 com os headers X-RateLimit-Remaining/Reset e Retry-After.
+//
+// Deprecated: use WriteTooManyRequests, que deixa os headers de rate limit
+// (X-RateLimit-* ou RateLimit) para quem chama.
 func WriteRateLimitError(w http.ResponseWriter, remaining int, resetTime time.Time) {
-	w.Header().Set("Content-Type", "application/json")
 	w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(remaining))
 	w.Header().Set("X-RateLimit-Reset", resetTime.Format(time.RFC3339))
+	WriteTooManyRequests(w, resetTime)
+}
+
+// Escreve a resposta 429 com o header Retry-After. Os headers de rate limit
+// (X-RateLimit-* ou RateLimit) são responsabilidade de quem chama.
+func WriteTooManyRequests(w http.ResponseWriter, resetTime time.Time) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Retry-After", strconv.Itoa(RetryAfterSeconds(resetTime, time.Now())))
 	w.WriteHeader(http.StatusTooManyRequests)
 
 	response := RateLimitResponse{
@@ -65,3 +77,12 @@ func WriteRateLimitError(w http.ResponseWriter, remaining int, resetTime time.Ti
 
 	_ = json.NewEncoder(w).Encode(response)
 }
+
+// Calcula os segundos (arredondados para cima) até o reset, com mínimo de 1
+func RetryAfterSeconds(resetTime, now time.Time) int {
+	seconds := int(math.Ceil(resetTime.Sub(now).Seconds()))
+	if seconds < 1 {
+		return 1
+	}
+	return seconds
+}
@@ -0,0 +1,151 @@
+package response
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"path"
+	"sort"
+	texttemplate "text/template"
+)
+
+// Formatos de template suportados
+const (
+	TemplateFormatJSON = "json"
+	TemplateFormatText = "text"
+	TemplateFormatHTML = "html"
+)
+
+// Rota usada quando nenhuma outra combina com o path da requisição
+const DefaultTemplateRoute = "default"
+
+// Retornado quando o template JSON não produz um JSON válido
+var ErrInvalidJSONOutput = errors.New("json template produced invalid JSON")
+
+// Funções dos templates JSON: {{json .Policy}} escreve o valor já codificado
+// (com aspas e escapes), já que text/template não escapa nada
+var jsonFuncs = texttemplate.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		encoded, err := json.Marshal(v)
+		return string(encoded), err
+	},
+}
+
+// Templates de corpo da resposta 429 de uma rota, um por formato
+type TemplateSet struct {
+	JSON string
+	Text string
+	HTML string
+}
+
+type compiledSet struct {
+	json *texttemplate.Template
+	text *texttemplate.Template
+	html *htmltemplate.Template
+}
+
+// Templates compilados por rota. As chaves são paths exatos ou globs
+// (path.Match), além da rota "default".
+type Templates struct {
+	routes   map[string]compiledSet
+	patterns []string
+}
+
+// Compila os templates de todas as rotas
+func NewTemplates(sets map[string]TemplateSet) (*Templates, error) {
+	t := &Templates{routes: make(map[string]compiledSet, len(sets))}
+
+	for route, set := range sets {
+		var compiled compiledSet
+		var err error
+
+		if set.JSON != "" {
+			if compiled.json, err = texttemplate.New(route + ".json").Funcs(jsonFuncs).Parse(set.JSON); err != nil {
+				return nil, fmt.Errorf("invalid json template for route %q: %w", route, err)
+			}
+		}
+		if set.Text != "" {
+			if compiled.text, err = texttemplate.New(route + ".text").Parse(set.Text); err != nil {
+				return nil, fmt.Errorf("invalid text template for route %q: %w", route, err)
+			}
+		}
+		if set.HTML != "" {
+			if compiled.html, err = htmltemplate.New(route + ".html").Parse(set.HTML); err != nil {
+				return nil, fmt.Errorf("invalid html template for route %q: %w", route, err)
+			}
+		}
+
+		t.routes[route] = compiled
+		if route != DefaultTemplateRoute {
+			t.patterns = append(t.patterns, route)
+		}
+	}
+
+	sort.Strings(t.patterns)
+	return t, nil
+}
+
+// Renderiza o template da rota para o formato pedido. Quando a rota não tem
+// template no formato, usa o da rota "default". Retorna ok=false quando não
+// existe template aplicável.
+func (t *Templates) Render(requestPath, format string, data TemplateData) ([]byte, bool, error) {
+	if set, ok := t.match(requestPath); ok {
+		if body, ok, err := set.render(format, data); ok {
+			return body, true, err
+		}
+	}
+
+	set, ok := t.routes[DefaultTemplateRoute]
+	if !ok {
+		return nil, false, nil
+	}
+	return set.render(format, data)
+}
+
+// Procura o template da rota: path exato e depois globs
+func (t *Templates) match(requestPath string) (compiledSet, bool) {
+	if set, ok := t.routes[requestPath]; ok {
+		return set, true
+	}
+
+	for _, pattern := range t.patterns {
+		if matched, err := path.Match(pattern, requestPath); err == nil && matched {
+			return t.routes[pattern], true
+		}
+	}
+	return compiledSet{}, false
+}
+
+func (s compiledSet) render(format string, data TemplateData) ([]byte, bool, error) {
+	var buf bytes.Buffer
+	var err error
+
+	switch format {
+	case TemplateFormatJSON:
+		if s.json == nil {
+			return nil, false, nil
+		}
+		if err = s.json.Execute(&buf, data); err == nil && !json.Valid(buf.Bytes()) {
+			err = ErrInvalidJSONOutput
+		}
+	case TemplateFormatText:
+		if s.text == nil {
+			return nil, false, nil
+		}
+		err = s.text.Execute(&buf, data)
+	case TemplateFormatHTML:
+		if s.html == nil {
+			return nil, false, nil
+		}
+		err = s.html.Execute(&buf, data)
+	default:
+		return nil, false, nil
+	}
+
+	if err != nil {
+		return nil, true, fmt.Errorf("error rendering %s template: %w", format, err)
+	}
+	return buf.Bytes(), true, nil
+}
@@ -0,0 +1,157 @@
+package integration
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"fc-pos-golang-rate-limiter/internal/limiter"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/wait"
+)
+
+// Conta as idas ao Redis: cada comando ou pipeline é uma
+type roundTripCounter struct {
+	count int64
+}
+
+func (c *roundTripCounter) BeforeProcess(ctx context.Context, cmd redis.Cmder) (context.Context, error) {
+	atomic.AddInt64(&c.count, 1)
+	return ctx, nil
+}
+
+func (c *roundTripCounter) AfterProcess(ctx context.Context, cmd redis.Cmder) error {
+	return nil
+}
+
+func (c *roundTripCounter) BeforeProcessPipeline(ctx context.Context, cmds []redis.Cmder) (context.Context, error) {
+	atomic.AddInt64(&c.count, 1)
+	return ctx, nil
+}
+
+func (c *roundTripCounter) AfterProcessPipeline(ctx context.Context, cmds []redis.Cmder) error {
+	return nil
+}
+
+// Sobe um Redis em container e retorna o endereço
+func startRedis(tb testing.TB) string {
+	tb.Helper()
+
+	ctx := context.Background()
+	redisContainer, err := testcontainers.GenericContainer(ctx, testcontainers.GenericContainerRequest{
+		ContainerRequest: testcontainers.ContainerRequest{
+			Image:        "redis:7-alpine",
+			ExposedPorts: []string{"6379/tcp"},
+			WaitingFor:   wait.ForLog("Ready to accept connections"),
+		},
+		Started: true,
+	})
+	require.NoError(tb, err)
+	tb.Cleanup(func() { _ = redisContainer.Terminate(ctx) })
+
+	host, err := redisContainer.Host(ctx)
+	require.NoError(tb, err)
+	port, err := redisContainer.MappedPort(ctx, "6379")
+	require.NoError(tb, err)
+
+	return host + ":" + port.Port()
+}
+
+func TestBatchStrategyIntegration(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	ctx := context.Background()
+	addr := startRedis(t)
+
+	// Duas instâncias compartilhando o mesmo Redis
+	limit := 50
+	leaseSize := 5
+	instances := make([]*limiter.BatchStrategy, 2)
+	for i := range instances {
+		client := redis.NewClient(&redis.Options{Addr: addr})
+		instances[i] = limiter.NewBatchStrategy(limiter.NewRedisStrategy(client),
+			limiter.WithBatchLeaseSize(leaseSize),
+			limiter.WithBatchSyncInterval(20*time.Millisecond),
+		)
+	}
+
+	var allowed int64
+	var wg sync.WaitGroup
+	for _, instance := range instances {
+		wg.Add(1)
+		go func(instance *limiter.BatchStrategy) {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				ok, _, _, err := instance.Allow(ctx, "test:batch:token:pro", limit, 10*time.Second, time.Minute)
+				assert.NoError(t, err)
+				if ok {
+					atomic.AddInt64(&allowed, 1)
+				}
+			}
+		}(instance)
+	}
+	wg.Wait()
+
+	assert.GreaterOrEqual(t, allowed, int64(limit))
+	assert.LessOrEqual(t, allowed, int64(limit+len(instances)*leaseSize))
+
+	for _, instance := range instances {
+		require.NoError(t, instance.Close())
+	}
+}
+
+// Compara o modo exato (uma verificação no Redis por requisição) com o modo
+// aproximado em lote. Executar com:
+//
+//	go test ./tests/integration -run '^$' -bench StorageModes   # ou: make bench-storage
+func BenchmarkStorageModes(b *testing.B) {
+	if testing.Short() {
+		b.Skip("Skipping integration benchmark in short mode")
+	}
+
+	addr := startRedis(b)
+	window := time.Minute
+
+	run := func(b *testing.B, newStorage func(client *redis.Client) limiter.StorageStrategy) {
+		counter := &roundTripCounter{}
+		client := redis.NewClient(&redis.Options{Addr: addr})
+		client.AddHook(counter)
+		storage := newStorage(client)
+		defer storage.Close()
+
+		ctx := context.Background()
+		key := fmt.Sprintf("bench:%s:token:pro", b.Name())
+		b.ResetTimer()
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				if _, _, _, err := storage.Allow(ctx, key, b.N*2, window, time.Minute); err != nil {
+					b.Error(err)
+				}
+			}
+		})
+		b.StopTimer()
+		b.ReportMetric(float64(atomic.LoadInt64(&counter.count))/float64(b.N), "redis-ops/op")
+	}
+
+	b.Run("exact", func(b *testing.B) {
+		run(b, func(client *redis.Client) limiter.StorageStrategy {
+			return limiter.NewRedisStrategy(client)
+		})
+	})
+	for _, leaseSize := range []int{10, 100} {
+		b.Run(fmt.Sprintf("batch/lease=%d", leaseSize), func(b *testing.B) {
+			run(b, func(client *redis.Client) limiter.StorageStrategy {
+				return limiter.NewBatchStrategy(limiter.NewRedisStrategy(client), limiter.WithBatchLeaseSize(leaseSize))
+			})
+		})
+	}
+}
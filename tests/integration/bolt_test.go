@@ -0,0 +1,47 @@
+package integration
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"fc-pos-golang-rate-limiter/internal/limiter"
+)
+
+// Mede o storage bolt com e sem fsync por transação, com um cliente por
+// goroutine. Não requer Docker. Executar com:
+//
+//	go test ./tests/integration -run '^$' -bench BoltStorage   # ou: make bench-storage
+func BenchmarkBoltStorage(b *testing.B) {
+	window := time.Minute
+
+	run := func(b *testing.B, opts ...limiter.BoltOption) {
+		storage, err := limiter.NewBoltStrategy(filepath.Join(b.TempDir(), "bench.db"), opts...)
+		if err != nil {
+			b.Fatal(err)
+		}
+		defer storage.Close()
+
+		ctx := context.Background()
+		var client int64
+		b.ResetTimer()
+		b.RunParallel(func(pb *testing.PB) {
+			key := fmt.Sprintf("ip:10.0.0.%d", atomic.AddInt64(&client, 1))
+			for pb.Next() {
+				if _, _, _, err := storage.Allow(ctx, key, b.N*2, window, time.Minute); err != nil {
+					b.Error(err)
+				}
+			}
+		})
+	}
+
+	b.Run("sync", func(b *testing.B) {
+		run(b)
+	})
+	b.Run("nosync", func(b *testing.B) {
+		run(b, limiter.WithBoltNoSync(true))
+	})
+}
@@ -0,0 +1,94 @@
+package integration
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"fc-pos-golang-rate-limiter/internal/limiter"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedisConcurrencyStrategyIntegration(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	ctx := context.Background()
+	client := redis.NewClient(&redis.Options{Addr: startRedis(t)})
+	defer client.Close()
+
+	slots := limiter.NewRedisConcurrencyStrategy(client)
+
+	t.Run("Caps simultaneous slots across instances", func(t *testing.T) {
+		// Duas "instâncias" disputando os mesmos 5 slots
+		other := limiter.NewRedisConcurrencyStrategy(client)
+
+		var mu sync.Mutex
+		var ids []string
+		var wg sync.WaitGroup
+		for i := 0; i < 20; i++ {
+			strategy := slots
+			if i%2 == 1 {
+				strategy = other
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				id, _, err := strategy.Acquire(ctx, "ip:1.1.1.1", 5, time.Minute)
+				assert.NoError(t, err)
+				if id != "" {
+					mu.Lock()
+					ids = append(ids, id)
+					mu.Unlock()
+				}
+			}()
+		}
+		wg.Wait()
+		assert.Len(t, ids, 5)
+
+		require.NoError(t, slots.Release(ctx, "ip:1.1.1.1", ids[0]))
+		id, inFlight, err := other.Acquire(ctx, "ip:1.1.1.1", 5, time.Minute)
+		require.NoError(t, err)
+		assert.NotEmpty(t, id)
+		assert.Equal(t, 5, inFlight)
+	})
+
+	t.Run("Leases of crashed instances expire", func(t *testing.T) {
+		id, _, err := slots.Acquire(ctx, "ip:2.2.2.2", 1, 300*time.Millisecond)
+		require.NoError(t, err)
+		require.NotEmpty(t, id)
+
+		// Sem Release: o slot só volta quando o lease expira
+		blocked, _, err := slots.Acquire(ctx, "ip:2.2.2.2", 1, 300*time.Millisecond)
+		require.NoError(t, err)
+		assert.Empty(t, blocked)
+
+		time.Sleep(400 * time.Millisecond)
+		id, inFlight, err := slots.Acquire(ctx, "ip:2.2.2.2", 1, 300*time.Millisecond)
+		require.NoError(t, err)
+		assert.NotEmpty(t, id)
+		assert.Equal(t, 1, inFlight)
+
+		ttl, err := client.PTTL(ctx, "{ip:2.2.2.2}").Result()
+		require.NoError(t, err)
+		assert.Greater(t, ttl, time.Duration(0))
+	})
+
+	t.Run("Refresh keeps the slot", func(t *testing.T) {
+		id, _, err := slots.Acquire(ctx, "ip:3.3.3.3", 1, 300*time.Millisecond)
+		require.NoError(t, err)
+
+		time.Sleep(200 * time.Millisecond)
+		require.NoError(t, slots.Refresh(ctx, "ip:3.3.3.3", id, 300*time.Millisecond))
+		time.Sleep(200 * time.Millisecond)
+
+		blocked, _, err := slots.Acquire(ctx, "ip:3.3.3.3", 1, 300*time.Millisecond)
+		require.NoError(t, err)
+		assert.Empty(t, blocked)
+	})
+}
@@ -0,0 +1,128 @@
+package integration
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"fc-pos-golang-rate-limiter/internal/config"
+	"fc-pos-golang-rate-limiter/internal/limiter"
+	"fc-pos-golang-rate-limiter/internal/rls"
+
+	ratelimitv3 "github.com/envoyproxy/go-control-plane/envoy/extensions/common/ratelimit/v3"
+	rlsv3 "github.com/envoyproxy/go-control-plane/envoy/service/ratelimit/v3"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/wait"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func TestEnvoyRateLimitServiceIntegration(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	ctx := context.Background()
+	req := testcontainers.ContainerRequest{
+		Image:        "redis:7-alpine",
+		ExposedPorts: []string{"6379/tcp"},
+		WaitingFor:   wait.ForLog("Ready to accept connections"),
+	}
+
+	redisContainer, err := testcontainers.GenericContainer(ctx, testcontainers.GenericContainerRequest{
+		ContainerRequest: req,
+		Started:          true,
+	})
+	require.NoError(t, err)
+	defer func() { _ = redisContainer.Terminate(ctx) }()
+
+	host, err := redisContainer.Host(ctx)
+	require.NoError(t, err)
+
+	port, err := redisContainer.MappedPort(ctx, "6379")
+	require.NoError(t, err)
+
+	redisClient := redis.NewClient(&redis.Options{
+		Addr: host + ":" + port.Port(),
+		DB:   0,
+	})
+
+	err = redisClient.Ping(ctx).Err()
+	require.NoError(t, err)
+
+	storageStrategy := limiter.NewRedisStrategy(redisClient)
+	rateLimiter := limiter.NewRateLimiter(storageStrategy, &config.RateLimitConfig{
+		IPLimit:              3,
+		WindowSeconds:        1,
+		BlockDurationSeconds: 300,
+	}, config.TokenConfigs{
+		"test_token": config.TokenConfig{Limit: 5, WindowSeconds: 1, BlockDurationSeconds: 300},
+	})
+
+	// Servidor gRPC em processo, acessado por um cliente via bufconn
+	listener := bufconn.Listen(1024 * 1024)
+	grpcServer := grpc.NewServer()
+	rls.NewServer(rateLimiter, config.EnvoyConfig{
+		Domain:   "edge",
+		RuleKey:  "rule",
+		IPKey:    "remote_address",
+		TokenKey: "api_key",
+	}, config.DefaultHeaderConfig()).Register(grpcServer)
+	go func() { _ = grpcServer.Serve(listener) }()
+	defer grpcServer.Stop()
+
+	conn, err := grpc.DialContext(ctx, "bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	defer func() { _ = conn.Close() }()
+
+	client := rlsv3.NewRateLimitServiceClient(conn)
+
+	request := func(key, value string) *rlsv3.RateLimitRequest {
+		return &rlsv3.RateLimitRequest{
+			Domain: "edge",
+			Descriptors: []*ratelimitv3.RateLimitDescriptor{{
+				Entries: []*ratelimitv3.RateLimitDescriptor_Entry{{Key: key, Value: value}},
+			}},
+		}
+	}
+
+	t.Run("IP descriptor is limited", func(t *testing.T) {
+		for i := 0; i < 3; i++ {
+			resp, err := client.ShouldRateLimit(ctx, request("remote_address", "192.168.10.1"))
+			require.NoError(t, err)
+			assert.Equal(t, rlsv3.RateLimitResponse_OK, resp.OverallCode)
+			assert.Equal(t, uint32(3-i-1), resp.Statuses[0].LimitRemaining)
+		}
+
+		resp, err := client.ShouldRateLimit(ctx, request("remote_address", "192.168.10.1"))
+		require.NoError(t, err)
+		assert.Equal(t, rlsv3.RateLimitResponse_OVER_LIMIT, resp.OverallCode)
+		assert.Equal(t, rlsv3.RateLimitResponse_OVER_LIMIT, resp.Statuses[0].Code)
+		assert.NotEmpty(t, resp.ResponseHeadersToAdd)
+	})
+
+	t.Run("Token descriptor uses token limit", func(t *testing.T) {
+		for i := 0; i < 5; i++ {
+			resp, err := client.ShouldRateLimit(ctx, request("api_key", "test_token"))
+			require.NoError(t, err)
+			assert.Equal(t, rlsv3.RateLimitResponse_OK, resp.OverallCode)
+			assert.Equal(t, uint32(5), resp.Statuses[0].CurrentLimit.RequestsPerUnit)
+		}
+
+		resp, err := client.ShouldRateLimit(ctx, request("api_key", "test_token"))
+		require.NoError(t, err)
+		assert.Equal(t, rlsv3.RateLimitResponse_OVER_LIMIT, resp.OverallCode)
+	})
+
+	err = storageStrategy.Close()
+	require.NoError(t, err)
+}
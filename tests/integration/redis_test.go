@@ -110,6 +110,28 @@ func TestRedisStrategyIntegration(t *testing.T) {
 		assert.Equal(t, limit-1, remaining)
 	})
 
+	t.Run("AllowN consumes cost", func(t *testing.T) {
+		key := "test:ip:192.168.1.5"
+		limit := 10
+		window := 1 * time.Second
+
+		allowed, remaining, _, err := strategy.AllowN(ctx, key, 4, limit, window, 5*time.Minute)
+		require.NoError(t, err)
+		assert.True(t, allowed)
+		assert.Equal(t, 6, remaining)
+
+		// Custo maior que o saldo é negado sem bloquear a chave
+		allowed, remaining, _, err = strategy.AllowN(ctx, key, 7, limit, window, 5*time.Minute)
+		require.NoError(t, err)
+		assert.False(t, allowed)
+		assert.Equal(t, 6, remaining)
+
+		allowed, remaining, _, err = strategy.AllowN(ctx, key, 6, limit, window, 5*time.Minute)
+		require.NoError(t, err)
+		assert.True(t, allowed)
+		assert.Equal(t, 0, remaining)
+	})
+
 	t.Run("Sliding window behavior", func(t *testing.T) {
 		key := "test:ip:192.168.1.4"
 		limit := 3
@@ -0,0 +1,86 @@
+package integration
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"fc-pos-golang-rate-limiter/internal/config"
+	"fc-pos-golang-rate-limiter/internal/limiter"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedisWaitIntegration(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	ctx := context.Background()
+	client := redis.NewClient(&redis.Options{Addr: startRedis(t)})
+	defer client.Close()
+
+	rateLimiter := limiter.NewRateLimiter(limiter.NewRedisStrategy(client), &config.RateLimitConfig{IPLimit: 10, WindowSeconds: 1}, nil)
+	rateLimiter.SetRules(config.RuleConfigs{
+		"batch":    config.RuleConfig{Limit: 2, WindowSeconds: 1, BlockDurationSeconds: 300},
+		"unlocked": config.RuleConfig{Limit: 2, WindowSeconds: 1},
+	})
+
+	blockKeys := func(t *testing.T) []string {
+		keys, err := client.Keys(ctx, "*:block").Result()
+		require.NoError(t, err)
+		return keys
+	}
+
+	t.Run("Waits for the window without applying the block", func(t *testing.T) {
+		req := limiter.CheckRequest{Identifier: "10.8.0.1", Rule: "batch"}
+		for i := 0; i < 2; i++ {
+			result, err := rateLimiter.Evaluate(ctx, req)
+			require.NoError(t, err)
+			assert.True(t, result.Allowed)
+		}
+
+		start := time.Now()
+		result, err := rateLimiter.Wait(ctx, req, 2*time.Second)
+		require.NoError(t, err)
+		assert.True(t, result.Allowed)
+		assert.Greater(t, time.Since(start), 100*time.Millisecond)
+		assert.Empty(t, blockKeys(t))
+	})
+
+	t.Run("Giving up applies the block", func(t *testing.T) {
+		req := limiter.CheckRequest{Identifier: "10.8.0.2", Rule: "batch"}
+		for i := 0; i < 2; i++ {
+			_, err := rateLimiter.Evaluate(ctx, req)
+			require.NoError(t, err)
+		}
+
+		result, err := rateLimiter.Wait(ctx, req, 10*time.Millisecond)
+		require.NoError(t, err)
+		assert.False(t, result.Allowed)
+
+		keys := blockKeys(t)
+		require.Len(t, keys, 1)
+		ttl, err := client.TTL(ctx, keys[0]).Result()
+		require.NoError(t, err)
+		assert.Greater(t, ttl, time.Minute)
+		require.NoError(t, client.Del(ctx, keys...).Err())
+	})
+
+	t.Run("Zero block duration leaves no block key", func(t *testing.T) {
+		req := limiter.CheckRequest{Identifier: "10.8.0.3", Rule: "unlocked"}
+		for i := 0; i < 3; i++ {
+			_, err := rateLimiter.Evaluate(ctx, req)
+			require.NoError(t, err)
+		}
+		assert.Empty(t, blockKeys(t))
+
+		// A janela expira e o cliente volta a ser permitido
+		time.Sleep(1100 * time.Millisecond)
+		result, err := rateLimiter.Evaluate(ctx, req)
+		require.NoError(t, err)
+		assert.True(t, result.Allowed)
+	})
+}